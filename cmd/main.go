@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"maritime_traffic/cmd/server"
+	"maritime_traffic/cmd/snapshot"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -13,6 +14,7 @@ func main() {
 
 	rootCmd.AddCommand(
 		server.NewServerCmd(),
+		snapshot.NewSnapshotCmd(),
 	)
 	err := rootCmd.Execute()
 	if err != nil {
@@ -1,12 +1,23 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"maritime_traffic/pkg/ais"
 	"maritime_traffic/pkg/handlers"
 	"maritime_traffic/pkg/server"
+	"maritime_traffic/pkg/storage"
 	"maritime_traffic/pkg/traffic"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 	"github.com/spf13/cobra"
@@ -14,14 +25,147 @@ import (
 
 type Config struct {
 	Port int `env:"PORT,default=8080"`
+
+	// plane or geodetic, in geodetic mode thresholds are in metres and max speed is in knots
+	Coordinates string `env:"COORDINATES,default=plane"`
+	// origin of the projection, required in geodetic mode and for AIS:
+	// east-west scale is exact only near the origin latitude, so there is no sensible default
+	OriginLat *float64 `env:"ORIGIN_LAT"`
+	OriginLon *float64 `env:"ORIGIN_LON"`
+
+	YellowThreshold  float64 `env:"YELLOW_THRESHOLD,default=2"`
+	RedThreshold     float64 `env:"RED_THRESHOLD,default=1"`
+	MaxSpeed         float64 `env:"MAX_SPEED,default=100"`
+	PredictionWindow int     `env:"PREDICTION_WINDOW,default=60"`
+	// max speed per vessel class in the same units as max speed, e.g. tanker:20,pilot:40
+	ClassMaxSpeed map[string]float64 `env:"CLASS_MAX_SPEED"`
+	// insert late positions into history instead of rejecting them
+	LatePositions bool `env:"LATE_POSITIONS,default=false"`
+
+	// history compaction is disabled when both retention and downsample age are zero
+	Retention          time.Duration `env:"RETENTION,default=0"`
+	DownsampleAfter    time.Duration `env:"DOWNSAMPLE_AFTER,default=0"`
+	DownsampleInterval time.Duration `env:"DOWNSAMPLE_INTERVAL,default=1m"`
+	CompactEvery       time.Duration `env:"COMPACT_EVERY,default=1m"`
+
+	Storage       string `env:"STORAGE,default=memory"` // memory or file
+	StorageDir    string `env:"STORAGE_DIR,default=data"`
+	SnapshotEvery int    `env:"SNAPSHOT_EVERY,default=100000"` // changes between snapshots
+
+	// AIS NMEA listeners are disabled when address is empty
+	AISUDPAddr string `env:"AIS_UDP_ADDR"`
+	AISTCPAddr string `env:"AIS_TCP_ADDR"`
+}
+
+const (
+	storageMemory = "memory"
+	storageFile   = "file"
+)
+
+const (
+	coordinatesPlane    = "plane"
+	coordinatesGeodetic = "geodetic"
+)
+
+func (c Config) Traffic() traffic.Config {
+	maxSpeed := c.MaxSpeed
+	classMaxSpeed := maps.Clone(c.ClassMaxSpeed)
+	if c.Coordinates == coordinatesGeodetic {
+		maxSpeed *= traffic.Knot
+		for class := range classMaxSpeed {
+			classMaxSpeed[class] *= traffic.Knot
+		}
+	}
+
+	return traffic.Config{
+		YellowThreshold:  c.YellowThreshold,
+		RedThreshold:     c.RedThreshold,
+		MaxSpeed:         maxSpeed,
+		ClassMaxSpeed:    classMaxSpeed,
+		PredictionWindow: c.PredictionWindow,
+		LatePositions:    c.LatePositions,
+
+		Retention:          int(c.Retention.Seconds()),
+		DownsampleAfter:    int(c.DownsampleAfter.Seconds()),
+		DownsampleInterval: int(c.DownsampleInterval.Seconds()),
+	}
+}
+
+func (c Config) Projection() (traffic.Projection, error) {
+	if c.OriginLat == nil || c.OriginLon == nil {
+		return traffic.Projection{}, fmt.Errorf("ORIGIN_LAT and ORIGIN_LON are required in geodetic mode and for AIS")
+	}
+
+	p := traffic.Projection{OriginLat: *c.OriginLat, OriginLon: *c.OriginLon}
+	return p, p.Validate()
+}
+
+func (c Config) CoordinateSystem() (handlers.Coordinates, error) {
+	switch c.Coordinates {
+	case coordinatesPlane:
+		return handlers.Plane, nil
+	case coordinatesGeodetic:
+		projection, err := c.Projection()
+		if err != nil {
+			return nil, err
+		}
+		return handlers.Geodetic(projection), nil
+	default:
+		return nil, fmt.Errorf("unknown coordinates %q", c.Coordinates)
+	}
+}
+
+func (c Config) Store() (traffic.Store, error) {
+	switch c.Storage {
+	case storageMemory:
+		return traffic.NewMemoryStore(), nil
+	case storageFile:
+		return storage.NewFileStore(c.StorageDir, c.SnapshotEvery)
+	default:
+		return nil, fmt.Errorf("unknown storage %q", c.Storage)
+	}
 }
 
 func NewServerCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "start a server",
+		Long:  "Start a server. Flags override environment variables of the same name, e.g. --max-speed overrides MAX_SPEED.",
 		Run:   serve,
 	}
+
+	// defaults are applied from env config, flags only override values which are set
+	flags := cmd.Flags()
+	flags.Int("port", 0, "serving port, PORT")
+	flags.Float64("yellow-threshold", 0, "distance threshold for yellow status, YELLOW_THRESHOLD")
+	flags.Float64("red-threshold", 0, "distance threshold for red status, RED_THRESHOLD")
+	flags.Float64("max-speed", 0, "maximum speed of a ship, MAX_SPEED")
+	flags.Int("prediction-window", 0, "how far ahead collisions are predicted in seconds, PREDICTION_WINDOW")
+
+	return cmd
+}
+
+// applyFlags overrides config loaded from env with flags set in command line
+func applyFlags(cmd *cobra.Command, cfg *Config) error {
+	flags := cmd.Flags()
+	var err error
+	if flags.Changed("port") {
+		cfg.Port, err = flags.GetInt("port")
+	}
+	if err == nil && flags.Changed("yellow-threshold") {
+		cfg.YellowThreshold, err = flags.GetFloat64("yellow-threshold")
+	}
+	if err == nil && flags.Changed("red-threshold") {
+		cfg.RedThreshold, err = flags.GetFloat64("red-threshold")
+	}
+	if err == nil && flags.Changed("max-speed") {
+		cfg.MaxSpeed, err = flags.GetFloat64("max-speed")
+	}
+	if err == nil && flags.Changed("prediction-window") {
+		cfg.PredictionWindow, err = flags.GetInt("prediction-window")
+	}
+
+	return err
 }
 
 func serve(cmd *cobra.Command, args []string) {
@@ -30,19 +174,130 @@ func serve(cmd *cobra.Command, args []string) {
 		slog.Error("failed to load config", "error", err)
 		return
 	}
+	if err := applyFlags(cmd, &cfg); err != nil {
+		slog.Error("failed to load config", "error", err)
+		return
+	}
+
+	coords, err := cfg.CoordinateSystem()
+	if err != nil {
+		slog.Error("invalid config", "error", err)
+		return
+	}
+	if cfg.CompactEvery <= 0 {
+		slog.Error("invalid config", "error", "compaction interval must be positive")
+		return
+	}
+
+	store, err := cfg.Store()
+	if err != nil {
+		slog.Error("failed to open storage", "error", err)
+		return
+	}
+
+	t, err := traffic.NewTraffic(cfg.Traffic(), store)
+	if err != nil {
+		_ = store.Close()
+		slog.Error("failed to create traffic", "error", err)
+		return
+	}
+	defer func() {
+		if err := t.Close(); err != nil {
+			slog.Error("failed to close storage", "error", err)
+		}
+	}()
+
+	shipsH := handlers.NewShipsHandler(t, coords)
+	hazardsH := handlers.NewHazardsHandler(t, coords)
+	geofencesH := handlers.NewGeofencesHandler(t, coords)
+	snapshotH := handlers.NewSnapshotHandler(t)
+	eventsH := handlers.NewEventsHandler(t, coords)
+	watchH := handlers.NewWatchHandler(t, coords)
+	incidentsH := handlers.NewIncidentsHandler(t, coords)
+
+	// listeners must stop before traffic is closed
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	t := traffic.NewTraffic()
-	shipsH := handlers.NewShipsHandler(t)
+	if err := serveAIS(ctx, cfg, t, &wg); err != nil {
+		slog.Error("failed to start AIS listener", "error", err)
+		return
+	}
+
+	if cfg.Retention > 0 || cfg.DownsampleAfter > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.RunCompaction(ctx, cfg.CompactEvery)
+		}()
+	}
 
 	server := http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: server.NewAPI(shipsH),
+		Handler: server.NewAPI(shipsH, hazardsH, geofencesH, snapshotH, eventsH, watchH, incidentsH),
+		// event streams and websockets never finish on their own, they end with the context on shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			slog.Error("failed to shutdown server", "error", err)
+		}
+	}()
 
 	slog.Info("listening on port", "port", cfg.Port)
-	err := server.ListenAndServe()
-	if err != nil {
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		slog.Error("failed to start server", "error", err)
 		return
 	}
 }
+
+func serveAIS(ctx context.Context, cfg Config, t *traffic.Traffic, wg *sync.WaitGroup) error {
+	if cfg.AISUDPAddr == "" && cfg.AISTCPAddr == "" {
+		return nil
+	}
+
+	projection, err := cfg.Projection()
+	if err != nil {
+		return err
+	}
+	l := ais.NewListener(t, projection)
+
+	if cfg.AISUDPAddr != "" {
+		conn, err := net.ListenPacket("udp", cfg.AISUDPAddr)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("listening for AIS over UDP", "addr", cfg.AISUDPAddr)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.ServeUDP(ctx, conn); err != nil {
+				slog.Error("AIS UDP listener failed", "error", err)
+			}
+		}()
+	}
+
+	if cfg.AISTCPAddr != "" {
+		ln, err := net.Listen("tcp", cfg.AISTCPAddr)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("listening for AIS over TCP", "addr", cfg.AISTCPAddr)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.ServeTCP(ctx, ln); err != nil {
+				slog.Error("AIS TCP listener failed", "error", err)
+			}
+		}()
+	}
+
+	return nil
+}
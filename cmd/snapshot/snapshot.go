@@ -0,0 +1,161 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maritime_traffic/pkg/storage"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagServer        = "server"
+	flagDir           = "dir"
+	flagFile          = "file"
+	flagSnapshotEvery = "snapshot-every"
+
+	snapshotPath        = "/api/v1/snapshot"
+	snapshotContentType = "application/x-ndjson"
+)
+
+func NewSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "export or import traffic snapshot",
+		Long: `Snapshot is a versioned JSON lines dump of all ships history, statuses and speed anomalies, vessel profiles, hazards, geofences, audit and incident logs.
+Source or target is either running server(--server) or file storage directory(--dir).
+Import replaces all of it in the target, hazards and geofences missing from the snapshot are removed.`,
+	}
+
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "write snapshot to the file or stdout",
+		RunE:  exportSnapshot,
+	}
+	export.Flags().StringP(flagFile, "f", "", "output file, stdout if empty")
+
+	imp := &cobra.Command{
+		Use:   "import",
+		Short: "replace traffic with the snapshot from the file or stdin",
+		RunE:  importSnapshot,
+	}
+	imp.Flags().StringP(flagFile, "f", "", "input file, stdin if empty")
+
+	for _, c := range []*cobra.Command{export, imp} {
+		c.Flags().String(flagServer, "", "server address, e.g. http://localhost:8080")
+		c.Flags().String(flagDir, "", "file storage directory, server must be stopped")
+		c.Flags().Int(flagSnapshotEvery, 100000, "number of changes between snapshots of the file storage")
+		c.MarkFlagsOneRequired(flagServer, flagDir)
+		c.MarkFlagsMutuallyExclusive(flagServer, flagDir)
+	}
+
+	cmd.AddCommand(export, imp)
+	return cmd
+}
+
+func exportSnapshot(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	if name, _ := cmd.Flags().GetString(flagFile); name != "" {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if addr, _ := cmd.Flags().GetString(flagServer); addr != "" {
+		resp, err := http.Get(addr + snapshotPath)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to export snapshot: %s", resp.Status)
+		}
+
+		// decode to validate snapshot before writing it anywhere
+		store := traffic.NewMemoryStore()
+		if _, err := traffic.ReadSnapshot(resp.Body, store); err != nil {
+			return err
+		}
+
+		return traffic.WriteSnapshot(out, traffic.SnapshotHeader{}, store)
+	}
+
+	store, err := openFileStore(cmd)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return traffic.WriteSnapshot(out, traffic.SnapshotHeader{}, store)
+}
+
+func importSnapshot(cmd *cobra.Command, args []string) error {
+	in := cmd.InOrStdin()
+	if name, _ := cmd.Flags().GetString(flagFile); name != "" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if addr, _ := cmd.Flags().GetString(flagServer); addr != "" {
+		// decode first, so broken snapshot never reaches the server
+		imported := traffic.NewMemoryStore()
+		if _, err := traffic.ReadSnapshot(in, imported); err != nil {
+			return err
+		}
+
+		var body bytes.Buffer
+		if err := traffic.WriteSnapshot(&body, traffic.SnapshotHeader{}, imported); err != nil {
+			return err
+		}
+
+		resp, err := http.Post(addr+snapshotPath, snapshotContentType, &body)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			msg, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("failed to import snapshot: %s: %s", resp.Status, bytes.TrimSpace(msg))
+		}
+
+		return nil
+	}
+
+	store, err := openFileStore(cmd)
+	if err != nil {
+		return err
+	}
+	// same import as the server does, it decodes snapshot before replacing anything
+	t, err := traffic.NewTraffic(traffic.DefaultConfig(), store)
+	if err != nil {
+		_ = store.Close()
+		return err
+	}
+
+	err = t.Import(in)
+	if closeErr := t.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func openFileStore(cmd *cobra.Command) (*storage.FileStore, error) {
+	dir, _ := cmd.Flags().GetString(flagDir)
+	snapshotEvery, _ := cmd.Flags().GetInt(flagSnapshotEvery)
+
+	return storage.NewFileStore(dir, snapshotEvery)
+}
@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"bytes"
+	"maritime_traffic/pkg/storage"
+	"maritime_traffic/pkg/traffic"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportExportDir(t *testing.T) {
+	store := traffic.NewMemoryStore()
+	require.NoError(t, store.Put("1", []traffic.ShipPosition{
+		{Time: 100, Position: traffic.Vector{X: 1, Y: 2}},
+		{Time: 101, Position: traffic.Vector{X: 2, Y: 3.5}, Speed: traffic.Vector{X: 1, Y: 1.5}},
+	}, traffic.Yellow))
+	require.NoError(t, store.AppendAnomaly("1", traffic.SpeedAnomaly{Time: 101, RawSpeed: 250.5, Speed: 100, MaxSpeed: 100}))
+	require.NoError(t, store.PutProfile("1", traffic.VesselProfile{Type: "tanker", Length: 250, Beam: 40, SafetyRadius: 300}))
+	require.NoError(t, store.PutHazard(traffic.Hazard{ID: "wreck", Position: traffic.Vector{X: 5, Y: -5}, Radius: 3, RedRadius: 1, YellowRadius: 4}))
+	require.NoError(t, store.PutGeofence(traffic.Geofence{ID: "anchorage", Polygon: []traffic.Vector{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10.5}}, Status: traffic.Red}))
+	require.NoError(t, store.AppendAudit(traffic.AuditRecord{
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Operator: "jane",
+		Action:   traffic.AuditDelete,
+		ShipID:   "2",
+		Before:   traffic.PositionRecord{Time: 40, X: 5, Y: 5},
+	}))
+	require.NoError(t, store.AppendIncident(traffic.Incident{ShipID: "1", Time: 101, From: traffic.Green, To: traffic.Yellow}))
+
+	var snapshot bytes.Buffer
+	require.NoError(t, traffic.WriteSnapshot(&snapshot, traffic.SnapshotHeader{}, store))
+
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "storage")
+	in := filepath.Join(tmp, "in.jsonl")
+	out := filepath.Join(tmp, "out.jsonl")
+	require.NoError(t, os.WriteFile(in, snapshot.Bytes(), 0o644))
+
+	// hazards and geofences are replaced as well
+	fs, err := storage.NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, fs.PutHazard(traffic.Hazard{ID: "reef", RedRadius: 1, YellowRadius: 2}))
+	require.NoError(t, fs.PutGeofence(traffic.Geofence{ID: "port", Polygon: []traffic.Vector{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}, Status: traffic.Red}))
+	require.NoError(t, fs.Close())
+
+	// import twice, so the second import replaces everything of the first one
+	for range 2 {
+		cmd := NewSnapshotCmd()
+		cmd.SetArgs([]string{"import", "--dir", dir, "-f", in})
+		require.NoError(t, cmd.Execute())
+	}
+
+	cmd := NewSnapshotCmd()
+	cmd.SetArgs([]string{"export", "--dir", dir, "-f", out})
+	require.NoError(t, cmd.Execute())
+
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer f.Close()
+
+	exported := traffic.NewMemoryStore()
+	_, err = traffic.ReadSnapshot(f, exported)
+	require.NoError(t, err)
+	assert.Equal(t, store, exported)
+}
@@ -2,14 +2,14 @@ module maritime_traffic
 
 go 1.24
 
-require github.com/spf13/cobra v1.9.1
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/spf13/cobra v1.9.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/sys v0.4.0 // indirect
-	golang.org/x/tools v0.5.1-0.20230111220935-a7f7db3f17fc // indirect
-	golang.org/x/tools/cmd/cover v0.1.0-deprecated // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
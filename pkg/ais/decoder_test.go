@@ -0,0 +1,167 @@
+package ais
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// field of encoded message, value is written as two's complement of length bits
+type field struct {
+	length int
+	value  int64
+}
+
+// armor encodes fields to 6-bit payload, returns payload and fill bits
+func armor(fields ...field) (string, int) {
+	var b []byte
+	for _, f := range fields {
+		for i := f.length - 1; i >= 0; i-- {
+			b = append(b, byte(f.value>>i)&1)
+		}
+	}
+
+	fill := (6 - len(b)%6) % 6
+	b = append(b, make([]byte, fill)...)
+
+	var payload strings.Builder
+	for i := 0; i < len(b); i += 6 {
+		var v byte
+		for _, bit := range b[i : i+6] {
+			v = v<<1 | bit
+		}
+		if v > 39 {
+			v += 8
+		}
+		payload.WriteByte(v + 48)
+	}
+
+	return payload.String(), fill
+}
+
+func nmea(total, number int, seqID, payload string, fill int) string {
+	body := fmt.Sprintf("AIVDM,%d,%d,%s,A,%s,%d", total, number, seqID, payload, fill)
+	return fmt.Sprintf("!%s*%02X", body, checksum(body))
+}
+
+// classB encodes type 18 or 19 report, lat/lon in 1/10000 minute
+func classB(msgType int, mmsi int64, lon, lat int64, sog, cog, second int64) []field {
+	fields := []field{
+		{6, int64(msgType)}, {2, 0}, {30, mmsi}, {8, 0},
+		{10, sog}, {1, 0}, {28, lon}, {27, lat}, {12, cog}, {9, 511}, {6, second},
+	}
+	if msgType == 18 {
+		return append(fields, field{29, 0})
+	}
+	// name, ship type, dimensions, flags and spare
+	return append(fields, field{4, 0}, field{120, 0}, field{8, 0}, field{30, 0}, field{4, 0}, field{7, 0})
+}
+
+func TestDecodeClassA(t *testing.T) {
+	d := NewDecoder()
+
+	fix, ok, err := d.Decode("!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.Equal(t, 1, fix.Type)
+	assert.Equal(t, uint32(477553000), fix.MMSI)
+	assert.InDelta(t, 47.582833, fix.Lat, 1e-5)
+	assert.InDelta(t, -122.345832, fix.Lon, 1e-5)
+	assert.Equal(t, 0.0, fix.SOG)
+	assert.Equal(t, 51.0, fix.COG)
+	assert.Equal(t, 15, fix.Second)
+
+	// tag block and trailing new line are accepted
+	_, ok, err = d.Decode("\\s:station,c:1700000000*5A\\!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C\r\n")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestDecodeClassB(t *testing.T) {
+	d := NewDecoder()
+
+	payload, fill := armor(classB(18, 338087471, -74*600000-3000, 40*600000+42000, 123, 2700, 42)...)
+	fix, ok, err := d.Decode(nmea(1, 1, "", payload, fill))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.Equal(t, 18, fix.Type)
+	assert.Equal(t, uint32(338087471), fix.MMSI)
+	assert.InDelta(t, -74.005, fix.Lon, 1e-9)
+	assert.InDelta(t, 40.07, fix.Lat, 1e-9)
+	assert.InDelta(t, 12.3, fix.SOG, 1e-9)
+	assert.InDelta(t, 270.0, fix.COG, 1e-9)
+	assert.Equal(t, 42, fix.Second)
+
+	// not available fields
+	payload, fill = armor(classB(18, 1, 600000, 600000, sogNotAvailable, cogNotAvailable, secondNotAvailable)...)
+	fix, _, err = d.Decode(nmea(1, 1, "", payload, fill))
+	require.NoError(t, err)
+	assert.Equal(t, -1.0, fix.SOG)
+	assert.Equal(t, -1.0, fix.COG)
+	assert.Equal(t, -1, fix.Second)
+
+	payload, fill = armor(classB(18, 1, lonNotAvailable, latNotAvailable, 0, 0, 0)...)
+	_, _, err = d.Decode(nmea(1, 1, "", payload, fill))
+	assert.ErrorIs(t, err, ErrNoPosition)
+}
+
+func TestDecodeMultiFragment(t *testing.T) {
+	d := NewDecoder()
+
+	payload, fill := armor(classB(19, 211000000, 600000, 1200000, 50, 900, 10)...)
+	first, second := payload[:30], payload[30:]
+
+	_, ok, err := d.Decode(nmea(2, 1, "3", first, 0))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// fragment of other message doesn't interfere
+	_, ok, err = d.Decode(nmea(2, 2, "4", second, fill))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	fix, ok, err := d.Decode(nmea(2, 2, "3", second, fill))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 19, fix.Type)
+	assert.Equal(t, uint32(211000000), fix.MMSI)
+	assert.InDelta(t, 1.0, fix.Lon, 1e-9)
+	assert.InDelta(t, 2.0, fix.Lat, 1e-9)
+
+	// missing first fragment
+	_, ok, err = d.Decode(nmea(2, 2, "3", second, fill))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// too short without second fragment
+	_, _, err = d.Decode(nmea(1, 1, "", first, 0))
+	assert.ErrorIs(t, err, ErrInvalidSentence)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		sentence string
+		err      error
+	}{
+		{"checksum", "!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5D", ErrChecksum},
+		{"no checksum", "!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0", ErrInvalidSentence},
+		{"not AIS", "$GPGGA,1,2", ErrInvalidSentence},
+		{"fields", fmt.Sprintf("!AIVDM,1,1*%02X", checksum("AIVDM,1,1")), ErrInvalidSentence},
+		{"fragment number", nmea(1, 2, "", "177KQJ5000G?tO`K>RA1wUbN0TKH", 0), ErrInvalidSentence},
+		{"payload character", nmea(1, 1, "", "177KQJ5000G?tO`K>RA1wUbN0TK~", 0), ErrInvalidSentence},
+		{"static data", nmea(1, 1, "", "55P5TL01VIaAL@7WKO@mBplU@<PDhh000000001S;AJ::4A80?4i@E53", 2), ErrUnsupportedMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewDecoder().Decode(tt.sentence)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
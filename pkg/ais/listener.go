@@ -0,0 +1,155 @@
+package ais
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"maritime_traffic/pkg/traffic"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDatagramSize is enough for a datagram with many sentences, single sentence is at most 82 bytes
+const maxDatagramSize = 64 * 1024
+
+// maxUDPSenders limits number of senders which fragments are reassembled for
+const maxUDPSenders = 1024
+
+type (
+	// Positioner records position of the ship, implemented by traffic.Traffic
+	Positioner interface {
+		PositionShip(ps traffic.PositionShip) (traffic.PositionResult, error)
+	}
+
+	// Listener receives NMEA sentences over UDP or TCP and feeds decoded positions into traffic
+	Listener struct {
+		traffic    Positioner
+		projection traffic.Projection
+		now        func() time.Time
+	}
+)
+
+func NewListener(t Positioner, projection traffic.Projection) *Listener {
+	return &Listener{
+		traffic:    t,
+		projection: projection,
+		now:        time.Now,
+	}
+}
+
+// ServeUDP reads datagrams with one or more sentences until ctx is done,
+// fragments are reassembled per sender
+func (l *Listener) ServeUDP(ctx context.Context, conn net.PacketConn) error {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	decoders := make(map[string]*Decoder)
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		d := senderDecoder(decoders, addr.String())
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			l.handle(d, line)
+		}
+	}
+}
+
+// senderDecoder returns decoder of the sender, adding one for a new sender
+func senderDecoder(decoders map[string]*Decoder, addr string) *Decoder {
+	d, ok := decoders[addr]
+	if ok {
+		return d
+	}
+
+	if len(decoders) >= maxUDPSenders {
+		// feeds come from few gateways, drop everything instead of tracking activity
+		clear(decoders)
+	}
+	d = NewDecoder()
+	decoders[addr] = d
+	return d
+}
+
+// ServeTCP accepts connections with newline separated sentences until ctx is done
+func (l *Listener) ServeTCP(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (l *Listener) serveConn(ctx context.Context, conn net.Conn) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	slog.Info("AIS connection opened", "remote", conn.RemoteAddr())
+
+	d := NewDecoder()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		l.handle(d, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		slog.Warn("AIS connection failed", "remote", conn.RemoteAddr(), "error", err)
+		return
+	}
+
+	slog.Info("AIS connection closed", "remote", conn.RemoteAddr())
+}
+
+func (l *Listener) handle(d *Decoder, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	fix, ok, err := d.Decode(line)
+	if err != nil {
+		// feeds are full of other message types and reports without position
+		if errors.Is(err, ErrUnsupportedMessage) || errors.Is(err, ErrNoPosition) {
+			return
+		}
+		slog.Warn("invalid AIS sentence", "sentence", line, "error", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	ps := fix.PositionShip(l.now(), l.projection)
+	if _, err := l.traffic.PositionShip(ps); err != nil {
+		// repeated reports of the same fix are expected
+		if errors.Is(err, traffic.ErrTimeInPast) || errors.Is(err, traffic.ErrDuplicateTime) {
+			return
+		}
+		slog.Warn("failed to position ship", "id", ps.ID, "error", err)
+	}
+}
@@ -0,0 +1,123 @@
+package ais
+
+import (
+	"context"
+	"fmt"
+	"maritime_traffic/pkg/traffic"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type positions struct {
+	mu  sync.Mutex
+	all []traffic.PositionShip
+}
+
+func (p *positions) PositionShip(ps traffic.PositionShip) (traffic.PositionResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.all = append(p.all, ps)
+	return traffic.PositionResult{}, nil
+}
+
+func (p *positions) get() []traffic.PositionShip {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]traffic.PositionShip(nil), p.all...)
+}
+
+func TestFixPositionShip(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 30, 20, 0, time.UTC)
+	p := traffic.Projection{OriginLat: 60, OriginLon: 10}
+
+	ps := Fix{MMSI: 123, Lat: 60.001, Lon: 10.002, Second: 15}.PositionShip(received, p)
+	assert.Equal(t, "123", ps.ID)
+	assert.Equal(t, int(received.Unix())-5, ps.Time)
+	// one thousandth of degree is ~111m of latitude and ~55.6m of longitude at 60N
+	assert.InDelta(t, 111.2, ps.Point.Y, 0.1)
+	assert.InDelta(t, 111.2, ps.Point.X, 0.1)
+
+	// report from previous minute
+	ps = Fix{MMSI: 123, Lat: 60, Lon: 10, Second: 50}.PositionShip(received, p)
+	assert.Equal(t, int(received.Unix())-30, ps.Time)
+	assert.Equal(t, traffic.Vector{}, ps.Point)
+
+	ps = Fix{MMSI: 123, Second: -1}.PositionShip(received, p)
+	assert.Equal(t, int(received.Unix()), ps.Time)
+
+	// across antimeridian
+	ps = Fix{Lat: 0, Lon: -179.999}.PositionShip(received, traffic.Projection{OriginLon: 179.999})
+	assert.InDelta(t, 222.4, ps.Point.X, 0.1)
+}
+
+func TestListener(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := &positions{}
+	l := NewListener(sink, traffic.Projection{})
+	l.now = func() time.Time { return time.Date(2024, 5, 1, 12, 30, 20, 0, time.UTC) }
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, l.ServeUDP(ctx, udp))
+	}()
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, l.ServeTCP(ctx, tcp))
+	}()
+
+	classA := "!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C"
+	payload, fill := armor(classB(19, 211000000, 600000, 1200000, 50, 900, 10)...)
+
+	conn, err := net.Dial("udp", udp.LocalAddr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte(classA + "\r\ngarbage\r\n" + nmea(2, 1, "1", payload[:30], 0) + "\n"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte(nmea(2, 2, "1", payload[30:], fill)))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	conn, err = net.Dial("tcp", tcp.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte(classA + "\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	require.Eventually(t, func() bool { return len(sink.get()) == 3 }, time.Second, 10*time.Millisecond)
+
+	ids := map[string]int{}
+	for _, ps := range sink.get() {
+		ids[ps.ID]++
+	}
+	assert.Equal(t, map[string]int{"477553000": 2, "211000000": 1}, ids)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestSenderDecoder(t *testing.T) {
+	decoders := map[string]*Decoder{}
+	d := senderDecoder(decoders, "127.0.0.1:1")
+	assert.Same(t, d, senderDecoder(decoders, "127.0.0.1:1"))
+
+	// senders don't pile up
+	for i := range 2 * maxUDPSenders {
+		senderDecoder(decoders, fmt.Sprintf("10.0.0.1:%d", i))
+		assert.LessOrEqual(t, len(decoders), maxUDPSenders)
+	}
+}
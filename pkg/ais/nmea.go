@@ -0,0 +1,178 @@
+package ais
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxPendingMessages limits number of incomplete multi-fragment messages kept by decoder
+const maxPendingMessages = 64
+
+var (
+	ErrInvalidSentence    = errors.New("invalid NMEA sentence")
+	ErrChecksum           = errors.New("NMEA checksum mismatch")
+	ErrUnsupportedMessage = errors.New("unsupported AIS message type")
+	ErrNoPosition         = errors.New("position is not available")
+)
+
+type (
+	// sentence is a single !AIVDM or !AIVDO sentence, fragment of AIS message
+	sentence struct {
+		total    int
+		number   int
+		seqID    string
+		channel  string
+		payload  string
+		fillBits int
+	}
+
+	fragmentKey struct {
+		seqID   string
+		channel string
+	}
+
+	fragments struct {
+		total    int
+		next     int
+		payload  strings.Builder
+		fillBits int
+	}
+
+	// Decoder reassembles multi-fragment messages and decodes position reports.
+	// Decoder keeps state between sentences and must be used for a single source, it is not safe for concurrent use.
+	Decoder struct {
+		pending map[fragmentKey]*fragments
+	}
+)
+
+func NewDecoder() *Decoder {
+	return &Decoder{
+		pending: make(map[fragmentKey]*fragments),
+	}
+}
+
+// Decode parses sentence and returns position report when sentence completes a message,
+// false means sentence is a fragment of not yet complete message.
+// Messages other than position reports return ErrUnsupportedMessage.
+func (d *Decoder) Decode(line string) (Fix, bool, error) {
+	s, err := parseSentence(line)
+	if err != nil {
+		return Fix{}, false, err
+	}
+
+	payload, fillBits, ok := d.reassemble(s)
+	if !ok {
+		return Fix{}, false, nil
+	}
+
+	bits, err := unarmor(payload, fillBits)
+	if err != nil {
+		return Fix{}, false, err
+	}
+
+	fix, err := decodePosition(bits)
+	if err != nil {
+		return Fix{}, false, err
+	}
+
+	return fix, true, nil
+}
+
+func (d *Decoder) reassemble(s sentence) (string, int, bool) {
+	if s.total == 1 {
+		return s.payload, s.fillBits, true
+	}
+
+	key := fragmentKey{seqID: s.seqID, channel: s.channel}
+	if s.number == 1 {
+		if len(d.pending) >= maxPendingMessages {
+			// messages are short lived, drop everything instead of tracking age
+			clear(d.pending)
+		}
+		d.pending[key] = &fragments{total: s.total, next: 1}
+	}
+
+	f, ok := d.pending[key]
+	if !ok || f.total != s.total || f.next != s.number {
+		// missed or reordered fragment, message can't be recovered
+		delete(d.pending, key)
+		return "", 0, false
+	}
+
+	f.payload.WriteString(s.payload)
+	f.next++
+	if s.number < s.total {
+		return "", 0, false
+	}
+
+	delete(d.pending, key)
+	return f.payload.String(), s.fillBits, true
+}
+
+// parseSentence parses !AIVDM/!AIVDO sentence, optional NMEA 4.0 tag block is skipped
+//
+//	!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C
+func parseSentence(line string) (sentence, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, `\`) {
+		end := strings.Index(line[1:], `\`)
+		if end < 0 {
+			return sentence{}, fmt.Errorf("%w: unterminated tag block", ErrInvalidSentence)
+		}
+		line = line[end+2:]
+	}
+
+	if !strings.HasPrefix(line, "!") {
+		return sentence{}, fmt.Errorf("%w: must start with '!'", ErrInvalidSentence)
+	}
+
+	star := strings.LastIndexByte(line, '*')
+	if star < 0 || len(line)-star != 3 {
+		return sentence{}, fmt.Errorf("%w: missing checksum", ErrInvalidSentence)
+	}
+	expected, err := strconv.ParseUint(line[star+1:], 16, 8)
+	if err != nil {
+		return sentence{}, fmt.Errorf("%w: invalid checksum: %w", ErrInvalidSentence, err)
+	}
+
+	body := line[1:star]
+	if checksum(body) != byte(expected) {
+		return sentence{}, ErrChecksum
+	}
+
+	fields := strings.Split(body, ",")
+	if len(fields) != 7 {
+		return sentence{}, fmt.Errorf("%w: expected 7 fields, got %d", ErrInvalidSentence, len(fields))
+	}
+	if len(fields[0]) != 5 || (fields[0][2:] != "VDM" && fields[0][2:] != "VDO") {
+		return sentence{}, fmt.Errorf("%w: unsupported sentence %q", ErrInvalidSentence, fields[0])
+	}
+
+	s := sentence{
+		seqID:   fields[3],
+		channel: fields[4],
+		payload: fields[5],
+	}
+	if s.total, err = strconv.Atoi(fields[1]); err != nil || s.total < 1 {
+		return sentence{}, fmt.Errorf("%w: invalid fragment count %q", ErrInvalidSentence, fields[1])
+	}
+	if s.number, err = strconv.Atoi(fields[2]); err != nil || s.number < 1 || s.number > s.total {
+		return sentence{}, fmt.Errorf("%w: invalid fragment number %q", ErrInvalidSentence, fields[2])
+	}
+	if s.fillBits, err = strconv.Atoi(fields[6]); err != nil || s.fillBits < 0 || s.fillBits > 5 {
+		return sentence{}, fmt.Errorf("%w: invalid fill bits %q", ErrInvalidSentence, fields[6])
+	}
+
+	return s, nil
+}
+
+// checksum is XOR of all characters between '!' and '*'
+func checksum(body string) byte {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return sum
+}
@@ -0,0 +1,165 @@
+package ais
+
+import (
+	"fmt"
+	"maritime_traffic/pkg/traffic"
+	"strconv"
+	"time"
+)
+
+const (
+	lonNotAvailable    = 181 * 600000
+	latNotAvailable    = 91 * 600000
+	sogNotAvailable    = 1023
+	cogNotAvailable    = 3600
+	secondNotAvailable = 60
+)
+
+// Fix is a position report of a vessel
+type Fix struct {
+	MMSI uint32
+	Type int
+	Lat  float64 // degrees, north is positive
+	Lon  float64 // degrees, east is positive
+	// SOG is speed over ground in knots, negative if not available
+	SOG float64
+	// COG is course over ground in degrees, negative if not available
+	COG float64
+	// Second is UTC second when report was generated, negative if not available
+	Second int
+}
+
+// bits of unarmored payload, one bit per byte for simple field extraction
+type bits []byte
+
+// unarmor converts 6-bit ASCII armored payload to bits, fill bits are dropped
+func unarmor(payload string, fillBits int) (bits, error) {
+	b := make(bits, 0, len(payload)*6)
+	for i := 0; i < len(payload); i++ {
+		c := payload[i]
+		if c < 48 || c > 119 || (c > 87 && c < 96) {
+			return nil, fmt.Errorf("%w: invalid payload character %q", ErrInvalidSentence, c)
+		}
+
+		v := c - 48
+		if v > 40 {
+			v -= 8
+		}
+		for j := 5; j >= 0; j-- {
+			b = append(b, (v>>j)&1)
+		}
+	}
+
+	if fillBits > len(b) {
+		return nil, fmt.Errorf("%w: fill bits exceed payload", ErrInvalidSentence)
+	}
+	return b[:len(b)-fillBits], nil
+}
+
+func (b bits) uint(start, length int) uint32 {
+	var v uint32
+	for _, bit := range b[start : start+length] {
+		v = v<<1 | uint32(bit)
+	}
+	return v
+}
+
+func (b bits) int(start, length int) int32 {
+	v := b.uint(start, length)
+	if b[start] == 1 {
+		// sign extension of two's complement
+		v |= ^uint32(0) << length
+	}
+	return int32(v)
+}
+
+// positionLayout is the offsets of common fields in position reports
+type positionLayout struct {
+	minLength int
+	sog       int
+	lon       int
+	lat       int
+	cog       int
+	second    int
+}
+
+var (
+	// class A position report, types 1, 2 and 3
+	classALayout = positionLayout{minLength: 168, sog: 50, lon: 61, lat: 89, cog: 116, second: 137}
+	// class B position report, type 18 and extended type 19 share position fields
+	classBLayout = positionLayout{minLength: 168, sog: 46, lon: 57, lat: 85, cog: 112, second: 133}
+)
+
+func decodePosition(b bits) (Fix, error) {
+	if len(b) < 38 {
+		return Fix{}, fmt.Errorf("%w: payload is too short", ErrInvalidSentence)
+	}
+
+	fix := Fix{
+		Type: int(b.uint(0, 6)),
+		MMSI: b.uint(8, 30),
+	}
+
+	var layout positionLayout
+	switch fix.Type {
+	case 1, 2, 3:
+		layout = classALayout
+	case 18:
+		layout = classBLayout
+	case 19:
+		layout = classBLayout
+		layout.minLength = 312
+	default:
+		return Fix{}, fmt.Errorf("%w: %d", ErrUnsupportedMessage, fix.Type)
+	}
+
+	if len(b) < layout.minLength {
+		return Fix{}, fmt.Errorf("%w: type %d payload must have %d bits, got %d", ErrInvalidSentence, fix.Type, layout.minLength, len(b))
+	}
+
+	lon := b.int(layout.lon, 28)
+	lat := b.int(layout.lat, 27)
+	if lon == lonNotAvailable || lat == latNotAvailable {
+		return Fix{}, ErrNoPosition
+	}
+	fix.Lon = float64(lon) / 600000
+	fix.Lat = float64(lat) / 600000
+	if fix.Lon < -180 || fix.Lon > 180 || fix.Lat < -90 || fix.Lat > 90 {
+		return Fix{}, fmt.Errorf("%w: coordinates out of range", ErrInvalidSentence)
+	}
+
+	fix.SOG = -1
+	if sog := b.uint(layout.sog, 10); sog != sogNotAvailable {
+		fix.SOG = float64(sog) / 10
+	}
+
+	fix.COG = -1
+	if cog := b.uint(layout.cog, 12); cog < cogNotAvailable {
+		fix.COG = float64(cog) / 10
+	}
+
+	fix.Second = -1
+	if second := int(b.uint(layout.second, 6)); second < secondNotAvailable {
+		fix.Second = second
+	}
+
+	return fix, nil
+}
+
+// PositionShip converts fix to traffic position, MMSI is ship id.
+// Report has only UTC second of the fix, so time is the last moment before received with that second.
+func (f Fix) PositionShip(received time.Time, p traffic.Projection) traffic.PositionShip {
+	t := received.Unix()
+	if f.Second >= 0 {
+		t = t - int64(received.Second()) + int64(f.Second)
+		if t > received.Unix() {
+			t -= 60
+		}
+	}
+
+	return traffic.PositionShip{
+		ID:    strconv.FormatUint(uint64(f.MMSI), 10),
+		Time:  int(t),
+		Point: p.Project(f.Lat, f.Lon),
+	}
+}
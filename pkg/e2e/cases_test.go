@@ -1,9 +1,13 @@
 package e2e
 
 import (
+	"context"
 	"maritime_traffic/pkg/handlers"
+	"maritime_traffic/pkg/traffic"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -259,11 +263,12 @@ func TestCollision(t *testing.T) {
 				require.NoError(t, err)
 				results[i] = result
 			}
-			// ignore time, x, y
+			// ignore time, x, y and conflicts
 			for i := range results {
 				results[i].Time = 0
 				results[i].X = 0
 				results[i].Y = 0
+				results[i].Conflicts = nil
 			}
 
 			assert.Equal(t, tt.expectedResults, results)
@@ -271,6 +276,866 @@ func TestCollision(t *testing.T) {
 	}
 }
 
+func TestConflicts(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 10, Y: 10})
+	require.NoError(t, err)
+	_, err = client.PositionShip("123", 101, handlers.Position{X: 11, Y: 10})
+	require.NoError(t, err)
+
+	// head on with 123, they meet at (15, 10)
+	_, err = client.PositionShip("345", 100, handlers.Position{X: 21, Y: 10})
+	require.NoError(t, err)
+	res, err := client.PositionShip("345", 101, handlers.Position{X: 20, Y: 10})
+	require.NoError(t, err)
+
+	assert.Equal(t, handlers.Red, res.Status)
+	assert.Equal(t, []handlers.Conflict{
+		{
+			Kind:          "ship",
+			ID:            "123",
+			Status:        handlers.Red,
+			Distance:      0,
+			Time:          105.5,
+			Position:      handlers.Position{X: 15, Y: 10},
+			OtherPosition: handlers.Position{X: 15, Y: 10},
+		},
+	}, res.Conflicts)
+
+	// passing by the tower
+	res, err = client.PositionShip("678", 100, handlers.Position{X: -10, Y: 1})
+	require.NoError(t, err)
+	res, err = client.PositionShip("678", 101, handlers.Position{X: -9, Y: 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, handlers.Yellow, res.Status)
+	assert.Equal(t, []handlers.Conflict{
+		{
+			Kind:          "hazard",
+			ID:            "tower",
+			Status:        handlers.Yellow,
+			Distance:      1,
+			Time:          110,
+			Position:      handlers.Position{X: 0, Y: 1},
+			OtherPosition: handlers.Position{X: 0, Y: 0},
+		},
+	}, res.Conflicts)
+}
+
+func TestHazards(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	buoy := handlers.Hazard{ID: "buoy", Position: handlers.Position{X: 500, Y: 500}, Radius: 2, RedRadius: 1, YellowRadius: 3}
+	require.NoError(t, client.AddHazard(buoy))
+	t.Cleanup(func() { client.DeleteHazard(buoy.ID) })
+
+	assert.Error(t, client.AddHazard(buoy))
+	assert.Error(t, client.AddHazard(handlers.Hazard{ID: "pier", RedRadius: 2, YellowRadius: 1}))
+
+	hazards, err := client.GetHazards()
+	require.NoError(t, err)
+	assert.Equal(t, []handlers.Hazard{
+		buoy,
+		{ID: "tower", Position: handlers.Position{X: 0, Y: 0}, RedRadius: 1, YellowRadius: 2},
+	}, hazards)
+
+	// passes 2 units from the edge of the buoy
+	_, err = client.PositionShip("123", 100, handlers.Position{X: 490, Y: 504})
+	require.NoError(t, err)
+	res, err := client.PositionShip("123", 101, handlers.Position{X: 491, Y: 504})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Yellow, res.Status)
+	assert.Equal(t, []handlers.Conflict{
+		{
+			Kind:          "hazard",
+			ID:            "buoy",
+			Status:        handlers.Yellow,
+			Distance:      2,
+			Time:          110,
+			Position:      handlers.Position{X: 500, Y: 504},
+			OtherPosition: handlers.Position{X: 500, Y: 500},
+		},
+	}, res.Conflicts)
+
+	// bigger buoy is red
+	buoy.Radius = 4
+	require.NoError(t, client.UpdateHazard(buoy))
+	res, err = client.PositionShip("123", 102, handlers.Position{X: 492, Y: 504})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Red, res.Status)
+
+	require.NoError(t, client.DeleteHazard(buoy.ID))
+	assert.Error(t, client.DeleteHazard(buoy.ID))
+	assert.Error(t, client.UpdateHazard(buoy))
+	res, err = client.PositionShip("123", 103, handlers.Position{X: 493, Y: 504})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Green, res.Status)
+}
+
+func TestGeofences(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	zone := handlers.Geofence{ID: "military", Polygon: []handlers.Position{{X: 1000, Y: 1000}, {X: 1100, Y: 1000}, {X: 1100, Y: 1100}, {X: 1000, Y: 1100}}}
+	require.NoError(t, client.AddGeofence(zone))
+	t.Cleanup(func() { client.DeleteGeofence(zone.ID) })
+	assert.Error(t, client.AddGeofence(zone))
+	assert.Error(t, client.AddGeofence(handlers.Geofence{ID: "line", Polygon: zone.Polygon[:2]}))
+
+	assert.Error(t, client.AddGeofence(handlers.Geofence{ID: "green", Polygon: zone.Polygon, Status: handlers.Green}))
+
+	// red by default
+	geofences, err := client.GetGeofences()
+	require.NoError(t, err)
+	zone.Status = handlers.Red
+	assert.Equal(t, []handlers.Geofence{zone}, geofences)
+
+	_, err = client.PositionShip("123", 100, handlers.Position{X: 900, Y: 1050})
+	require.NoError(t, err)
+	res, err := client.PositionShip("123", 101, handlers.Position{X: 910, Y: 1050})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Yellow, res.Status)
+	assert.Equal(t, []handlers.Conflict{
+		{
+			Kind:          "geofence",
+			ID:            "military",
+			Alert:         "predicted_enter",
+			Status:        handlers.Yellow,
+			Time:          110,
+			Position:      handlers.Position{X: 1000, Y: 1050},
+			OtherPosition: handlers.Position{X: 1000, Y: 1050},
+		},
+	}, res.Conflicts)
+
+	res, err = client.PositionShip("123", 102, handlers.Position{X: 1010, Y: 1050})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Red, res.Status)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, "enter", res.Conflicts[0].Alert)
+
+	res, err = client.PositionShip("123", 103, handlers.Position{X: 1110, Y: 1050})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Green, res.Status)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, "exit", res.Conflicts[0].Alert)
+
+	// ship inside the advisory area is yellow
+	zone.Status = handlers.Yellow
+	require.NoError(t, client.UpdateGeofence(zone))
+	res, err = client.PositionShip("123", 104, handlers.Position{X: 1050, Y: 1050})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Yellow, res.Status)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, "enter", res.Conflicts[0].Alert)
+	assert.Equal(t, handlers.Yellow, res.Conflicts[0].Status)
+
+	require.NoError(t, client.DeleteGeofence(zone.ID))
+	assert.Error(t, client.DeleteGeofence(zone.ID))
+}
+
+func TestProfiles(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 100, Y: 100})
+	require.NoError(t, err)
+	res, err := client.PositionShip("345", 100, handlers.Position{X: 150, Y: 100})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Green, res.Status)
+
+	// tanker domain alone is bigger than the distance
+	require.NoError(t, client.SetProfile("345", handlers.VesselProfile{Type: "tanker", Length: 250, Beam: 40}))
+	assert.Error(t, client.SetProfile("345", handlers.VesselProfile{Length: -1}))
+
+	res, err = client.PositionShip("345", 101, handlers.Position{X: 150, Y: 100})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Red, res.Status)
+}
+
+func TestSpeedAnomalies(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 0, Y: 0})
+	require.NoError(t, err)
+	res, err := client.PositionShip("123", 101, handlers.Position{X: 10, Y: 0})
+	require.NoError(t, err)
+	assert.False(t, res.SpeedAnomaly)
+
+	// jump far beyond max speed, speed is clamped and anomaly kept
+	res, err = client.PositionShip("123", 102, handlers.Position{X: 1010, Y: 0})
+	require.NoError(t, err)
+	assert.True(t, res.SpeedAnomaly)
+	assert.Equal(t, 100, res.Speed)
+
+	ship, err := client.GetShip("123")
+	require.NoError(t, err)
+	assert.Equal(t, []handlers.SpeedAnomaly{{Time: 102, RawSpeed: 1000, Speed: 100, MaxSpeed: 100}}, ship.Anomalies)
+}
+
+func TestShipsAt(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 0, Y: 100})
+	require.NoError(t, err)
+	_, err = client.PositionShip("123", 110, handlers.Position{X: 100, Y: 100})
+	require.NoError(t, err)
+	_, err = client.PositionShip("345", 105, handlers.Position{X: 50, Y: 100})
+	require.NoError(t, err)
+
+	ships, err := client.GetShipsAt(105)
+	require.NoError(t, err)
+	require.Len(t, ships, 2)
+	assert.Equal(t, "123", ships[0].ID)
+	assert.Equal(t, handlers.Position{X: 50, Y: 100}, ships[0].Position)
+	assert.Equal(t, 10, ships[0].Speed)
+	assert.Equal(t, 100, ships[0].LastSeen)
+	assert.Equal(t, handlers.Red, ships[0].Status)
+	assert.Equal(t, "345", ships[1].ID)
+
+	ships, err = client.GetShipsAt(104)
+	require.NoError(t, err)
+	require.Len(t, ships, 1)
+
+	ships, err = client.GetShipsAt(120)
+	require.NoError(t, err)
+	require.Len(t, ships, 2)
+	assert.True(t, ships[0].Extrapolated)
+	assert.Equal(t, handlers.Position{X: 200, Y: 100}, ships[0].Position)
+}
+
+func TestQueryShips(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 1000, Y: 1000})
+	require.NoError(t, err)
+	_, err = client.PositionShip("345", 101, handlers.Position{X: 1000, Y: 1000})
+	require.NoError(t, err)
+	_, err = client.PositionShip("567", 102, handlers.Position{X: 5000, Y: 5000})
+	require.NoError(t, err)
+	_, err = client.PositionShip("789", 103, handlers.Position{X: 9000, Y: 9000})
+	require.NoError(t, err)
+
+	ids := func(ships []handlers.ShipResponse) []string {
+		var ids []string
+		for _, ship := range ships {
+			ids = append(ids, ship.ID)
+		}
+		return ids
+	}
+
+	ships, next, err := client.QueryShips("status=red")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"345"}, ids(ships))
+	assert.Empty(t, next)
+
+	ships, _, err = client.QueryShips("min_x=0&min_y=0&max_x=5000&max_y=5000&seen_from=101")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"345", "567"}, ids(ships))
+
+	ships, next, err = client.QueryShips("sort=-last_time&limit=3")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"789", "567", "345"}, ids(ships))
+	require.NotEmpty(t, next)
+
+	ships, next, err = client.QueryShips("sort=-last_time&limit=3&cursor=" + next)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"123"}, ids(ships))
+	assert.Empty(t, next)
+
+	for _, query := range []string{"sort=name", "status=blue", "limit=-1", "cursor=abc", "min_x=10&max_x=0", "at=100&sort=id"} {
+		_, _, err = client.QueryShips(query)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestShipHistory(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	for time := 100; time < 130; time += 2 {
+		_, err := client.PositionShip("123", time, handlers.Position{X: time, Y: 100})
+		require.NoError(t, err)
+	}
+
+	ship, err := client.GetShip("123")
+	require.NoError(t, err)
+	assert.Len(t, ship.Positions, 15)
+	assert.Nil(t, ship.NextFrom)
+
+	ship, err = client.GetShipHistory("123", "from=110&to=119&limit=3")
+	require.NoError(t, err)
+	assert.Equal(t, []handlers.ShipPosition{
+		{Time: 110, Speed: 1, Position: handlers.Position{X: 110, Y: 100}},
+		{Time: 112, Speed: 1, Position: handlers.Position{X: 112, Y: 100}},
+		{Time: 114, Speed: 1, Position: handlers.Position{X: 114, Y: 100}},
+	}, ship.Positions)
+	require.NotNil(t, ship.NextFrom)
+	assert.Equal(t, 116, *ship.NextFrom)
+
+	ship, err = client.GetShipHistory("123", "from=116&to=119&limit=3")
+	require.NoError(t, err)
+	assert.Len(t, ship.Positions, 2)
+	assert.Nil(t, ship.NextFrom)
+
+	ship, err = client.GetShipHistory("123", "downsample=10")
+	require.NoError(t, err)
+	var times []int
+	for _, pos := range ship.Positions {
+		times = append(times, pos.Time)
+	}
+	assert.Equal(t, []int{100, 110, 120}, times)
+
+	ship, err = client.GetShipHistory("123", "from=200")
+	require.NoError(t, err)
+	assert.Empty(t, ship.Positions)
+
+	for _, query := range []string{"from=120&to=110", "limit=-1", "downsample=x"} {
+		_, err = client.GetShipHistory("123", query)
+		assert.Error(t, err, query)
+	}
+	_, err = client.GetShipHistory("345", "from=100")
+	assert.Error(t, err)
+}
+
+func TestIncidents(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 1000, Y: 1000})
+	require.NoError(t, err)
+	_, err = client.PositionShip("345", 100, handlers.Position{X: 1000, Y: 1000})
+	require.NoError(t, err)
+	_, err = client.PositionShip("345", 101, handlers.Position{X: 1050, Y: 1000})
+	require.NoError(t, err)
+
+	incidents, err := client.Incidents("")
+	require.NoError(t, err)
+	require.Len(t, incidents, 2)
+	assert.Equal(t, handlers.Incident{
+		ShipID: "345",
+		Time:   100,
+		From:   handlers.Green,
+		To:     handlers.Red,
+		Counterpart: &handlers.Conflict{
+			Kind:          "ship",
+			ID:            "123",
+			Status:        handlers.Red,
+			Time:          100,
+			Position:      handlers.Position{X: 1000, Y: 1000},
+			OtherPosition: handlers.Position{X: 1000, Y: 1000},
+		},
+	}, incidents[0])
+	assert.Equal(t, handlers.Incident{ShipID: "345", Time: 101, From: handlers.Red, To: handlers.Green}, incidents[1])
+
+	incidents, err = client.Incidents("id=123&from=100&to=100")
+	require.NoError(t, err)
+	assert.Len(t, incidents, 1)
+
+	incidents, err = client.Incidents("from=102")
+	require.NoError(t, err)
+	assert.Empty(t, incidents)
+
+	_, err = client.Incidents("from=abc")
+	assert.Error(t, err)
+
+	// incident log survives snapshot
+	snapshot, err := client.ExportSnapshot()
+	require.NoError(t, err)
+	require.NoError(t, client.Flush())
+	require.NoError(t, client.ImportSnapshot(snapshot))
+	incidents, err = client.Incidents("")
+	require.NoError(t, err)
+	assert.Len(t, incidents, 2)
+}
+
+func TestPrediction(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 0, Y: 100})
+	require.NoError(t, err)
+	_, err = client.PositionShip("123", 110, handlers.Position{X: 100, Y: 100})
+	require.NoError(t, err)
+
+	prediction, err := client.GetPrediction("123", 60, 30)
+	require.NoError(t, err)
+	assert.Equal(t, handlers.PredictionResponse{
+		ID:    "123",
+		From:  110,
+		To:    170,
+		Speed: 10,
+		Positions: []handlers.ShipPosition{
+			{Time: 110, Speed: 10, Position: handlers.Position{X: 100, Y: 100}},
+			{Time: 140, Speed: 10, Position: handlers.Position{X: 400, Y: 100}},
+			{Time: 170, Speed: 10, Position: handlers.Position{X: 700, Y: 100}},
+		},
+	}, prediction)
+
+	_, err = client.GetPrediction("123", 60, 0)
+	assert.Error(t, err)
+	_, err = client.GetPrediction("345", 60, 5)
+	assert.Error(t, err)
+}
+
+func TestNearby(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 1000, Y: 1000})
+	require.NoError(t, err)
+	_, err = client.PositionShip("345", 100, handlers.Position{X: 1030, Y: 1040})
+	require.NoError(t, err)
+	_, err = client.PositionShip("567", 100, handlers.Position{X: 1000, Y: 1010})
+	require.NoError(t, err)
+	_, err = client.PositionShip("789", 100, handlers.Position{X: 5000, Y: 5000})
+	require.NoError(t, err)
+
+	ships, err := client.GetNearby(1000, 1000, 50)
+	require.NoError(t, err)
+	require.Len(t, ships, 3)
+	assert.Equal(t, handlers.NearbyShipResponse{
+		ID:       "567",
+		Position: handlers.Position{X: 1000, Y: 1010},
+		Distance: 10,
+		LastSeen: 100,
+	}, ships[1])
+	assert.Equal(t, "123", ships[0].ID)
+	assert.Equal(t, "345", ships[2].ID)
+	assert.Equal(t, 50.0, ships[2].Distance)
+
+	ships, err = client.GetNeighbours("123", 2)
+	require.NoError(t, err)
+	require.Len(t, ships, 2)
+	assert.Equal(t, "567", ships[0].ID)
+	assert.Equal(t, "345", ships[1].ID)
+
+	ships, err = client.GetNeighbours("123", 10)
+	require.NoError(t, err)
+	assert.Len(t, ships, 3)
+
+	_, err = client.GetNearby(1000, 1000, 0)
+	assert.Error(t, err)
+	_, err = client.GetNeighbours("000", 2)
+	assert.Error(t, err)
+
+	// nearby is reserved for the route
+	_, err = client.PositionShip("nearby", 100, handlers.Position{X: 9000, Y: 9000})
+	assert.ErrorContains(t, err, "400")
+	results, err := client.PositionShips([]handlers.BatchPositionRequest{
+		{ID: "nearby", PositionShipRequest: handlers.PositionShipRequest{Time: 100, X: 9000, Y: 9000}},
+	}, false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, `ship id "nearby" is reserved`, results[0].Error)
+}
+
+func TestSimulate(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 100, Y: 100})
+	require.NoError(t, err)
+	_, err = client.PositionShip("345", 100, handlers.Position{X: 110, Y: 100})
+	require.NoError(t, err)
+
+	res, err := client.Simulate("345", 101, handlers.Position{X: 100, Y: 100})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Red, res.Status)
+	assert.True(t, res.StatusChanged)
+	assert.Equal(t, 10, res.Speed)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, "123", res.Conflicts[0].ID)
+
+	// history is not changed
+	ship, err := client.GetShip("345")
+	require.NoError(t, err)
+	assert.Len(t, ship.Positions, 1)
+	ships, err := client.GetShips()
+	require.NoError(t, err)
+	for _, ship := range ships {
+		assert.Equal(t, handlers.Green, ship.LastStatus)
+	}
+
+	_, err = client.Simulate("345", 100, handlers.Position{X: 100, Y: 100})
+	assert.Error(t, err)
+}
+
+func TestSnapshot(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 2, Y: 2})
+	require.NoError(t, err)
+	_, err = client.PositionShip("123", 101, handlers.Position{X: 3, Y: 3})
+	require.NoError(t, err)
+	_, err = client.PositionShip("345", 101, handlers.Position{X: 4, Y: 4})
+	require.NoError(t, err)
+
+	ships, err := client.GetShips()
+	require.NoError(t, err)
+	ship, err := client.GetShip("123")
+	require.NoError(t, err)
+
+	snapshot, err := client.ExportSnapshot()
+	require.NoError(t, err)
+
+	require.NoError(t, client.Flush())
+	require.NoError(t, client.ImportSnapshot(snapshot))
+
+	restoredShips, err := client.GetShips()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ships, restoredShips)
+
+	restoredShip, err := client.GetShip("123")
+	require.NoError(t, err)
+	assert.Equal(t, ship, restoredShip)
+
+	// broken snapshot doesn't change anything
+	require.Error(t, client.ImportSnapshot([]byte(`{"version":1000}`)))
+	restoredShips, err = client.GetShips()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ships, restoredShips)
+}
+
+func TestEvents(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.Events(ctx, "min_status=purple")
+	require.Error(t, err)
+
+	all, err := client.Events(ctx, "id=123,345")
+	require.NoError(t, err)
+	red, err := client.Events(ctx, "id=123&id=345&min_status=red")
+	require.NoError(t, err)
+
+	requests := []PositionRequest{
+		{ID: "123", Time: 100, X: 1000, Y: 1000},
+		{ID: "999", Time: 100, X: 5000, Y: 5000},
+		{ID: "345", Time: 101, X: 959, Y: 959},
+		{ID: "345", Time: 102, X: 1000, Y: 1000},
+	}
+	for _, req := range requests {
+		_, err := client.PositionShip(req.ID, req.Time, handlers.Position{X: req.X, Y: req.Y})
+		require.NoError(t, err)
+	}
+
+	next := func(events <-chan handlers.Event) handlers.Event {
+		select {
+		case event := <-events:
+			return event
+		case <-ctx.Done():
+			require.FailNow(t, "event not received")
+			return handlers.Event{}
+		}
+	}
+
+	// 999 is filtered out, ship 123 doesn't know about 345 until it sends new position
+	expected := []handlers.Event{
+		{Kind: "position", ID: "123", Time: 100, Position: handlers.Position{X: 1000, Y: 1000}, Status: handlers.Green, PreviousStatus: handlers.Green},
+		{Kind: "position", ID: "345", Time: 101, Position: handlers.Position{X: 959, Y: 959}, Status: handlers.Green, PreviousStatus: handlers.Green},
+		{Kind: "position", ID: "345", Time: 102, Position: handlers.Position{X: 1000, Y: 1000}, Speed: 57, Status: handlers.Red, PreviousStatus: handlers.Green},
+		{Kind: "status", ID: "345", Time: 102, Position: handlers.Position{X: 1000, Y: 1000}, Speed: 57, Status: handlers.Red, PreviousStatus: handlers.Green},
+	}
+	for _, want := range expected {
+		got := next(all)
+		got.Conflicts = nil
+		assert.Equal(t, want, got)
+	}
+
+	for _, want := range expected[2:] {
+		got := next(red)
+		require.Len(t, got.Conflicts, 1)
+		assert.Equal(t, "123", got.Conflicts[0].ID)
+		got.Conflicts = nil
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	watch, err := client.Watch()
+	require.NoError(t, err)
+	defer watch.Close()
+
+	request := func(req handlers.WatchRequest) handlers.WatchMessage {
+		require.NoError(t, watch.Send(req))
+		msg, err := watch.Receive()
+		require.NoError(t, err)
+		return msg
+	}
+	position := func(id string, time, x, y int) {
+		_, err := client.PositionShip(id, time, handlers.Position{X: x, Y: y})
+		require.NoError(t, err)
+	}
+	receive := func() handlers.Event {
+		msg, err := watch.Receive()
+		require.NoError(t, err)
+		require.Equal(t, handlers.WatchEvent, msg.Type)
+		return *msg.Event
+	}
+
+	msg := request(handlers.WatchRequest{Type: "dance"})
+	assert.Equal(t, handlers.WatchError, msg.Type)
+	msg = request(handlers.WatchRequest{Type: handlers.WatchSubscribe, Area: &handlers.Area{Min: handlers.Position{X: 10}}})
+	assert.Equal(t, handlers.WatchError, msg.Type)
+
+	msg = request(handlers.WatchRequest{
+		Type: handlers.WatchSubscribe,
+		Area: &handlers.Area{Min: handlers.Position{X: 1000, Y: 1000}, Max: handlers.Position{X: 1100, Y: 1100}},
+	})
+	require.Equal(t, handlers.WatchSubscribed, msg.Type)
+
+	position("123", 100, 500, 500)   // outside
+	position("123", 110, 1050, 1050) // inside
+	position("345", 110, 1010, 1090) // inside
+	position("123", 120, 1200, 1050) // left the area
+
+	event := receive()
+	assert.Equal(t, "123", event.ID)
+	assert.Equal(t, handlers.Position{X: 1050, Y: 1050}, event.Position)
+	event = receive()
+	assert.Equal(t, "345", event.ID)
+	event = receive()
+	assert.Equal(t, "123", event.ID)
+	assert.Equal(t, handlers.Position{X: 1200, Y: 1050}, event.Position)
+
+	// change subscription on the fly
+	msg = request(handlers.WatchRequest{Type: handlers.WatchSubscribe, IDs: []string{"123"}})
+	require.Equal(t, handlers.WatchSubscribed, msg.Type)
+
+	position("345", 130, 1010, 1090)
+	position("123", 130, 5000, 5000)
+	event = receive()
+	assert.Equal(t, "123", event.ID)
+	assert.Equal(t, 130, event.Time)
+
+	msg = request(handlers.WatchRequest{Type: handlers.WatchUnsubscribe})
+	require.Equal(t, handlers.WatchUnsubscribed, msg.Type)
+	position("123", 140, 5000, 5000)
+
+	// nothing is sent after unsubscribe, next message is reply to subscribe
+	msg = request(handlers.WatchRequest{Type: handlers.WatchSubscribe, MinStatus: handlers.Red})
+	require.Equal(t, handlers.WatchSubscribed, msg.Type)
+}
+
+func TestPositionBatch(t *testing.T) {
+	client := NewClient(addr, port)
+
+	batch := []handlers.BatchPositionRequest{
+		{ID: "123", PositionShipRequest: handlers.PositionShipRequest{Time: 110, X: 1010, Y: 1000}},
+		{ID: "345", PositionShipRequest: handlers.PositionShipRequest{Time: 105, X: 1010, Y: 1000}},
+		{ID: "123", PositionShipRequest: handlers.PositionShipRequest{Time: 100, X: 1000, Y: 1000}},
+		{ID: "123", PositionShipRequest: handlers.PositionShipRequest{Time: 110, X: 1000, Y: 1000}},
+		{ID: "", PositionShipRequest: handlers.PositionShipRequest{Time: 110, X: 1000, Y: 1000}},
+		{ID: "999", PositionShipRequest: handlers.PositionShipRequest{Time: 0, X: 1000, Y: 1000}},
+	}
+	expected := []handlers.BatchPositionResponse{
+		{ID: "123", Time: 110, X: 1010, Y: 1000, Speed: 1, Status: handlers.Red},
+		{ID: "345", Time: 105, X: 1010, Y: 1000, Speed: 0, Status: handlers.Green},
+		{ID: "123", Time: 100, X: 1000, Y: 1000, Speed: 0, Status: handlers.Green},
+		{ID: "123", Time: 110, X: 1000, Y: 1000, Error: "time must be greater than last position time"},
+		{ID: "", Time: 110, X: 1000, Y: 1000, Error: "ship id can not be empty"},
+		{ID: "999", Time: 0, X: 1000, Y: 1000, Error: "time can not be empty"},
+	}
+
+	client.Flush()
+	results, err := client.PositionShips(batch, false)
+	require.NoError(t, err)
+	require.Len(t, results, len(expected))
+	require.Len(t, results[0].Conflicts, 1)
+	assert.Equal(t, "345", results[0].Conflicts[0].ID)
+	results[0].Conflicts = nil
+	assert.Equal(t, expected, results)
+
+	ship, err := client.GetShip("123")
+	require.NoError(t, err)
+	assert.Len(t, ship.Positions, 2)
+
+	// stream positions line by line, so 345 is not known to the first line and 123 can't go back in time
+	client.Flush()
+	expected[0].Speed, expected[0].Status = 0, handlers.Green
+	expected[1].Status = handlers.Red
+	expected[2] = handlers.BatchPositionResponse{ID: "123", Time: 100, X: 1000, Y: 1000, Error: "time must be greater than last position time"}
+	results, err = client.PositionShips(batch, true)
+	require.NoError(t, err)
+	require.Len(t, results, len(expected))
+	require.Len(t, results[1].Conflicts, 1)
+	assert.Equal(t, "123", results[1].Conflicts[0].ID)
+	results[1].Conflicts = nil
+	assert.Equal(t, expected, results)
+
+	_, err = client.PositionShips(make([]handlers.BatchPositionRequest, 10001), false)
+	assert.ErrorContains(t, err, "413")
+	// body is limited before positions are counted
+	_, err = client.PositionShips([]handlers.BatchPositionRequest{{ID: strings.Repeat("1", 11<<20)}}, false)
+	assert.ErrorContains(t, err, "413")
+
+	// stream is not limited, every line gets a result
+	results, err = client.PositionShips(make([]handlers.BatchPositionRequest, 10001), true)
+	require.NoError(t, err)
+	require.Len(t, results, 10001)
+	assert.Equal(t, "ship id can not be empty", results[10000].Error)
+}
+
+func TestPositionStream(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	// result of every line comes before the next line is sent
+	stream, err := client.StreamPositions()
+	require.NoError(t, err)
+	defer stream.Close()
+
+	result, err := stream.Send(handlers.BatchPositionRequest{ID: "123", PositionShipRequest: handlers.PositionShipRequest{Time: 100, X: 1000, Y: 1000}})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.BatchPositionResponse{ID: "123", Time: 100, X: 1000, Y: 1000, Status: handlers.Green}, result)
+
+	result, err = stream.Send(handlers.BatchPositionRequest{ID: "345", PositionShipRequest: handlers.PositionShipRequest{Time: 100, X: 1000, Y: 1000}})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Red, result.Status)
+	require.Len(t, result.Conflicts, 1)
+	assert.Equal(t, "123", result.Conflicts[0].ID)
+
+	// malformed line doesn't end the stream
+	result, err = stream.SendLine("{")
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Error)
+	result, err = stream.Send(handlers.BatchPositionRequest{ID: "123", PositionShipRequest: handlers.PositionShipRequest{Time: 101, X: 1000, Y: 1000}})
+	require.NoError(t, err)
+	assert.Empty(t, result.Error)
+}
+
+func TestCorrections(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 100, Y: 100})
+	require.NoError(t, err)
+	_, err = client.PositionShip("345", 100, handlers.Position{X: 300, Y: 100})
+	require.NoError(t, err)
+	// glitch puts 123 on collision course with 345
+	res, err := client.PositionShip("123", 101, handlers.Position{X: 290, Y: 100})
+	require.NoError(t, err)
+	require.Equal(t, handlers.Red, res.Status)
+
+	correction, err := client.CorrectPosition("123", 101, "jane", handlers.Position{X: 101, Y: 100})
+	require.NoError(t, err)
+	assert.Equal(t, handlers.Green, correction.Status)
+	assert.True(t, correction.StatusChanged)
+	assert.Equal(t, "jane", correction.Audit.Operator)
+	assert.Equal(t, "correct", correction.Audit.Action)
+	assert.Equal(t, handlers.ShipPosition{Time: 101, Speed: 100, Position: handlers.Position{X: 290, Y: 100}}, correction.Audit.Before)
+	assert.Equal(t, &handlers.ShipPosition{Time: 101, Speed: 1, Position: handlers.Position{X: 101, Y: 100}}, correction.Audit.After)
+
+	_, err = client.PositionShip("123", 102, handlers.Position{X: 102, Y: 100})
+	require.NoError(t, err)
+	correction, err = client.DeletePosition("123", 101, "john")
+	require.NoError(t, err)
+	assert.False(t, correction.StatusChanged)
+	assert.Equal(t, "delete", correction.Audit.Action)
+	assert.Nil(t, correction.Audit.After)
+
+	ship, err := client.GetShip("123")
+	require.NoError(t, err)
+	assert.Equal(t, []handlers.ShipPosition{
+		{Time: 100, Speed: 0, Position: handlers.Position{X: 100, Y: 100}},
+		{Time: 102, Speed: 1, Position: handlers.Position{X: 102, Y: 100}},
+	}, ship.Positions)
+
+	audit, err := client.Audit("123")
+	require.NoError(t, err)
+	require.Len(t, audit, 2)
+	assert.Equal(t, []string{"jane", "john"}, []string{audit[0].Operator, audit[1].Operator})
+
+	// operator is required, position must exist
+	_, err = client.DeletePosition("123", 102, "")
+	assert.Error(t, err)
+	_, err = client.DeletePosition("123", 101, "john")
+	assert.Error(t, err)
+	_, err = client.DeletePosition("unknown", 101, "john")
+	assert.Error(t, err)
+}
+
+func TestDeleteShip(t *testing.T) {
+	client := NewClient(addr, port)
+	client.Flush()
+
+	_, err := client.PositionShip("123", 100, handlers.Position{X: 100, Y: 100})
+	require.NoError(t, err)
+	res, err := client.PositionShip("345", 100, handlers.Position{X: 100, Y: 100})
+	require.NoError(t, err)
+	require.Equal(t, handlers.Red, res.Status)
+
+	require.NoError(t, client.DeleteShip("123"))
+	assert.Error(t, client.DeleteShip("123"))
+
+	_, err = client.GetShip("123")
+	assert.Error(t, err)
+
+	// 345 is no longer in conflict with the removed ship
+	ships, err := client.GetShips()
+	require.NoError(t, err)
+	require.Len(t, ships, 1)
+	assert.Equal(t, "345", ships[0].ID)
+	assert.Equal(t, handlers.Green, ships[0].LastStatus)
+}
+
+func latLon(lat, lon float64) handlers.Position {
+	return handlers.Position{Lat: &lat, Lon: &lon}
+}
+
+func TestGeodetic(t *testing.T) {
+	client := NewClient(addr, geoPort)
+	client.Flush()
+
+	_, err := client.PositionShip("anchored", 100, latLon(59.95, 10.8))
+	require.NoError(t, err)
+
+	// 20 knots to the south, passes anchored ship within prediction window
+	_, err = client.PositionShip("ferry", 100, latLon(59.96, 10.8))
+	require.NoError(t, err)
+	res, err := client.PositionShip("ferry", 160, latLon(59.96-20*traffic.Knot*60/111195, 10.8))
+	require.NoError(t, err)
+
+	assert.Equal(t, 20, res.Speed)
+	assert.Equal(t, handlers.Red, res.Status)
+	assert.InDelta(t, 59.95444, *res.Lat, 1e-5)
+	assert.InDelta(t, 10.8, *res.Lon, 1e-9)
+	assert.InDelta(t, 6054, res.Y, 1)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, "anchored", res.Conflicts[0].ID)
+	assert.InDelta(t, 59.95, *res.Conflicts[0].OtherPosition.Lat, 1e-9)
+	assert.Less(t, res.Conflicts[0].Distance, 1.0)
+
+	ship, err := client.GetShip("ferry")
+	require.NoError(t, err)
+	require.Len(t, ship.Positions, 2)
+	assert.InDelta(t, 59.96, *ship.Positions[0].Position.Lat, 1e-9)
+	assert.Equal(t, 20, ship.Positions[1].Speed)
+
+	// plane coordinates are rejected
+	_, err = client.PositionShip("ferry", 170, handlers.Position{X: 10, Y: 10})
+	assert.Error(t, err)
+	_, err = client.PositionShip("ferry", 170, latLon(91, 10))
+	assert.Error(t, err)
+
+	// and lat/lon by plane server
+	_, err = NewClient(addr, port).PositionShip("ferry", 170, latLon(59.9, 10.7))
+	assert.Error(t, err)
+}
+
 func TestBasic(t *testing.T) {
 	client := NewClient(addr, port)
 	client.Flush()
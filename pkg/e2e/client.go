@@ -1,11 +1,18 @@
 package e2e
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"maritime_traffic/pkg/handlers"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 type Client struct {
@@ -33,7 +40,32 @@ func (c *Client) Flush() error {
 }
 
 func (c *Client) GetShips() ([]handlers.ShipResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/api/v1/ships", c.Address))
+	ships, _, err := c.QueryShips("")
+	return ships, err
+}
+
+// QueryShips returns ships matching the query string and cursor of the next page
+func (c *Client) QueryShips(query string) ([]handlers.ShipResponse, string, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/ships?%s", c.Address, query))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to get ships: %s", resp.Status)
+	}
+
+	var ships []handlers.ShipResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ships); err != nil {
+		return nil, "", err
+	}
+
+	return ships, resp.Header.Get("X-Next-Cursor"), nil
+}
+
+func (c *Client) GetShipsAt(at int) ([]handlers.ShipStateResponse, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/ships?at=%d", c.Address, at))
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +75,53 @@ func (c *Client) GetShips() ([]handlers.ShipResponse, error) {
 		return nil, fmt.Errorf("failed to get ships: %s", resp.Status)
 	}
 
-	var ships []handlers.ShipResponse
+	var ships []handlers.ShipStateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ships); err != nil {
+		return nil, err
+	}
+
+	return ships, nil
+}
+
+func (c *Client) GetPrediction(id string, horizon, step int) (handlers.PredictionResponse, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/ships/%s/prediction?horizon=%d&step=%d", c.Address, id, horizon, step))
+	if err != nil {
+		return handlers.PredictionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return handlers.PredictionResponse{}, fmt.Errorf("failed to get prediction: %s", resp.Status)
+	}
+
+	var prediction handlers.PredictionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&prediction); err != nil {
+		return handlers.PredictionResponse{}, err
+	}
+
+	return prediction, nil
+}
+
+func (c *Client) GetNearby(x, y int, radius float64) ([]handlers.NearbyShipResponse, error) {
+	return c.getNearby(fmt.Sprintf("%s/api/v1/ships/nearby?x=%d&y=%d&radius=%g", c.Address, x, y, radius))
+}
+
+func (c *Client) GetNeighbours(id string, k int) ([]handlers.NearbyShipResponse, error) {
+	return c.getNearby(fmt.Sprintf("%s/api/v1/ships/%s/neighbours?k=%d", c.Address, id, k))
+}
+
+func (c *Client) getNearby(url string) ([]handlers.NearbyShipResponse, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get nearby ships: %s", resp.Status)
+	}
+
+	var ships []handlers.NearbyShipResponse
 	if err := json.NewDecoder(resp.Body).Decode(&ships); err != nil {
 		return nil, err
 	}
@@ -52,7 +130,12 @@ func (c *Client) GetShips() ([]handlers.ShipResponse, error) {
 }
 
 func (c *Client) GetShip(id string) (handlers.GetShipResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/api/v1/ships/%s", c.Address, id))
+	return c.GetShipHistory(id, "")
+}
+
+// GetShipHistory returns part of the ship track selected by the query string
+func (c *Client) GetShipHistory(id, query string) (handlers.GetShipResponse, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/ships/%s?%s", c.Address, id, query))
 	if err != nil {
 		return handlers.GetShipResponse{}, err
 	}
@@ -70,23 +153,56 @@ func (c *Client) GetShip(id string) (handlers.GetShipResponse, error) {
 	return ship, nil
 }
 
+func (c *Client) DeleteShip(id string) error {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/v1/ships/%s", c.Address, id), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to delete ship: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (c *Client) SetProfile(id string, profile handlers.VesselProfile) error {
+	return c.change(http.MethodPut, fmt.Sprintf("%s/api/v1/ships/%s/profile", c.Address, id), profile, http.StatusOK)
+}
+
 func (c *Client) PositionShip(id string, time int, position handlers.Position) (handlers.PositionShipResponse, error) {
+	return c.postPosition(id, "position", time, position, http.StatusCreated)
+}
+
+func (c *Client) Simulate(id string, time int, position handlers.Position) (handlers.PositionShipResponse, error) {
+	return c.postPosition(id, "simulate", time, position, http.StatusOK)
+}
+
+func (c *Client) postPosition(id, action string, time int, position handlers.Position, expectedStatus int) (handlers.PositionShipResponse, error) {
 	reqBody, err := json.Marshal(handlers.PositionShipRequest{
 		Time: time,
 		X:    position.X,
 		Y:    position.Y,
+		Lat:  position.Lat,
+		Lon:  position.Lon,
 	})
 	if err != nil {
 		return handlers.PositionShipResponse{}, err
 	}
 
-	resp, err := http.Post(fmt.Sprintf("%s/api/v1/ships/%s/position", c.Address, id), "application/json", bytes.NewReader(reqBody))
+	resp, err := http.Post(fmt.Sprintf("%s/api/v1/ships/%s/%s", c.Address, id, action), "application/json", bytes.NewReader(reqBody))
 	if err != nil {
 		return handlers.PositionShipResponse{}, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusCreated {
-		return handlers.PositionShipResponse{}, fmt.Errorf("failed to position ship: %s", resp.Status)
+	if resp.StatusCode != expectedStatus {
+		return handlers.PositionShipResponse{}, fmt.Errorf("failed to %s ship: %s", action, resp.Status)
 	}
 
 	var result handlers.PositionShipResponse
@@ -96,3 +212,368 @@ func (c *Client) PositionShip(id string, time int, position handlers.Position) (
 
 	return result, nil
 }
+
+func (c *Client) CorrectPosition(id string, time int, operator string, position handlers.Position) (handlers.CorrectionResponse, error) {
+	body, err := json.Marshal(position)
+	if err != nil {
+		return handlers.CorrectionResponse{}, err
+	}
+
+	return c.changePosition(http.MethodPut, id, time, operator, bytes.NewReader(body))
+}
+
+func (c *Client) DeletePosition(id string, time int, operator string) (handlers.CorrectionResponse, error) {
+	return c.changePosition(http.MethodDelete, id, time, operator, nil)
+}
+
+func (c *Client) changePosition(method, id string, time int, operator string, body io.Reader) (handlers.CorrectionResponse, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/api/v1/ships/%s/positions/%d", c.Address, id, time), body)
+	if err != nil {
+		return handlers.CorrectionResponse{}, err
+	}
+	req.Header.Set(handlers.OperatorHeader, operator)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return handlers.CorrectionResponse{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return handlers.CorrectionResponse{}, fmt.Errorf("failed to change position: %s", resp.Status)
+	}
+
+	var result handlers.CorrectionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return handlers.CorrectionResponse{}, err
+	}
+
+	return result, nil
+}
+
+func (c *Client) Audit(id string) ([]handlers.AuditRecord, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/ships/%s/audit", c.Address, id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get audit: %s", resp.Status)
+	}
+
+	var records []handlers.AuditRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// PositionShips sends batch as JSON array or NDJSON
+func (c *Client) Incidents(query string) ([]handlers.Incident, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/incidents?%s", c.Address, query))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get incidents: %s", resp.Status)
+	}
+
+	var incidents []handlers.Incident
+	if err := json.NewDecoder(resp.Body).Decode(&incidents); err != nil {
+		return nil, err
+	}
+
+	return incidents, nil
+}
+
+func (c *Client) GetHazards() ([]handlers.Hazard, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/hazards", c.Address))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get hazards: %s", resp.Status)
+	}
+
+	var hazards []handlers.Hazard
+	if err := json.NewDecoder(resp.Body).Decode(&hazards); err != nil {
+		return nil, err
+	}
+
+	return hazards, nil
+}
+
+func (c *Client) AddHazard(hazard handlers.Hazard) error {
+	return c.change(http.MethodPost, fmt.Sprintf("%s/api/v1/hazards", c.Address), hazard, http.StatusCreated)
+}
+
+func (c *Client) UpdateHazard(hazard handlers.Hazard) error {
+	return c.change(http.MethodPut, fmt.Sprintf("%s/api/v1/hazards/%s", c.Address, hazard.ID), hazard, http.StatusOK)
+}
+
+func (c *Client) DeleteHazard(id string) error {
+	return c.change(http.MethodDelete, fmt.Sprintf("%s/api/v1/hazards/%s", c.Address, id), nil, http.StatusNoContent)
+}
+
+func (c *Client) change(method, url string, body any, expectedStatus int) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		return fmt.Errorf("failed to %s %s: %s", method, url, resp.Status)
+	}
+
+	return nil
+}
+
+func (c *Client) GetGeofences() ([]handlers.Geofence, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/geofences", c.Address))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get geofences: %s", resp.Status)
+	}
+
+	var geofences []handlers.Geofence
+	if err := json.NewDecoder(resp.Body).Decode(&geofences); err != nil {
+		return nil, err
+	}
+
+	return geofences, nil
+}
+
+func (c *Client) AddGeofence(geofence handlers.Geofence) error {
+	return c.change(http.MethodPost, fmt.Sprintf("%s/api/v1/geofences", c.Address), geofence, http.StatusCreated)
+}
+
+func (c *Client) UpdateGeofence(geofence handlers.Geofence) error {
+	return c.change(http.MethodPut, fmt.Sprintf("%s/api/v1/geofences/%s", c.Address, geofence.ID), geofence, http.StatusOK)
+}
+
+func (c *Client) DeleteGeofence(id string) error {
+	return c.change(http.MethodDelete, fmt.Sprintf("%s/api/v1/geofences/%s", c.Address, id), nil, http.StatusNoContent)
+}
+
+func (c *Client) PositionShips(batch []handlers.BatchPositionRequest, ndjson bool) ([]handlers.BatchPositionResponse, error) {
+	var (
+		body        bytes.Buffer
+		contentType = "application/json"
+	)
+	if ndjson {
+		contentType = "application/x-ndjson"
+		enc := json.NewEncoder(&body)
+		for _, req := range batch {
+			if err := enc.Encode(req); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := json.NewEncoder(&body).Encode(batch); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%s/api/v1/positions:batch", c.Address), contentType, &body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to position ships: %s", resp.Status)
+	}
+
+	var results []handlers.BatchPositionResponse
+	dec := json.NewDecoder(resp.Body)
+	if !ndjson {
+		err := dec.Decode(&results)
+		return results, err
+	}
+	for {
+		var result handlers.BatchPositionResponse
+		err := dec.Decode(&result)
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+}
+
+// PositionStream is NDJSON stream of positions, every position is answered before the next one is sent
+type PositionStream struct {
+	w    *io.PipeWriter
+	resp *http.Response
+	dec  *json.Decoder
+}
+
+func (c *Client) StreamPositions() (*PositionStream, error) {
+	r, w := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/positions:batch", c.Address), r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to stream positions: %s", resp.Status)
+	}
+
+	return &PositionStream{w: w, resp: resp, dec: json.NewDecoder(resp.Body)}, nil
+}
+
+func (s *PositionStream) Send(req handlers.BatchPositionRequest) (handlers.BatchPositionResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return handlers.BatchPositionResponse{}, err
+	}
+	return s.SendLine(string(data))
+}
+
+func (s *PositionStream) SendLine(line string) (handlers.BatchPositionResponse, error) {
+	var result handlers.BatchPositionResponse
+	if _, err := io.WriteString(s.w, line+"\n"); err != nil {
+		return result, err
+	}
+	err := s.dec.Decode(&result)
+	return result, err
+}
+
+func (s *PositionStream) Close() error {
+	_ = s.w.Close()
+	return s.resp.Body.Close()
+}
+
+func (c *Client) ExportSnapshot() ([]byte, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/snapshot", c.Address))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to export snapshot: %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) ImportSnapshot(snapshot []byte) error {
+	resp, err := http.Post(fmt.Sprintf("%s/api/v1/snapshot", c.Address), "application/x-ndjson", bytes.NewReader(snapshot))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to import snapshot: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Events subscribes to event stream, channel is closed when ctx is done or stream ends
+func (c *Client) Events(ctx context.Context, query string) (<-chan handlers.Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/events?%s", c.Address, query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to subscribe to events: %s", resp.Status)
+	}
+
+	events := make(chan handlers.Event)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+
+			var event handlers.Event
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				return
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+type WatchConn struct {
+	conn *websocket.Conn
+}
+
+func (c *Client) Watch() (*WatchConn, error) {
+	url := "ws" + strings.TrimPrefix(c.Address, "http") + "/api/v1/watch"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WatchConn{conn: conn}, nil
+}
+
+func (w *WatchConn) Send(req handlers.WatchRequest) error {
+	return w.conn.WriteJSON(req)
+}
+
+func (w *WatchConn) Receive() (handlers.WatchMessage, error) {
+	var msg handlers.WatchMessage
+	if err := w.conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return msg, err
+	}
+
+	err := w.conn.ReadJSON(&msg)
+	return msg, err
+}
+
+func (w *WatchConn) Close() error {
+	return w.conn.Close()
+}
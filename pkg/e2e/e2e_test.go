@@ -16,12 +16,39 @@ import (
 const port = 7070
 const addr = "http://localhost"
 
-func TestMain(m *testing.M) {
-	t := traffic.NewTraffic()
+// geoPort serves the same API in geodetic mode
+const geoPort = 7071
+
+// geoOrigin is projection origin of geodetic server
+var geoOrigin = traffic.Projection{OriginLat: 59.9, OriginLon: 10.7}
+
+func geoConfig() traffic.Config {
+	return traffic.Config{
+		YellowThreshold:  500,
+		RedThreshold:     100,
+		MaxSpeed:         40 * traffic.Knot,
+		PredictionWindow: 60,
+	}
+}
 
-	server := http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: server.NewAPI(handlers.NewShipsHandler(t)),
+func startServer(port int, cfg traffic.Config, coords handlers.Coordinates) *http.Server {
+	t, err := traffic.NewTraffic(cfg, traffic.NewMemoryStore())
+	if err != nil {
+		slog.Error("failed to create traffic", "error", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%d", port),
+		Handler: server.NewAPI(
+			handlers.NewShipsHandler(t, coords),
+			handlers.NewHazardsHandler(t, coords),
+			handlers.NewGeofencesHandler(t, coords),
+			handlers.NewSnapshotHandler(t),
+			handlers.NewEventsHandler(t, coords),
+			handlers.NewWatchHandler(t, coords),
+			handlers.NewIncidentsHandler(t, coords),
+		),
 	}
 
 	go func() {
@@ -34,12 +61,20 @@ func TestMain(m *testing.M) {
 		}
 	}()
 
+	return server
+}
+
+func TestMain(m *testing.M) {
+	plane := startServer(port, traffic.DefaultConfig(), handlers.Plane)
+	geodetic := startServer(geoPort, geoConfig(), handlers.Geodetic(geoOrigin))
+
 	// Give the server a moment to start
 	time.Sleep(1 * time.Second)
 
 	exitCode := m.Run()
 
-	server.Shutdown(context.Background())
+	plane.Shutdown(context.Background())
+	geodetic.Shutdown(context.Background())
 
 	os.Exit(exitCode)
 }
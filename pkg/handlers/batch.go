@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maritime_traffic/pkg/traffic"
+	"mime"
+	"net/http"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+const (
+	// maxBatchSize keeps single lock acquisition short for other writers
+	maxBatchSize = 10000
+	// maxBatchBytes bounds the body of the batch, a position is much less than a KiB
+	maxBatchBytes = maxBatchSize << 10
+	// maxLineBytes bounds a single position of NDJSON stream
+	maxLineBytes = 64 << 10
+)
+
+var errBatchTooLarge = fmt.Errorf("batch can not have more than %d positions", maxBatchSize)
+
+type (
+	BatchPositionRequest struct {
+		ID string `json:"id"`
+		PositionShipRequest
+	}
+	// BatchPositionResponse is a result of a single position, Error is set if position was rejected
+	BatchPositionResponse struct {
+		ID            string     `json:"id"`
+		Time          int        `json:"time"`
+		X             int        `json:"x"`
+		Y             int        `json:"y"`
+		Lat           *float64   `json:"lat,omitempty"`
+		Lon           *float64   `json:"lon,omitempty"`
+		Speed         int        `json:"speed"`
+		Status        Status     `json:"status,omitempty"`
+		Conflicts     []Conflict `json:"conflicts,omitempty"`
+		Late          bool       `json:"late,omitempty"`
+		StatusChanged bool       `json:"status_changed,omitempty"`
+		SpeedAnomaly  bool       `json:"speed_anomaly,omitempty"`
+		Error         string     `json:"error,omitempty"`
+	}
+)
+
+// PositionShips accepts JSON array or NDJSON(Content-Type: application/x-ndjson) of positions,
+// replies with results in the same order and format as request
+func (h *ShipsHandler) PositionShips(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == ndjsonContentType {
+		h.streamPositions(w, r)
+		return
+	}
+
+	requests, err := decodeJSONBatch(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.Is(err, errBatchTooLarge) || errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, h.positionShips(requests))
+}
+
+// streamPositions positions NDJSON lines one by one as they arrive, result of every line is written
+// and flushed before the next line is read, so the stream has no size limit
+func (h *ShipsHandler) streamPositions(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// request is read while response is written
+	if err := rc.EnableFullDuplex(); err != nil {
+		slog.Error("failed to enable full duplex", "error", err)
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	// client may wait for the status before sending anything
+	if err := rc.Flush(); err != nil {
+		slog.Error("failed to write response", "error", err)
+		return
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, maxLineBytes)
+	enc := json.NewEncoder(w)
+	for line := 0; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var (
+			req      BatchPositionRequest
+			response BatchPositionResponse
+		)
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			response.Error = fmt.Sprintf("line %d: %v", line, err)
+		} else {
+			response = h.positionShips([]BatchPositionRequest{req})[0]
+		}
+
+		if err := enc.Encode(response); err != nil {
+			slog.Error("failed to encode response", "error", err)
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			slog.Error("failed to write response", "error", err)
+			return
+		}
+	}
+
+	// status is already sent, the error is the last line
+	if err := scanner.Err(); err != nil {
+		if err := enc.Encode(BatchPositionResponse{Error: err.Error()}); err != nil {
+			slog.Error("failed to encode response", "error", err)
+		}
+	}
+}
+
+// positionShips positions valid requests as a single batch, responses are in the order of requests
+func (h *ShipsHandler) positionShips(requests []BatchPositionRequest) []BatchPositionResponse {
+	responses := make([]BatchPositionResponse, len(requests))
+	batch := make([]traffic.PositionShip, 0, len(requests))
+	accepted := make([]int, 0, len(requests))
+	for i, req := range requests {
+		responses[i] = BatchPositionResponse{ID: req.ID, Time: req.Time, X: req.X, Y: req.Y, Lat: req.Lat, Lon: req.Lon}
+
+		if err := req.Validate(); err != nil {
+			responses[i].Error = err.Error()
+			continue
+		}
+		point, err := h.coords.Point(req.Position())
+		if err != nil {
+			responses[i].Error = err.Error()
+			continue
+		}
+
+		position := h.coords.Position(point)
+		responses[i].X, responses[i].Y = position.X, position.Y
+		batch = append(batch, traffic.PositionShip{
+			ID:    req.ID,
+			Time:  req.Time,
+			Point: point,
+		})
+		accepted = append(accepted, i)
+	}
+
+	for i, result := range h.ships.PositionShips(batch) {
+		response := &responses[accepted[i]]
+		if result.Err != nil {
+			response.Error = result.Err.Error()
+			continue
+		}
+
+		response.Speed = h.coords.Speed(result.Result.Speed)
+		response.Status = mapStatus(result.Result.Status)
+		response.Conflicts = mapConflicts(result.Result.Conflicts, h.coords)
+		response.Late = result.Result.Late
+		response.StatusChanged = result.Result.StatusChanged
+		response.SpeedAnomaly = result.Result.Anomaly
+	}
+
+	return responses
+}
+
+// decodeJSONBatch decodes array of positions one by one, so too large batch is rejected
+// without reading all of it
+func decodeJSONBatch(r io.Reader) ([]BatchPositionRequest, error) {
+	dec := json.NewDecoder(r)
+	if token, err := dec.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('[') {
+		return nil, fmt.Errorf("batch must be an array of positions")
+	}
+
+	requests := []BatchPositionRequest{}
+	for dec.More() {
+		if len(requests) == maxBatchSize {
+			return nil, errBatchTooLarge
+		}
+
+		var req BatchPositionRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, fmt.Errorf("position %d: %w", len(requests), err)
+		}
+		requests = append(requests, req)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+func (p BatchPositionRequest) Validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("ship id can not be empty")
+	}
+	if p.ID == nearbyShipID {
+		return errReservedShipID
+	}
+
+	return p.PositionShipRequest.Validate()
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"maritime_traffic/pkg/traffic"
+	"math"
+)
+
+type (
+	// Coordinates converts positions and speeds between API and traffic plane
+	Coordinates interface {
+		// Point converts request position to traffic plane
+		Point(p Position) (traffic.Vector, error)
+		// Position converts traffic plane point to response position
+		Position(v traffic.Vector) Position
+		// Speed converts traffic plane speed to response units
+		Speed(speed float64) int
+	}
+
+	plane struct{}
+
+	geodetic struct {
+		projection traffic.Projection
+	}
+)
+
+// Plane is a flat Cartesian plane with integer coordinates, speed in units per second
+var Plane Coordinates = plane{}
+
+// Geodetic positions are WGS84 lat/lon, traffic plane is in metres around projection origin, speed is in knots
+func Geodetic(projection traffic.Projection) Coordinates {
+	return geodetic{projection: projection}
+}
+
+func (plane) Point(p Position) (traffic.Vector, error) {
+	if p.Lat != nil || p.Lon != nil {
+		return traffic.Vector{}, fmt.Errorf("lat/lon are not supported, use x/y")
+	}
+
+	return traffic.Vector{X: float64(p.X), Y: float64(p.Y)}, nil
+}
+
+func (plane) Position(v traffic.Vector) Position {
+	return Position{X: int(v.X), Y: int(v.Y)}
+}
+
+func (plane) Speed(speed float64) int {
+	return int(speed)
+}
+
+func (g geodetic) Point(p Position) (traffic.Vector, error) {
+	if p.Lat == nil || p.Lon == nil {
+		return traffic.Vector{}, fmt.Errorf("lat and lon are required")
+	}
+	if math.Abs(*p.Lat) > 90 || math.Abs(*p.Lon) > 180 {
+		return traffic.Vector{}, fmt.Errorf("lat must be within [-90, 90] and lon within [-180, 180]")
+	}
+
+	return g.projection.Project(*p.Lat, *p.Lon), nil
+}
+
+// Position keeps projected x/y in metres along with lat/lon
+func (g geodetic) Position(v traffic.Vector) Position {
+	lat, lon := g.projection.Unproject(v)
+	return Position{X: int(v.X), Y: int(v.Y), Lat: &lat, Lon: &lon}
+}
+
+func (geodetic) Speed(speed float64) int {
+	return int(math.Round(speed / traffic.Knot))
+}
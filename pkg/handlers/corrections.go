@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	muxTimeVar = "time"
+	// OperatorHeader identifies who changes ship history, it is required for corrections
+	OperatorHeader = "X-Operator"
+)
+
+type (
+	// CorrectionResponse has current status of the ship after its history was changed
+	CorrectionResponse struct {
+		Status        Status      `json:"status"`
+		StatusChanged bool        `json:"status_changed"`
+		Conflicts     []Conflict  `json:"conflicts,omitempty"`
+		Audit         AuditRecord `json:"audit"`
+	}
+	AuditRecord struct {
+		Time     time.Time     `json:"time"`
+		Operator string        `json:"operator"`
+		Action   string        `json:"action"`
+		ShipID   string        `json:"ship_id"`
+		Before   ShipPosition  `json:"before"`
+		After    *ShipPosition `json:"after,omitempty"`
+	}
+)
+
+// CorrectPosition moves existing position of the ship, body is a position in the current coordinates
+func (h *ShipsHandler) CorrectPosition(w http.ResponseWriter, r *http.Request) {
+	shipID, posTime, operator, ok := correctionParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req Position
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	point, err := h.coords.Point(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	correction, err := h.ships.CorrectPosition(traffic.PositionShip{
+		ID:    shipID,
+		Time:  posTime,
+		Point: point,
+	}, operator)
+	h.sendCorrection(w, correction, err)
+}
+
+// DeletePosition removes bogus position from the ship history
+func (h *ShipsHandler) DeletePosition(w http.ResponseWriter, r *http.Request) {
+	shipID, posTime, operator, ok := correctionParams(w, r)
+	if !ok {
+		return
+	}
+
+	correction, err := h.ships.DeletePosition(shipID, posTime, operator)
+	h.sendCorrection(w, correction, err)
+}
+
+// Audit lists changes of the ship history made by operators
+func (h *ShipsHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	shipID := mux.Vars(r)[muxIDVar]
+
+	records := h.ships.Audit(shipID)
+	result := make([]AuditRecord, len(records))
+	for i, record := range records {
+		result[i] = mapAuditRecord(record, h.coords)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, result)
+}
+
+func correctionParams(w http.ResponseWriter, r *http.Request) (string, int, string, bool) {
+	vars := mux.Vars(r)
+	shipID := vars[muxIDVar]
+	if shipID == "" {
+		http.Error(w, "ship id can not be empty", http.StatusBadRequest)
+		return "", 0, "", false
+	}
+
+	posTime, err := strconv.Atoi(vars[muxTimeVar])
+	if err != nil {
+		http.Error(w, "time must be an integer", http.StatusBadRequest)
+		return "", 0, "", false
+	}
+
+	operator := r.Header.Get(OperatorHeader)
+	if operator == "" {
+		http.Error(w, OperatorHeader+" header is required", http.StatusBadRequest)
+		return "", 0, "", false
+	}
+
+	return shipID, posTime, operator, true
+}
+
+func (h *ShipsHandler) sendCorrection(w http.ResponseWriter, correction traffic.Correction, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, traffic.ErrNotFound), errors.Is(err, traffic.ErrPositionNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, CorrectionResponse{
+		Status:        mapStatus(correction.Status),
+		StatusChanged: correction.StatusChanged,
+		Conflicts:     mapConflicts(correction.Conflicts, h.coords),
+		Audit:         mapAuditRecord(correction.Audit, h.coords),
+	})
+}
+
+func mapAuditRecord(record traffic.AuditRecord, coords Coordinates) AuditRecord {
+	result := AuditRecord{
+		Time:     record.Time,
+		Operator: record.Operator,
+		Action:   string(record.Action),
+		ShipID:   record.ShipID,
+		Before:   mapPosition(record.Before.ShipPosition(), coords),
+	}
+	if record.After != nil {
+		after := mapPosition(record.After.ShipPosition(), coords)
+		result.After = &after
+	}
+
+	return result
+}
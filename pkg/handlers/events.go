@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// keepAliveInterval between comments sent to idle stream, so proxies don't close it
+const keepAliveInterval = 15 * time.Second
+
+type (
+	IEvents interface {
+		Subscribe(filter traffic.EventFilter) *traffic.Subscription
+	}
+	EventsHandler struct {
+		events IEvents
+		coords Coordinates
+	}
+	Event struct {
+		Kind           string     `json:"kind"`
+		ID             string     `json:"id"`
+		Time           int        `json:"time"`
+		Position       Position   `json:"position"`
+		Speed          int        `json:"speed"`
+		Status         Status     `json:"status"`
+		PreviousStatus Status     `json:"previous_status"`
+		Conflicts      []Conflict `json:"conflicts,omitempty"`
+		Late           bool       `json:"late,omitempty"`
+	}
+)
+
+func NewEventsHandler(events IEvents, coords Coordinates) *EventsHandler {
+	return &EventsHandler{
+		events: events,
+		coords: coords,
+	}
+}
+
+// Stream sends events as Server-Sent Events until client disconnects,
+// ships are selected with repeated or comma separated `id` and `min_status` query params
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub := h.events.Subscribe(filter)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Error("streaming is not supported", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				// client reconnects and gets fresh state, lost events can't be replayed
+				slog.Warn("event stream closed", "error", sub.Err())
+				return
+			}
+			if err := writeEvent(w, event, h.coords); err != nil {
+				slog.Error("failed to write event", "error", err)
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event traffic.Event, coords Coordinates) error {
+	data, err := json.Marshal(mapEvent(event, coords))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Kind, data)
+	return err
+}
+
+func mapEvent(event traffic.Event, coords Coordinates) Event {
+	return Event{
+		Kind:           string(event.Kind),
+		ID:             event.ID,
+		Time:           event.Time,
+		Position:       coords.Position(event.Position),
+		Speed:          coords.Speed(event.Speed),
+		Status:         mapStatus(event.Status),
+		PreviousStatus: mapStatus(event.PreviousStatus),
+		Conflicts:      mapConflicts(event.Conflicts, coords),
+		Late:           event.Late,
+	}
+}
+
+func parseEventFilter(r *http.Request) (traffic.EventFilter, error) {
+	var filter traffic.EventFilter
+
+	query := r.URL.Query()
+	for _, ids := range query["id"] {
+		for _, id := range strings.Split(ids, ",") {
+			if id != "" {
+				filter.IDs = append(filter.IDs, id)
+			}
+		}
+	}
+
+	if minStatus := query.Get("min_status"); minStatus != "" {
+		status, err := parseStatus(Status(minStatus))
+		if err != nil {
+			return filter, err
+		}
+		filter.MinStatus = status
+	}
+
+	return filter, nil
+}
+
+func parseStatus(status Status) (traffic.Status, error) {
+	switch status {
+	case Green:
+		return traffic.Green, nil
+	case Yellow:
+		return traffic.Yellow, nil
+	case Red:
+		return traffic.Red, nil
+	default:
+		return traffic.Green, fmt.Errorf("unknown status %q", status)
+	}
+}
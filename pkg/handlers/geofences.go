@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type (
+	IGeofences interface {
+		Geofences() []traffic.Geofence
+		Geofence(id string) (traffic.Geofence, error)
+		AddGeofence(g traffic.Geofence) error
+		UpdateGeofence(g traffic.Geofence) error
+		DeleteGeofence(id string) error
+	}
+	GeofencesHandler struct {
+		geofences IGeofences
+		coords    Coordinates
+	}
+	// Geofence is a restricted area, polygon vertices go in order and the last one connects to the first.
+	// Status of the ship inside is yellow or red, red by default
+	Geofence struct {
+		ID      string     `json:"id"`
+		Polygon []Position `json:"polygon"`
+		Status  Status     `json:"status,omitempty"`
+	}
+)
+
+func NewGeofencesHandler(geofences IGeofences, coords Coordinates) *GeofencesHandler {
+	return &GeofencesHandler{
+		geofences: geofences,
+		coords:    coords,
+	}
+}
+
+func (h *GeofencesHandler) GetGeofences(w http.ResponseWriter, r *http.Request) {
+	geofences := h.geofences.Geofences()
+
+	result := make([]Geofence, len(geofences))
+	for i, geofence := range geofences {
+		result[i] = mapGeofence(geofence, h.coords)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, result)
+}
+
+func (h *GeofencesHandler) GetGeofence(w http.ResponseWriter, r *http.Request) {
+	geofence, err := h.geofences.Geofence(mux.Vars(r)[muxIDVar])
+	if err != nil {
+		writeGeofenceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, mapGeofence(geofence, h.coords))
+}
+
+func (h *GeofencesHandler) AddGeofence(w http.ResponseWriter, r *http.Request) {
+	geofence, err := h.decodeGeofence(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.geofences.AddGeofence(geofence); err != nil {
+		writeGeofenceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	sendJSON(w, mapGeofence(geofence, h.coords))
+}
+
+// UpdateGeofence replaces geofence, id in the body is ignored
+func (h *GeofencesHandler) UpdateGeofence(w http.ResponseWriter, r *http.Request) {
+	geofence, err := h.decodeGeofence(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	geofence.ID = mux.Vars(r)[muxIDVar]
+
+	if err := h.geofences.UpdateGeofence(geofence); err != nil {
+		writeGeofenceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, mapGeofence(geofence, h.coords))
+}
+
+func (h *GeofencesHandler) DeleteGeofence(w http.ResponseWriter, r *http.Request) {
+	if err := h.geofences.DeleteGeofence(mux.Vars(r)[muxIDVar]); err != nil {
+		writeGeofenceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *GeofencesHandler) decodeGeofence(r *http.Request) (traffic.Geofence, error) {
+	var req Geofence
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return traffic.Geofence{}, err
+	}
+
+	polygon := make([]traffic.Vector, len(req.Polygon))
+	for i, p := range req.Polygon {
+		point, err := h.coords.Point(p)
+		if err != nil {
+			return traffic.Geofence{}, err
+		}
+		polygon[i] = point
+	}
+
+	status := traffic.Red
+	if req.Status != "" {
+		var err error
+		if status, err = parseStatus(req.Status); err != nil {
+			return traffic.Geofence{}, err
+		}
+	}
+
+	return traffic.Geofence{ID: req.ID, Polygon: polygon, Status: status}, nil
+}
+
+func writeGeofenceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, traffic.ErrInvalidGeofence):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, traffic.ErrGeofenceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, traffic.ErrGeofenceExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func mapGeofence(g traffic.Geofence, coords Coordinates) Geofence {
+	polygon := make([]Position, len(g.Polygon))
+	for i, v := range g.Polygon {
+		polygon[i] = coords.Position(v)
+	}
+
+	return Geofence{ID: g.ID, Polygon: polygon, Status: mapStatus(g.Status)}
+}
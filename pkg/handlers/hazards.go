@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type (
+	IHazards interface {
+		Hazards() []traffic.Hazard
+		Hazard(id string) (traffic.Hazard, error)
+		AddHazard(h traffic.Hazard) error
+		UpdateHazard(h traffic.Hazard) error
+		DeleteHazard(id string) error
+	}
+	HazardsHandler struct {
+		hazards IHazards
+		coords  Coordinates
+	}
+	// Hazard is a point when radius is zero or a circle, red and yellow radii are measured from its edge
+	Hazard struct {
+		ID           string   `json:"id"`
+		Position     Position `json:"position"`
+		Radius       float64  `json:"radius"`
+		RedRadius    float64  `json:"red_radius"`
+		YellowRadius float64  `json:"yellow_radius"`
+	}
+)
+
+func NewHazardsHandler(hazards IHazards, coords Coordinates) *HazardsHandler {
+	return &HazardsHandler{
+		hazards: hazards,
+		coords:  coords,
+	}
+}
+
+func (h *HazardsHandler) GetHazards(w http.ResponseWriter, r *http.Request) {
+	hazards := h.hazards.Hazards()
+
+	result := make([]Hazard, len(hazards))
+	for i, hazard := range hazards {
+		result[i] = mapHazard(hazard, h.coords)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, result)
+}
+
+func (h *HazardsHandler) GetHazard(w http.ResponseWriter, r *http.Request) {
+	hazard, err := h.hazards.Hazard(mux.Vars(r)[muxIDVar])
+	if err != nil {
+		writeHazardError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, mapHazard(hazard, h.coords))
+}
+
+func (h *HazardsHandler) AddHazard(w http.ResponseWriter, r *http.Request) {
+	hazard, err := h.decodeHazard(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.hazards.AddHazard(hazard); err != nil {
+		writeHazardError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	sendJSON(w, mapHazard(hazard, h.coords))
+}
+
+// UpdateHazard replaces hazard, id in the body is ignored
+func (h *HazardsHandler) UpdateHazard(w http.ResponseWriter, r *http.Request) {
+	hazard, err := h.decodeHazard(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hazard.ID = mux.Vars(r)[muxIDVar]
+
+	if err := h.hazards.UpdateHazard(hazard); err != nil {
+		writeHazardError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, mapHazard(hazard, h.coords))
+}
+
+func (h *HazardsHandler) DeleteHazard(w http.ResponseWriter, r *http.Request) {
+	if err := h.hazards.DeleteHazard(mux.Vars(r)[muxIDVar]); err != nil {
+		writeHazardError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *HazardsHandler) decodeHazard(r *http.Request) (traffic.Hazard, error) {
+	var req Hazard
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return traffic.Hazard{}, err
+	}
+
+	point, err := h.coords.Point(req.Position)
+	if err != nil {
+		return traffic.Hazard{}, err
+	}
+
+	return traffic.Hazard{
+		ID:           req.ID,
+		Position:     point,
+		Radius:       req.Radius,
+		RedRadius:    req.RedRadius,
+		YellowRadius: req.YellowRadius,
+	}, nil
+}
+
+func writeHazardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, traffic.ErrInvalidHazard):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, traffic.ErrHazardNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, traffic.ErrHazardExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func mapHazard(h traffic.Hazard, coords Coordinates) Hazard {
+	return Hazard{
+		ID:           h.ID,
+		Position:     coords.Position(h.Position),
+		Radius:       h.Radius,
+		RedRadius:    h.RedRadius,
+		YellowRadius: h.YellowRadius,
+	}
+}
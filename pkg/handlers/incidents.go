@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type (
+	IIncidents interface {
+		Incidents(q traffic.IncidentQuery) []traffic.Incident
+	}
+	IncidentsHandler struct {
+		incidents IIncidents
+		coords    Coordinates
+	}
+	// Incident is a status transition of the ship, counterpart is the closest conflict with the new status
+	Incident struct {
+		ShipID      string    `json:"ship_id"`
+		Time        int       `json:"time"`
+		From        Status    `json:"from"`
+		To          Status    `json:"to"`
+		Counterpart *Conflict `json:"counterpart,omitempty"`
+	}
+)
+
+func NewIncidentsHandler(incidents IIncidents, coords Coordinates) *IncidentsHandler {
+	return &IncidentsHandler{
+		incidents: incidents,
+		coords:    coords,
+	}
+}
+
+// GetIncidents lists status transitions in the order they happened,
+// selected with repeated or comma separated `id` and `from`/`to` time query params
+func (h *IncidentsHandler) GetIncidents(w http.ResponseWriter, r *http.Request) {
+	var q traffic.IncidentQuery
+	for _, ids := range r.URL.Query()["id"] {
+		for _, id := range strings.Split(ids, ",") {
+			if id != "" {
+				q.ShipIDs = append(q.ShipIDs, id)
+			}
+		}
+	}
+
+	var err error
+	if q.From, err = queryParam(r, "from", strconv.Atoi); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.To, err = queryParam(r, "to", strconv.Atoi); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	incidents := h.incidents.Incidents(q)
+	result := make([]Incident, len(incidents))
+	for i, incident := range incidents {
+		result[i] = Incident{
+			ShipID: incident.ShipID,
+			Time:   incident.Time,
+			From:   mapStatus(incident.From),
+			To:     mapStatus(incident.To),
+		}
+		if incident.Counterpart != nil {
+			counterpart := mapConflict(*incident.Counterpart, h.coords)
+			result[i].Counterpart = &counterpart
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, result)
+}
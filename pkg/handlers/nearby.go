@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// NearbyShipResponse is a ship distance away from the query point, at its last known position or at requested time
+type NearbyShipResponse struct {
+	ID       string   `json:"id"`
+	Position Position `json:"position"`
+	Speed    int      `json:"speed"`
+	Distance float64  `json:"distance"`
+	LastSeen int      `json:"last_seen"`
+}
+
+const defaultNeighbours = 5
+
+// nearbyShipID is taken by the nearby route under /ships, so positions of such ship are rejected
+const nearbyShipID = "nearby"
+
+var errReservedShipID = fmt.Errorf("ship id %q is reserved", nearbyShipID)
+
+// Nearby returns ships within "radius" of x/y(lat/lon in geodetic mode) ordered by distance,
+// positions are at time "at" when it is set
+func (h *ShipsHandler) Nearby(w http.ResponseWriter, r *http.Request) {
+	point, err := queryPoint(r, h.coords, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	radius, err := queryParam(r, "radius", parseFloat)
+	if err == nil && radius == nil {
+		err = errors.New("radius is required")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	at, err := queryParam(r, "at", strconv.Atoi)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ships, err := h.ships.Nearby(point, *radius, at)
+	if err != nil {
+		writeNearbyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, mapNearbyShips(ships, h.coords))
+}
+
+// Neighbours returns "k"(default 5) ships closest to the ship, positions are at time "at" when it is set
+func (h *ShipsHandler) Neighbours(w http.ResponseWriter, r *http.Request) {
+	k, err := queryInt(r, "k", defaultNeighbours)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	at, err := queryParam(r, "at", strconv.Atoi)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ships, err := h.ships.Neighbours(mux.Vars(r)[muxIDVar], k, at)
+	if err != nil {
+		writeNearbyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, mapNearbyShips(ships, h.coords))
+}
+
+func writeNearbyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, traffic.ErrInvalidNearby):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, traffic.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func mapNearbyShips(ships []traffic.NearbyShip, coords Coordinates) []NearbyShipResponse {
+	result := make([]NearbyShipResponse, len(ships))
+	for i, ship := range ships {
+		result[i] = NearbyShipResponse{
+			ID:       ship.ID,
+			Position: coords.Position(ship.Position),
+			Speed:    coords.Speed(ship.Speed.Magnitude()),
+			Distance: ship.Distance,
+			LastSeen: ship.LastSeen,
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// VesselProfile has dimensions in plane units, metres in geodetic mode.
+// Safety radius overrides the domain derived from dimensions, half of the largest one
+type VesselProfile struct {
+	Type         string  `json:"type,omitempty"`
+	Length       float64 `json:"length"`
+	Beam         float64 `json:"beam"`
+	SafetyRadius float64 `json:"safety_radius"`
+	Domain       float64 `json:"domain"`
+}
+
+func (h *ShipsHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.ships.Profile(mux.Vars(r)[muxIDVar])
+	if err != nil {
+		switch {
+		case errors.Is(err, traffic.ErrProfileNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, mapProfile(profile))
+}
+
+// SetProfile adds or replaces vessel profile, ship doesn't have to have positions yet
+func (h *ShipsHandler) SetProfile(w http.ResponseWriter, r *http.Request) {
+	shipID := mux.Vars(r)[muxIDVar]
+	if shipID == "" {
+		http.Error(w, "ship id can not be empty", http.StatusBadRequest)
+		return
+	}
+
+	var req VesselProfile
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile := traffic.VesselProfile{
+		Type:         req.Type,
+		Length:       req.Length,
+		Beam:         req.Beam,
+		SafetyRadius: req.SafetyRadius,
+	}
+	if err := h.ships.SetProfile(shipID, profile); err != nil {
+		switch {
+		case errors.Is(err, traffic.ErrInvalidProfile):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, mapProfile(profile))
+}
+
+func mapProfile(p traffic.VesselProfile) VesselProfile {
+	return VesselProfile{
+		Type:         p.Type,
+		Length:       p.Length,
+		Beam:         p.Beam,
+		SafetyRadius: p.SafetyRadius,
+		Domain:       p.Domain(),
+	}
+}
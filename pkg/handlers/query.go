@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"strconv"
+)
+
+// queryParam parses query parameter, nil if it is not set
+func queryParam[T any](r *http.Request, name string, parse func(string) (T, error)) (*T, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	v, err := parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return &v, nil
+}
+
+// queryInt returns integer query parameter, def if it is not set
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v, err := queryParam(r, name, strconv.Atoi)
+	if err != nil || v == nil {
+		return def, err
+	}
+
+	return *v, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+// queryPoint reads prefixed x/y or lat/lon query parameters
+func queryPoint(r *http.Request, coords Coordinates, prefix string) (traffic.Vector, error) {
+	var p Position
+	var err error
+	if p.X, err = queryInt(r, prefix+"x", 0); err != nil {
+		return traffic.Vector{}, err
+	}
+	if p.Y, err = queryInt(r, prefix+"y", 0); err != nil {
+		return traffic.Vector{}, err
+	}
+	if p.Lat, err = queryParam(r, prefix+"lat", parseFloat); err != nil {
+		return traffic.Vector{}, err
+	}
+	if p.Lon, err = queryParam(r, prefix+"lon", parseFloat); err != nil {
+		return traffic.Vector{}, err
+	}
+
+	return coords.Point(p)
+}
+
+// queryHas tells whether any of the query parameters is set
+func queryHas(r *http.Request, names ...string) bool {
+	query := r.URL.Query()
+	for _, name := range names {
+		if query.Has(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"maritime_traffic/pkg/traffic"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 )
@@ -21,13 +23,26 @@ const (
 type (
 	Status string
 	IShips interface {
-		GetShips() ([]traffic.Ship, error)
-		GetShipPositions(id string) ([]traffic.ShipPosition, error)
+		GetShips(q traffic.ShipsQuery) (traffic.ShipsPage, error)
+		ShipsAt(time int) []traffic.ShipState
+		Predict(id string, horizon, step int) (traffic.Prediction, error)
+		Nearby(p traffic.Vector, radius float64, at *int) ([]traffic.NearbyShip, error)
+		Neighbours(id string, k int, at *int) ([]traffic.NearbyShip, error)
+		ShipHistory(id string, q traffic.HistoryQuery) (traffic.Track, error)
 		PositionShip(ps traffic.PositionShip) (traffic.PositionResult, error)
-		Flush()
+		Simulate(ps traffic.PositionShip) (traffic.PositionResult, error)
+		PositionShips(batch []traffic.PositionShip) []traffic.BatchResult
+		CorrectPosition(ps traffic.PositionShip, operator string) (traffic.Correction, error)
+		DeletePosition(id string, time int, operator string) (traffic.Correction, error)
+		Audit(id string) []traffic.AuditRecord
+		DeleteShip(id string) error
+		Profile(id string) (traffic.VesselProfile, error)
+		SetProfile(id string, p traffic.VesselProfile) error
+		Flush() error
 	}
 	ShipsHandler struct {
-		ships IShips
+		ships  IShips
+		coords Coordinates
 	}
 	ShipResponse struct {
 		ID           string   `json:"id"`
@@ -36,71 +51,180 @@ type (
 		LastSpeed    int      `json:"last_speed"`
 		LastPosition Position `json:"last_position"`
 	}
+	// ShipStateResponse is the ship at requested time, position is interpolated between fixes
+	// or extrapolated from the last one when extrapolated is set
+	ShipStateResponse struct {
+		ID           string     `json:"id"`
+		Time         int        `json:"time"`
+		Position     Position   `json:"position"`
+		Speed        int        `json:"speed"`
+		Status       Status     `json:"status"`
+		Conflicts    []Conflict `json:"conflicts,omitempty"`
+		LastSeen     int        `json:"last_seen"`
+		Extrapolated bool       `json:"extrapolated,omitempty"`
+	}
+	// PositionShipRequest has x/y in plane mode and lat/lon in geodetic mode
 	PositionShipRequest struct {
-		Time int `json:"time"`
-		X    int `json:"x"`
-		Y    int `json:"y"`
+		Time int      `json:"time"`
+		X    int      `json:"x"`
+		Y    int      `json:"y"`
+		Lat  *float64 `json:"lat,omitempty"`
+		Lon  *float64 `json:"lon,omitempty"`
 	}
 	PositionShipResponse struct {
-		Time   int    `json:"time"`
-		X      int    `json:"x"`
-		Y      int    `json:"y"`
-		Speed  int    `json:"speed"`
-		Status Status `json:"status"`
+		Time      int        `json:"time"`
+		X         int        `json:"x"`
+		Y         int        `json:"y"`
+		Lat       *float64   `json:"lat,omitempty"`
+		Lon       *float64   `json:"lon,omitempty"`
+		Speed     int        `json:"speed"`
+		Status    Status     `json:"status"`
+		Conflicts []Conflict `json:"conflicts,omitempty"`
+		// Late position was inserted into history, status is the current status of the ship
+		Late          bool `json:"late,omitempty"`
+		StatusChanged bool `json:"status_changed,omitempty"`
+		// SpeedAnomaly position implied speed above the limit of the vessel class, speed is clamped
+		SpeedAnomaly bool `json:"speed_anomaly,omitempty"`
+	}
+	// Conflict is a reason of the status, geofence conflicts have alert: enter, exit, inside or predicted_enter
+	Conflict struct {
+		Kind          string   `json:"kind"`
+		ID            string   `json:"id"`
+		Alert         string   `json:"alert,omitempty"`
+		Status        Status   `json:"status"`
+		Distance      float64  `json:"distance"`
+		Time          float64  `json:"time"`
+		Position      Position `json:"position"`
+		OtherPosition Position `json:"other_position"`
 	}
+	// Position is x/y on the plane, in geodetic mode x/y are metres from projection origin and lat/lon are set
 	Position struct {
-		X int `json:"x"`
-		Y int `json:"y"`
+		X   int      `json:"x"`
+		Y   int      `json:"y"`
+		Lat *float64 `json:"lat,omitempty"`
+		Lon *float64 `json:"lon,omitempty"`
 	}
 	ShipPosition struct {
 		Time     int      `json:"time"`
 		Speed    int      `json:"speed"`
 		Position Position `json:"position"`
 	}
+	// SpeedAnomaly is a fix which implied impossible speed, raw speed is measured from the previous fix
+	SpeedAnomaly struct {
+		Time     int `json:"time"`
+		RawSpeed int `json:"raw_speed"`
+		Speed    int `json:"speed"`
+		MaxSpeed int `json:"max_speed"`
+	}
+	// PredictionResponse is the straight trajectory from the last position used for collision evaluation
+	PredictionResponse struct {
+		ID        string         `json:"id"`
+		From      int            `json:"from"`
+		To        int            `json:"to"`
+		Speed     int            `json:"speed"`
+		Positions []ShipPosition `json:"positions"`
+	}
 	GetShipResponse struct {
 		ID        string         `json:"id"`
 		Positions []ShipPosition `json:"positions"`
+		Anomalies []SpeedAnomaly `json:"anomalies,omitempty"`
+		// NextFrom is "from" for the rest of the track when it was cut by "limit"
+		NextFrom *int `json:"next_from,omitempty"`
 	}
 )
 
-func NewShipsHandler(ships IShips) *ShipsHandler {
+func NewShipsHandler(ships IShips, coords Coordinates) *ShipsHandler {
 	return &ShipsHandler{
-		ships: ships,
+		ships:  ships,
+		coords: coords,
 	}
 }
 
 func (h *ShipsHandler) Flush(w http.ResponseWriter, r *http.Request) {
-	h.ships.Flush()
+	if err := h.ships.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetShips returns last known state of ships matching query parameters, or state of every ship
+// at the time from "at" query parameter
 func (h *ShipsHandler) GetShips(w http.ResponseWriter, r *http.Request) {
-	ships, err := h.ships.GetShips()
+	at, err := queryParam(r, "at", strconv.Atoi)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if at != nil {
+		if queryHas(r, shipsQueryParams...) {
+			http.Error(w, "at can't be combined with filters, sort and pagination", http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		sendJSON(w, mapShipStates(h.ships.ShipsAt(*at), h.coords))
+		return
+	}
+
+	q, err := h.parseShipsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	page, err := h.ships.GetShips(q)
+	if err != nil {
+		if errors.Is(err, traffic.ErrInvalidShipsQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if page.Next != "" {
+		w.Header().Set(nextCursorHeader, page.Next)
+	}
 	w.WriteHeader(http.StatusOK)
-	sendJSON(w, mapShips(ships))
+	sendJSON(w, mapShips(page.Ships, h.coords))
 }
 
-func mapShips(ships []traffic.Ship) []ShipResponse {
+func mapShips(ships []traffic.Ship, coords Coordinates) []ShipResponse {
 	result := make([]ShipResponse, len(ships))
 	for i, ship := range ships {
 		result[i] = ShipResponse{
 			ID:           ship.ID,
 			LastSeen:     ship.LastSeen,
 			LastStatus:   mapStatus(ship.LastStatus),
-			LastSpeed:    int(ship.LastSpeed),
-			LastPosition: Position{X: int(ship.LastPosition.X), Y: int(ship.LastPosition.Y)},
+			LastSpeed:    coords.Speed(ship.LastSpeed),
+			LastPosition: coords.Position(ship.LastPosition),
 		}
 	}
 
 	return result
 }
 
+func mapShipStates(states []traffic.ShipState, coords Coordinates) []ShipStateResponse {
+	result := make([]ShipStateResponse, len(states))
+	for i, state := range states {
+		result[i] = ShipStateResponse{
+			ID:           state.ID,
+			Time:         state.Time,
+			Position:     coords.Position(state.Position),
+			Speed:        coords.Speed(state.Speed.Magnitude()),
+			Status:       mapStatus(state.Status),
+			Conflicts:    mapConflicts(state.Conflicts, coords),
+			LastSeen:     state.LastSeen,
+			Extrapolated: state.Extrapolated,
+		}
+	}
+
+	return result
+}
+
+// GetShip streams track of the ship, part of it with "from", "to", "limit" and "downsample" query parameters
 func (h *ShipsHandler) GetShip(w http.ResponseWriter, r *http.Request) {
 	shipID, ok := mux.Vars(r)[muxIDVar]
 	if !ok {
@@ -108,10 +232,69 @@ func (h *ShipsHandler) GetShip(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	positions, err := h.ships.GetShipPositions(shipID)
+	q, err := parseHistoryQuery(r)
 	if err != nil {
-		switch err {
-		case traffic.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	track, err := h.ships.ShipHistory(shipID, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, traffic.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, traffic.ErrInvalidHistoryQuery):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := h.writeTrack(w, shipID, track); err != nil {
+		slog.Error("failed to stream ship track", "id", shipID, "error", err)
+	}
+}
+
+func mapAnomalies(anomalies []traffic.SpeedAnomaly, coords Coordinates) []SpeedAnomaly {
+	if len(anomalies) == 0 {
+		return nil
+	}
+
+	result := make([]SpeedAnomaly, len(anomalies))
+	for i, a := range anomalies {
+		result[i] = SpeedAnomaly{
+			Time:     a.Time,
+			RawSpeed: coords.Speed(a.RawSpeed),
+			Speed:    coords.Speed(a.Speed),
+			MaxSpeed: coords.Speed(a.MaxSpeed),
+		}
+	}
+
+	return result
+}
+
+// GetPrediction returns positions every "step" seconds(default 1) within "horizon"(default prediction window)
+func (h *ShipsHandler) GetPrediction(w http.ResponseWriter, r *http.Request) {
+	horizon, err := queryInt(r, "horizon", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	step, err := queryInt(r, "step", 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prediction, err := h.ships.Predict(mux.Vars(r)[muxIDVar], horizon, step)
+	if err != nil {
+		switch {
+		case errors.Is(err, traffic.ErrInvalidPrediction):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, traffic.ErrNotFound):
 			http.Error(w, err.Error(), http.StatusNotFound)
 		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -120,75 +303,174 @@ func (h *ShipsHandler) GetShip(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-	sendJSON(w, GetShipResponse{
-		ID:        shipID,
-		Positions: mapPositions(positions),
+	sendJSON(w, PredictionResponse{
+		ID:        prediction.ID,
+		From:      prediction.From,
+		To:        prediction.To,
+		Speed:     h.coords.Speed(prediction.Speed.Magnitude()),
+		Positions: mapPositions(prediction.Positions, h.coords),
 	})
 }
 
-func mapPositions(positions []traffic.ShipPosition) []ShipPosition {
+// DeleteShip removes the ship with its whole history
+func (h *ShipsHandler) DeleteShip(w http.ResponseWriter, r *http.Request) {
+	shipID := mux.Vars(r)[muxIDVar]
+
+	if err := h.ships.DeleteShip(shipID); err != nil {
+		switch err {
+		case traffic.ErrNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func mapPositions(positions []traffic.ShipPosition, coords Coordinates) []ShipPosition {
 	result := make([]ShipPosition, len(positions))
 	for i, pos := range positions {
-		result[i] = ShipPosition{
-			Time:     pos.Time,
-			Speed:    int(pos.Speed.Magnitude()),
-			Position: Position{X: int(pos.Position.X), Y: int(pos.Position.Y)},
-		}
+		result[i] = mapPosition(pos, coords)
 	}
 
 	return result
 }
 
+func mapPosition(pos traffic.ShipPosition, coords Coordinates) ShipPosition {
+	return ShipPosition{
+		Time:     pos.Time,
+		Speed:    coords.Speed(pos.Speed.Magnitude()),
+		Position: coords.Position(pos.Position),
+	}
+}
+
 func (h *ShipsHandler) PositionShip(w http.ResponseWriter, r *http.Request) {
+	ps, ok := h.decodePosition(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.ships.PositionShip(ps)
+	if err != nil {
+		writePositionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	sendJSON(w, h.mapPositionResult(ps, result))
+}
+
+// Simulate evaluates position the same way as PositionShip without recording it
+func (h *ShipsHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	ps, ok := h.decodePosition(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.ships.Simulate(ps)
+	if err != nil {
+		writePositionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	sendJSON(w, h.mapPositionResult(ps, result))
+}
+
+// decodePosition reads position of the ship from the request, replies with 400 if it is invalid
+func (h *ShipsHandler) decodePosition(w http.ResponseWriter, r *http.Request) (traffic.PositionShip, bool) {
 	shipID, ok := mux.Vars(r)[muxIDVar]
 	if !ok {
 		http.Error(w, "ship id must be provided", http.StatusBadRequest)
-		return
+		return traffic.PositionShip{}, false
 	}
 	if shipID == "" {
 		http.Error(w, "ship id can not be empty", http.StatusBadRequest)
-		return
+		return traffic.PositionShip{}, false
+	}
+	if shipID == nearbyShipID {
+		http.Error(w, errReservedShipID.Error(), http.StatusBadRequest)
+		return traffic.PositionShip{}, false
 	}
 
 	var req PositionShipRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return traffic.PositionShip{}, false
 	}
 	if err := req.Validate(); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return traffic.PositionShip{}, false
 	}
 
-	result, err := h.ships.PositionShip(traffic.PositionShip{
-		ID:   shipID,
-		Time: req.Time,
-		Point: traffic.Vector{
-			X: float64(req.X),
-			Y: float64(req.Y),
-		},
-	})
+	point, err := h.coords.Point(req.Position())
 	if err != nil {
-		switch err {
-		case traffic.ErrTimeInPast, traffic.ErrTimeInFuture:
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			sendJSON(w, map[string]string{"error": "time out of range"})
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return traffic.PositionShip{}, false
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	sendJSON(w, PositionShipResponse{
-		Time:   req.Time,
-		X:      req.X,
-		Y:      req.Y,
-		Speed:  int(result.Speed),
-		Status: mapStatus(result.Status),
-	})
+	return traffic.PositionShip{
+		ID:    shipID,
+		Time:  req.Time,
+		Point: point,
+	}, true
+}
+
+func writePositionError(w http.ResponseWriter, err error) {
+	switch err {
+	case traffic.ErrTimeInPast, traffic.ErrTimeInFuture, traffic.ErrDuplicateTime:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		sendJSON(w, map[string]string{"error": "time out of range"})
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *ShipsHandler) mapPositionResult(ps traffic.PositionShip, result traffic.PositionResult) PositionShipResponse {
+	position := h.coords.Position(ps.Point)
+	return PositionShipResponse{
+		Time:          ps.Time,
+		X:             position.X,
+		Y:             position.Y,
+		Lat:           position.Lat,
+		Lon:           position.Lon,
+		Speed:         h.coords.Speed(result.Speed),
+		Status:        mapStatus(result.Status),
+		Conflicts:     mapConflicts(result.Conflicts, h.coords),
+		Late:          result.Late,
+		StatusChanged: result.StatusChanged,
+		SpeedAnomaly:  result.Anomaly,
+	}
+}
+
+func mapConflicts(conflicts []traffic.Conflict, coords Coordinates) []Conflict {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	result := make([]Conflict, len(conflicts))
+	for i, conflict := range conflicts {
+		result[i] = mapConflict(conflict, coords)
+	}
+
+	return result
+}
+
+func mapConflict(conflict traffic.Conflict, coords Coordinates) Conflict {
+	return Conflict{
+		Kind:          string(conflict.Kind),
+		ID:            conflict.ID,
+		Alert:         string(conflict.Alert),
+		Status:        mapStatus(conflict.Status),
+		Distance:      conflict.Distance,
+		Time:          conflict.Time,
+		Position:      coords.Position(conflict.Position),
+		OtherPosition: coords.Position(conflict.OtherPosition),
+	}
 }
 
 func mapStatus(status traffic.Status) Status {
@@ -205,6 +487,10 @@ func mapStatus(status traffic.Status) Status {
 	}
 }
 
+func (p PositionShipRequest) Position() Position {
+	return Position{X: p.X, Y: p.Y, Lat: p.Lat, Lon: p.Lon}
+}
+
 func (p PositionShipRequest) Validate() error {
 	if p.Time == 0 {
 		return fmt.Errorf("time can not be empty")
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// nextCursorHeader has cursor of the next page of ships, it is not set on the last page
+	nextCursorHeader = "X-Next-Cursor"
+)
+
+var (
+	shipsAreaParams = []string{
+		"min_x", "min_y", "max_x", "max_y",
+		"min_lat", "min_lon", "max_lat", "max_lon",
+	}
+	shipsQueryParams = append([]string{
+		"status", "seen_from", "seen_to", "sort", "limit", "cursor",
+	}, shipsAreaParams...)
+)
+
+// parseShipsQuery reads filters, sort and pagination of ships list:
+//
+//	status=red,yellow - repeated or comma separated
+//	min_x, min_y, max_x, max_y(min_lat, min_lon, max_lat, max_lon in geodetic mode) - bounding box
+//	seen_from, seen_to - last position time range
+//	sort=id|last_time|speed, "-" prefix for descending order
+//	limit, cursor - page size(traffic.DefaultShipsLimit by default) and X-Next-Cursor of the previous page
+func (h *ShipsHandler) parseShipsQuery(r *http.Request) (traffic.ShipsQuery, error) {
+	var q traffic.ShipsQuery
+	query := r.URL.Query()
+
+	for _, statuses := range query["status"] {
+		for _, status := range strings.Split(statuses, ",") {
+			if status == "" {
+				continue
+			}
+			s, err := parseStatus(Status(status))
+			if err != nil {
+				return q, err
+			}
+			q.Statuses = append(q.Statuses, s)
+		}
+	}
+
+	if queryHas(r, shipsAreaParams...) {
+		min, err := queryPoint(r, h.coords, "min_")
+		if err != nil {
+			return q, fmt.Errorf("area min: %w", err)
+		}
+		max, err := queryPoint(r, h.coords, "max_")
+		if err != nil {
+			return q, fmt.Errorf("area max: %w", err)
+		}
+		q.Area = &traffic.Area{Min: min, Max: max}
+	}
+
+	var err error
+	if q.SeenFrom, err = queryParam(r, "seen_from", strconv.Atoi); err != nil {
+		return q, err
+	}
+	if q.SeenTo, err = queryParam(r, "seen_to", strconv.Atoi); err != nil {
+		return q, err
+	}
+
+	sort := query.Get("sort")
+	q.Desc = strings.HasPrefix(sort, "-")
+	q.Sort = traffic.ShipsSort(strings.TrimPrefix(sort, "-"))
+
+	if q.Limit, err = queryInt(r, "limit", 0); err != nil {
+		return q, err
+	}
+	q.Cursor = query.Get("cursor")
+
+	return q, nil
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+)
+
+const snapshotContentType = "application/x-ndjson"
+
+type (
+	ISnapshot interface {
+		Export(w io.Writer) error
+		Import(r io.Reader) error
+	}
+	SnapshotHandler struct {
+		snapshot ISnapshot
+	}
+)
+
+func NewSnapshotHandler(snapshot ISnapshot) *SnapshotHandler {
+	return &SnapshotHandler{
+		snapshot: snapshot,
+	}
+}
+
+// Export streams snapshot of the whole traffic picture
+func (h *SnapshotHandler) Export(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", snapshotContentType)
+	w.WriteHeader(http.StatusOK)
+
+	// headers are already sent, nothing to report to the client
+	if err := h.snapshot.Export(w); err != nil {
+		slog.Error("failed to export snapshot", "error", err)
+	}
+}
+
+// Import replaces the whole traffic picture with the snapshot from request body
+func (h *SnapshotHandler) Import(w http.ResponseWriter, r *http.Request) {
+	err := h.snapshot.Import(r.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, traffic.ErrInvalidSnapshot):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
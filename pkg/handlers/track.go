@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"strconv"
+)
+
+func parseHistoryQuery(r *http.Request) (traffic.HistoryQuery, error) {
+	var q traffic.HistoryQuery
+	var err error
+	if q.From, err = queryParam(r, "from", strconv.Atoi); err != nil {
+		return q, err
+	}
+	if q.To, err = queryParam(r, "to", strconv.Atoi); err != nil {
+		return q, err
+	}
+	if q.Limit, err = queryInt(r, "limit", 0); err != nil {
+		return q, err
+	}
+	if q.Downsample, err = queryInt(r, "downsample", 0); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// writeTrack writes GetShipResponse position by position, so the whole track is never built in memory
+func (h *ShipsHandler) writeTrack(w http.ResponseWriter, id string, track traffic.Track) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	idJSON, _ := json.Marshal(id)
+	bw.WriteString(`{"id":`)
+	bw.Write(idJSON)
+	bw.WriteString(`,"positions":[`)
+
+	first := true
+	for pos := range track.Positions {
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+
+		// Encode appends new line, it is valid whitespace between array elements
+		if err := enc.Encode(mapPosition(pos, h.coords)); err != nil {
+			return err
+		}
+	}
+	bw.WriteByte(']')
+
+	if anomalies := mapAnomalies(track.Anomalies, h.coords); anomalies != nil {
+		data, err := json.Marshal(anomalies)
+		if err != nil {
+			return err
+		}
+		bw.WriteString(`,"anomalies":`)
+		bw.Write(data)
+	}
+	if track.Next != nil {
+		bw.WriteString(`,"next_from":`)
+		bw.WriteString(strconv.Itoa(*track.Next))
+	}
+	bw.WriteString("}\n")
+
+	return bw.Flush()
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maritime_traffic/pkg/traffic"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	WatchSubscribe   = "subscribe"
+	WatchUnsubscribe = "unsubscribe"
+
+	WatchSubscribed   = "subscribed"
+	WatchUnsubscribed = "unsubscribed"
+	WatchEvent        = "event"
+	WatchError        = "error"
+)
+
+const (
+	watchWriteWait  = 10 * time.Second
+	watchPongWait   = 60 * time.Second
+	watchPingPeriod = watchPongWait * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{
+	// same as CORS policy of the API
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type (
+	WatchHandler struct {
+		events IEvents
+		coords Coordinates
+	}
+	// WatchRequest is sent by client, subscribe replaces current subscription
+	WatchRequest struct {
+		Type      string   `json:"type"`
+		IDs       []string `json:"ids,omitempty"`
+		Area      *Area    `json:"area,omitempty"`
+		MinStatus Status   `json:"min_status,omitempty"`
+	}
+	// WatchMessage is sent by server
+	WatchMessage struct {
+		Type  string `json:"type"`
+		Event *Event `json:"event,omitempty"`
+		Error string `json:"error,omitempty"`
+	}
+	Area struct {
+		Min Position `json:"min"`
+		Max Position `json:"max"`
+	}
+	watchRead struct {
+		req WatchRequest
+		err error
+	}
+)
+
+func NewWatchHandler(events IEvents, coords Coordinates) *WatchHandler {
+	return &WatchHandler{
+		events: events,
+		coords: coords,
+	}
+}
+
+// Watch upgrades connection to WebSocket, client controls subscription with WatchRequest messages
+// and gets WatchMessage for every request and matching event
+func (h *WatchHandler) Watch(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader already replied with error
+		slog.Error("failed to upgrade connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	requests := make(chan watchRead)
+	done := make(chan struct{})
+	defer close(done)
+	go readWatchRequests(conn, requests, done)
+
+	var sub *traffic.Subscription
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+
+	ping := time.NewTicker(watchPingPeriod)
+	defer ping.Stop()
+
+	for {
+		var (
+			events <-chan traffic.Event
+			msg    WatchMessage
+		)
+		if sub != nil {
+			events = sub.C
+		}
+
+		select {
+		case <-r.Context().Done():
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(watchWriteWait))
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(watchWriteWait)); err != nil {
+				return
+			}
+			continue
+		case read, ok := <-requests:
+			if !ok {
+				return
+			}
+			if read.err != nil {
+				msg = WatchMessage{Type: WatchError, Error: read.err.Error()}
+			} else {
+				msg = h.handleWatchRequest(read.req, &sub)
+			}
+		case event, ok := <-events:
+			if !ok {
+				slog.Warn("watch subscription closed", "error", sub.Err())
+				_ = writeWatchMessage(conn, WatchMessage{Type: WatchError, Error: sub.Err().Error()})
+				return
+			}
+			e := mapEvent(event, h.coords)
+			msg = WatchMessage{Type: WatchEvent, Event: &e}
+		}
+
+		if err := writeWatchMessage(conn, msg); err != nil {
+			slog.Error("failed to write watch message", "error", err)
+			return
+		}
+	}
+}
+
+func (h *WatchHandler) handleWatchRequest(req WatchRequest, sub **traffic.Subscription) WatchMessage {
+	switch req.Type {
+	case WatchSubscribe:
+		filter, err := req.Filter(h.coords)
+		if err != nil {
+			return WatchMessage{Type: WatchError, Error: err.Error()}
+		}
+		if *sub == nil {
+			*sub = h.events.Subscribe(filter)
+		} else {
+			(*sub).SetFilter(filter)
+		}
+		return WatchMessage{Type: WatchSubscribed}
+	case WatchUnsubscribe:
+		if *sub != nil {
+			(*sub).Close()
+			*sub = nil
+		}
+		return WatchMessage{Type: WatchUnsubscribed}
+	default:
+		return WatchMessage{Type: WatchError, Error: fmt.Sprintf("unknown request type %q", req.Type)}
+	}
+}
+
+// readWatchRequests reads requests until connection is closed
+func readWatchRequests(conn *websocket.Conn, requests chan<- watchRead, done <-chan struct{}) {
+	defer close(requests)
+
+	_ = conn.SetReadDeadline(time.Now().Add(watchPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(watchPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var read watchRead
+		if err := json.Unmarshal(data, &read.req); err != nil {
+			read.err = fmt.Errorf("invalid request: %w", err)
+		}
+
+		select {
+		case requests <- read:
+		case <-done:
+			return
+		}
+	}
+}
+
+func writeWatchMessage(conn *websocket.Conn, msg WatchMessage) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(watchWriteWait)); err != nil {
+		return err
+	}
+
+	return conn.WriteJSON(msg)
+}
+
+func (r WatchRequest) Filter(coords Coordinates) (traffic.EventFilter, error) {
+	filter := traffic.EventFilter{
+		IDs: r.IDs,
+	}
+
+	if r.Area != nil {
+		min, err := coords.Point(r.Area.Min)
+		if err != nil {
+			return filter, fmt.Errorf("area min: %w", err)
+		}
+		max, err := coords.Point(r.Area.Max)
+		if err != nil {
+			return filter, fmt.Errorf("area max: %w", err)
+		}
+
+		filter.Area = &traffic.Area{Min: min, Max: max}
+		if err := filter.Area.Validate(); err != nil {
+			return filter, err
+		}
+	}
+
+	if r.MinStatus != "" {
+		status, err := parseStatus(r.MinStatus)
+		if err != nil {
+			return filter, err
+		}
+		filter.MinStatus = status
+	}
+
+	return filter, nil
+}
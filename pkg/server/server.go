@@ -8,7 +8,7 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func NewAPI(shipsH *handlers.ShipsHandler) *mux.Router {
+func NewAPI(shipsH *handlers.ShipsHandler, hazardsH *handlers.HazardsHandler, geofencesH *handlers.GeofencesHandler, snapshotH *handlers.SnapshotHandler, eventsH *handlers.EventsHandler, watchH *handlers.WatchHandler, incidentsH *handlers.IncidentsHandler) *mux.Router {
 	r := mux.NewRouter()
 
 	r.Use(func(next http.Handler) http.Handler {
@@ -26,8 +26,8 @@ func NewAPI(shipsH *handlers.ShipsHandler) *mux.Router {
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+handlers.OperatorHeader)
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
 				return
@@ -46,9 +46,40 @@ func NewAPI(shipsH *handlers.ShipsHandler) *mux.Router {
 	v1 := r.PathPrefix("/api/v1").Subrouter()
 	ships := v1.PathPrefix("/ships").Subrouter()
 	ships.HandleFunc("", shipsH.GetShips).Methods("GET")
+	// before /{id}, nearby is reserved and can't be a ship id
+	ships.HandleFunc("/nearby", shipsH.Nearby).Methods("GET")
 	ships.HandleFunc("/{id}", shipsH.GetShip).Methods("GET")
+	ships.HandleFunc("/{id}", shipsH.DeleteShip).Methods("DELETE")
 	ships.HandleFunc("/{id}/position", shipsH.PositionShip).Methods("POST")
+	ships.HandleFunc("/{id}/simulate", shipsH.Simulate).Methods("POST")
+	ships.HandleFunc("/{id}/positions/{time}", shipsH.CorrectPosition).Methods("PUT")
+	ships.HandleFunc("/{id}/positions/{time}", shipsH.DeletePosition).Methods("DELETE")
+	ships.HandleFunc("/{id}/audit", shipsH.Audit).Methods("GET")
+	ships.HandleFunc("/{id}/prediction", shipsH.GetPrediction).Methods("GET")
+	ships.HandleFunc("/{id}/neighbours", shipsH.Neighbours).Methods("GET")
+	ships.HandleFunc("/{id}/profile", shipsH.GetProfile).Methods("GET")
+	ships.HandleFunc("/{id}/profile", shipsH.SetProfile).Methods("PUT")
 
+	hazards := v1.PathPrefix("/hazards").Subrouter()
+	hazards.HandleFunc("", hazardsH.GetHazards).Methods("GET")
+	hazards.HandleFunc("", hazardsH.AddHazard).Methods("POST")
+	hazards.HandleFunc("/{id}", hazardsH.GetHazard).Methods("GET")
+	hazards.HandleFunc("/{id}", hazardsH.UpdateHazard).Methods("PUT")
+	hazards.HandleFunc("/{id}", hazardsH.DeleteHazard).Methods("DELETE")
+
+	geofences := v1.PathPrefix("/geofences").Subrouter()
+	geofences.HandleFunc("", geofencesH.GetGeofences).Methods("GET")
+	geofences.HandleFunc("", geofencesH.AddGeofence).Methods("POST")
+	geofences.HandleFunc("/{id}", geofencesH.GetGeofence).Methods("GET")
+	geofences.HandleFunc("/{id}", geofencesH.UpdateGeofence).Methods("PUT")
+	geofences.HandleFunc("/{id}", geofencesH.DeleteGeofence).Methods("DELETE")
+
+	v1.HandleFunc("/positions:batch", shipsH.PositionShips).Methods("POST")
 	v1.HandleFunc("/flush", shipsH.Flush).Methods("POST")
+	v1.HandleFunc("/snapshot", snapshotH.Export).Methods("GET")
+	v1.HandleFunc("/snapshot", snapshotH.Import).Methods("POST")
+	v1.HandleFunc("/events", eventsH.Stream).Methods("GET")
+	v1.HandleFunc("/watch", watchH.Watch).Methods("GET")
+	v1.HandleFunc("/incidents", incidentsH.GetIncidents).Methods("GET")
 	return r
 }
@@ -0,0 +1,613 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maritime_traffic/pkg/traffic"
+	"os"
+	"path/filepath"
+)
+
+const (
+	snapshotFileName = "snapshot.jsonl"
+	logFileName      = "changes.log"
+	// rotatedLogFileName is the log waiting to be compacted into the snapshot
+	rotatedLogFileName = logFileName + ".1"
+)
+
+const (
+	opAppend   = "append"
+	opPut      = "put"
+	opSplice   = "splice"
+	opStatus   = "status"
+	opAudit    = "audit"
+	opAnomaly  = "anomaly"
+	opIncident = "incident"
+	opTrim     = "trim_incidents"
+	opDelete   = "delete"
+	opFlush    = "flush"
+	opSeeded   = "seeded"
+
+	opPutHazard      = "put_hazard"
+	opDeleteHazard   = "delete_hazard"
+	opPutGeofence    = "put_geofence"
+	opDeleteGeofence = "delete_geofence"
+	opPutProfile     = "put_profile"
+	opDeleteProfile  = "delete_profile"
+)
+
+type (
+	// FileStore keeps state in memory and makes it durable with append-only change log
+	// plus periodic snapshot. Every change is written to the log before it is applied,
+	// after snapshotEvery changes the log is rotated and compacted into the snapshot in background,
+	// so writers never wait for the snapshot.
+	//
+	// Changes are not fsynced, they survive process crash but not power loss.
+	FileStore struct {
+		*traffic.MemoryStore
+
+		dir           string
+		log           *os.File
+		offset        int64  // end of the last complete change in the log
+		seq           uint64 // sequence number of the last written change
+		snapshotSeq   uint64 // sequence number of the last change in the snapshot or the rotated log
+		snapshotEvery int
+		rotated       bool       // rotated log exists and is not compacted yet
+		compaction    chan error // result of the running compaction, nil when there is none
+	}
+
+	change struct {
+		Seq       uint64                   `json:"seq"`
+		Op        string                   `json:"op"`
+		ID        string                   `json:"id,omitempty"`
+		Status    traffic.Status           `json:"status,omitempty"`
+		From      int                      `json:"from,omitempty"`
+		To        int                      `json:"to,omitempty"`
+		Before    int                      `json:"before,omitempty"`
+		Positions []traffic.PositionRecord `json:"positions,omitempty"`
+		Audit     *traffic.AuditRecord     `json:"audit,omitempty"`
+		Anomaly   *traffic.AnomalyRecord   `json:"anomaly,omitempty"`
+		Incident  *traffic.IncidentRecord  `json:"incident,omitempty"`
+		Hazard    *traffic.HazardRecord    `json:"hazard,omitempty"`
+		Geofence  *traffic.GeofenceRecord  `json:"geofence,omitempty"`
+		Profile   *traffic.ProfileRecord   `json:"profile,omitempty"`
+	}
+)
+
+var _ traffic.Store = (*FileStore)(nil)
+
+// NewFileStore opens store in dir, creating dir if needed, and replays snapshot and change logs
+func NewFileStore(dir string, snapshotEvery int) (*FileStore, error) {
+	if snapshotEvery <= 0 {
+		return nil, fmt.Errorf("snapshot interval must be positive")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage dir: %w", err)
+	}
+
+	s := &FileStore{
+		MemoryStore:   traffic.NewMemoryStore(),
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+	}
+
+	header, err := loadSnapshot(dir, s.MemoryStore)
+	if err != nil {
+		return nil, err
+	}
+	s.seq = header.Seq
+	s.snapshotSeq = header.Seq
+
+	// previous compaction didn't finish, its changes go before the log
+	if err := s.replayRotated(); err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open change log: %w", err)
+	}
+	s.log = log
+
+	if err := s.replay(); err != nil {
+		_ = log.Close()
+		return nil, err
+	}
+
+	if s.rotated {
+		s.compact()
+	}
+
+	slog.Info("storage loaded", "dir", dir, "ships", s.Len(), "seq", s.seq)
+
+	return s, nil
+}
+
+// loadSnapshot reads snapshot file of the dir into the store, zero header if there is none
+func loadSnapshot(dir string, store *traffic.MemoryStore) (traffic.SnapshotHeader, error) {
+	f, err := os.Open(filepath.Join(dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return traffic.SnapshotHeader{}, nil
+	}
+	if err != nil {
+		return traffic.SnapshotHeader{}, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	header, err := traffic.ReadSnapshot(f, store)
+	if err != nil {
+		return header, err
+	}
+	if header.Seeded {
+		return header, store.MarkSeeded()
+	}
+
+	return header, nil
+}
+
+func (s *FileStore) replayRotated() error {
+	f, err := os.Open(filepath.Join(s.dir, rotatedLogFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open rotated change log: %w", err)
+	}
+	defer f.Close()
+
+	s.seq, _, err = replayLog(f, s.MemoryStore, s.seq)
+	s.rotated = true
+
+	return err
+}
+
+// replay applies changes written after the snapshot,
+// incomplete change at the end of the log is a torn write and gets truncated
+func (s *FileStore) replay() error {
+	seq, offset, err := replayLog(s.log, s.MemoryStore, s.seq)
+	if err != nil {
+		return err
+	}
+	s.seq = seq
+
+	if end, err := s.log.Seek(0, io.SeekEnd); err != nil {
+		return err
+	} else if end > offset {
+		slog.Warn("truncating incomplete change at the end of the log", "offset", offset)
+	}
+
+	return s.truncate(offset)
+}
+
+// replayLog applies changes after seq to the store, returns sequence number of the last applied change
+// and offset after the last complete change
+func replayLog(log io.Reader, store *traffic.MemoryStore, seq uint64) (uint64, int64, error) {
+	r := bufio.NewReader(log)
+	offset := int64(0)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return seq, offset, nil
+		}
+		if err != nil {
+			return seq, offset, fmt.Errorf("failed to read change log: %w", err)
+		}
+
+		var c change
+		if err := json.Unmarshal(line, &c); err != nil {
+			return seq, offset, fmt.Errorf("corrupted change log at offset %d: %w", offset, err)
+		}
+		offset += int64(len(line))
+
+		// snapshot could be written without removing the log
+		if c.Seq <= seq {
+			continue
+		}
+		if err := apply(store, c); err != nil {
+			return seq, offset, err
+		}
+		seq = c.Seq
+	}
+}
+
+// truncate cuts the log at offset and continues writing from there
+func (s *FileStore) truncate(offset int64) error {
+	if err := s.log.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate change log: %w", err)
+	}
+	if _, err := s.log.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	s.offset = offset
+
+	return nil
+}
+
+func apply(store *traffic.MemoryStore, c change) error {
+	switch c.Op {
+	case opAppend:
+		if len(c.Positions) != 1 {
+			return fmt.Errorf("append change %d must have exactly one position", c.Seq)
+		}
+		return store.Append(c.ID, c.Positions[0].ShipPosition(), c.Status)
+	case opPut:
+		history := traffic.ShipRecord{Positions: c.Positions}.History()
+		return store.Put(c.ID, history, c.Status)
+	case opSplice:
+		history := traffic.ShipRecord{Positions: c.Positions}.History()
+		return store.Splice(c.ID, c.From, c.To, history, c.Status)
+	case opStatus:
+		return store.SetStatus(c.ID, c.Status)
+	case opDelete:
+		return store.Delete(c.ID)
+	case opAudit:
+		if c.Audit == nil {
+			return fmt.Errorf("audit change %d must have a record", c.Seq)
+		}
+		return store.AppendAudit(*c.Audit)
+	case opAnomaly:
+		if c.Anomaly == nil {
+			return fmt.Errorf("anomaly change %d must have an anomaly", c.Seq)
+		}
+		return store.AppendAnomaly(c.ID, c.Anomaly.SpeedAnomaly())
+	case opIncident:
+		if c.Incident == nil {
+			return fmt.Errorf("incident change %d must have an incident", c.Seq)
+		}
+		return store.AppendIncident(c.Incident.Incident())
+	case opTrim:
+		return store.TrimIncidents(c.Before)
+	case opPutHazard:
+		if c.Hazard == nil {
+			return fmt.Errorf("hazard change %d must have a hazard", c.Seq)
+		}
+		return store.PutHazard(c.Hazard.Hazard())
+	case opDeleteHazard:
+		return store.DeleteHazard(c.ID)
+	case opPutGeofence:
+		if c.Geofence == nil {
+			return fmt.Errorf("geofence change %d must have a geofence", c.Seq)
+		}
+		return store.PutGeofence(c.Geofence.Geofence())
+	case opDeleteGeofence:
+		return store.DeleteGeofence(c.ID)
+	case opPutProfile:
+		if c.Profile == nil {
+			return fmt.Errorf("profile change %d must have a profile", c.Seq)
+		}
+		return store.PutProfile(c.ID, c.Profile.Profile())
+	case opDeleteProfile:
+		return store.DeleteProfile(c.ID)
+	case opFlush:
+		return store.Flush()
+	case opSeeded:
+		return store.MarkSeeded()
+	default:
+		return fmt.Errorf("unknown change %q", c.Op)
+	}
+}
+
+// write appends change to the log and applies it
+func (s *FileStore) write(c change) error {
+	c.Seq = s.seq + 1
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.log.Write(append(data, '\n')); err != nil {
+		// torn change must not stay in front of the next ones, replay would stop at it
+		if truncErr := s.truncate(s.offset); truncErr != nil {
+			err = errors.Join(err, truncErr)
+		}
+		return fmt.Errorf("failed to write change log: %w", err)
+	}
+	s.offset += int64(len(data) + 1)
+	s.seq = c.Seq
+
+	if err := apply(s.MemoryStore, c); err != nil {
+		return err
+	}
+
+	if s.seq-s.snapshotSeq >= uint64(s.snapshotEvery) {
+		s.compact()
+	}
+
+	return nil
+}
+
+func (s *FileStore) Append(id string, position traffic.ShipPosition, status traffic.Status) error {
+	return s.write(change{
+		Op:        opAppend,
+		ID:        id,
+		Status:    status,
+		Positions: []traffic.PositionRecord{traffic.NewPositionRecord(position)},
+	})
+}
+
+func (s *FileStore) Put(id string, history []traffic.ShipPosition, status traffic.Status) error {
+	return s.write(change{
+		Op:        opPut,
+		ID:        id,
+		Status:    status,
+		Positions: traffic.NewShipRecord(id, history, status).Positions,
+	})
+}
+
+func (s *FileStore) Splice(id string, from, to int, positions []traffic.ShipPosition, status traffic.Status) error {
+	// invalid change must not get into the log, replay would fail on it
+	if history, _ := s.History(id); from < 0 || from > to || to > len(history) {
+		return fmt.Errorf("invalid range [%d:%d] of %d positions", from, to, len(history))
+	}
+
+	return s.write(change{
+		Op:        opSplice,
+		ID:        id,
+		Status:    status,
+		From:      from,
+		To:        to,
+		Positions: traffic.NewShipRecord(id, positions, status).Positions,
+	})
+}
+
+func (s *FileStore) SetStatus(id string, status traffic.Status) error {
+	return s.write(change{
+		Op:     opStatus,
+		ID:     id,
+		Status: status,
+	})
+}
+
+func (s *FileStore) Delete(id string) error {
+	return s.write(change{
+		Op: opDelete,
+		ID: id,
+	})
+}
+
+func (s *FileStore) AppendAudit(record traffic.AuditRecord) error {
+	return s.write(change{
+		Op:    opAudit,
+		Audit: &record,
+	})
+}
+
+func (s *FileStore) AppendAnomaly(id string, a traffic.SpeedAnomaly) error {
+	record := traffic.NewAnomalyRecord(id, a)
+	return s.write(change{
+		Op:      opAnomaly,
+		ID:      id,
+		Anomaly: &record,
+	})
+}
+
+func (s *FileStore) AppendIncident(i traffic.Incident) error {
+	record := traffic.NewIncidentRecord(i)
+	return s.write(change{
+		Op:       opIncident,
+		ID:       i.ShipID,
+		Incident: &record,
+	})
+}
+
+func (s *FileStore) TrimIncidents(before int) error {
+	return s.write(change{
+		Op:     opTrim,
+		Before: before,
+	})
+}
+
+func (s *FileStore) PutHazard(h traffic.Hazard) error {
+	record := traffic.NewHazardRecord(h)
+	return s.write(change{
+		Op:     opPutHazard,
+		Hazard: &record,
+	})
+}
+
+func (s *FileStore) DeleteHazard(id string) error {
+	return s.write(change{
+		Op: opDeleteHazard,
+		ID: id,
+	})
+}
+
+func (s *FileStore) PutGeofence(g traffic.Geofence) error {
+	record := traffic.NewGeofenceRecord(g)
+	return s.write(change{
+		Op:       opPutGeofence,
+		Geofence: &record,
+	})
+}
+
+func (s *FileStore) DeleteGeofence(id string) error {
+	return s.write(change{
+		Op: opDeleteGeofence,
+		ID: id,
+	})
+}
+
+func (s *FileStore) PutProfile(id string, p traffic.VesselProfile) error {
+	record := traffic.NewProfileRecord(id, p)
+	return s.write(change{
+		Op:      opPutProfile,
+		ID:      id,
+		Profile: &record,
+	})
+}
+
+func (s *FileStore) DeleteProfile(id string) error {
+	return s.write(change{
+		Op: opDeleteProfile,
+		ID: id,
+	})
+}
+
+func (s *FileStore) MarkSeeded() error {
+	return s.write(change{Op: opSeeded})
+}
+
+func (s *FileStore) Flush() error {
+	if err := s.write(change{Op: opFlush}); err != nil {
+		return err
+	}
+
+	// nothing to keep, compact the log right away
+	s.compact()
+	return nil
+}
+
+// compact rotates the log and compacts it into the snapshot in background, one compaction runs at a time.
+// Change is already durable when it is called, failed compaction is retried after snapshotEvery more changes
+func (s *FileStore) compact() {
+	if s.compacting() {
+		return
+	}
+	s.snapshotSeq = s.seq
+
+	// rotated log of failed compaction is compacted first, the log keeps growing meanwhile
+	if !s.rotated {
+		if err := s.rotate(); err != nil {
+			slog.Error("failed to rotate change log", "error", err)
+			return
+		}
+	}
+
+	done := make(chan error, 1)
+	s.compaction = done
+	go func() {
+		done <- compactLog(s.dir)
+	}()
+}
+
+// compacting tells whether compaction is still running, result of the finished one is collected
+func (s *FileStore) compacting() bool {
+	if s.compaction == nil {
+		return false
+	}
+
+	select {
+	case err := <-s.compaction:
+		s.finishCompaction(err)
+		return false
+	default:
+		return true
+	}
+}
+
+// wait blocks until running compaction finishes and returns its error
+func (s *FileStore) wait() error {
+	if s.compaction == nil {
+		return nil
+	}
+
+	err := <-s.compaction
+	s.finishCompaction(err)
+	return err
+}
+
+func (s *FileStore) finishCompaction(err error) {
+	s.compaction = nil
+	if err != nil {
+		slog.Error("failed to write snapshot", "error", err)
+		return
+	}
+	s.rotated = false
+}
+
+// rotate moves the log aside for compaction and starts a new one
+func (s *FileStore) rotate() error {
+	name := filepath.Join(s.dir, logFileName)
+	rotated := filepath.Join(s.dir, rotatedLogFileName)
+	if err := os.Rename(name, rotated); err != nil {
+		return err
+	}
+
+	log, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return errors.Join(err, os.Rename(rotated, name))
+	}
+
+	_ = s.log.Close()
+	s.log = log
+	s.offset = 0
+	s.rotated = true
+
+	return nil
+}
+
+// compactLog applies rotated log to the snapshot and removes the log. It works with files only,
+// so it doesn't need the state of the store.
+// Crash before the log is removed is fine, its changes up to snapshot seq are skipped on replay
+func compactLog(dir string) error {
+	store := traffic.NewMemoryStore()
+	header, err := loadSnapshot(dir, store)
+	if err != nil {
+		return err
+	}
+
+	rotated := filepath.Join(dir, rotatedLogFileName)
+	log, err := os.Open(rotated)
+	if err != nil {
+		return err
+	}
+	seq, _, err := replayLog(log, store, header.Seq)
+	_ = log.Close()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, snapshotFileName+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = traffic.WriteSnapshot(f, traffic.SnapshotHeader{Seq: seq, Seeded: store.Seeded()}, store)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	return os.Remove(rotated)
+}
+
+// Snapshot compacts the whole log into the snapshot and waits for it
+func (s *FileStore) Snapshot() error {
+	// failure is already logged, rotated log left by it is compacted again
+	_ = s.wait()
+	if s.rotated {
+		s.compact()
+		if err := s.wait(); err != nil {
+			return err
+		}
+	}
+
+	s.compact()
+	return s.wait()
+}
+
+// Close writes final snapshot and closes the log
+func (s *FileStore) Close() error {
+	err := s.Snapshot()
+	if closeErr := s.log.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"maritime_traffic/pkg/traffic"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func position(t int, x, y float64) traffic.ShipPosition {
+	return traffic.ShipPosition{Time: t, Position: traffic.Vector{X: x, Y: y}, Speed: traffic.Vector{X: 1, Y: 0.5}}
+}
+
+func TestFileStoreReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("1", position(100, 1, 2), traffic.Green))
+	require.NoError(t, s.Append("1", position(101, 2, 3), traffic.Yellow))
+	require.NoError(t, s.Append("2", position(101, 5, 5), traffic.Red))
+	require.NoError(t, s.Put("3", []traffic.ShipPosition{position(90, 0, 0), position(95, 5, 5)}, traffic.Yellow))
+
+	// simulate crash, nothing is closed
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+
+	history, ok := s.History("1")
+	require.True(t, ok)
+	assert.Equal(t, []traffic.ShipPosition{position(100, 1, 2), position(101, 2, 3)}, history)
+	assert.Equal(t, traffic.Yellow, s.Status("1"))
+	assert.Equal(t, traffic.Red, s.Status("2"))
+
+	history, ok = s.History("3")
+	require.True(t, ok)
+	assert.Equal(t, []traffic.ShipPosition{position(90, 0, 0), position(95, 5, 5)}, history)
+	assert.Equal(t, 3, s.Len())
+
+	// appends after replay continue the log
+	require.NoError(t, s.Append("2", position(102, 6, 6), traffic.Green))
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	history, _ = s.History("2")
+	assert.Equal(t, []traffic.ShipPosition{position(101, 5, 5), position(102, 6, 6)}, history)
+	assert.Equal(t, traffic.Green, s.Status("2"))
+}
+
+func TestFileStoreSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, 3)
+	require.NoError(t, err)
+	for i := range 10 {
+		require.NoError(t, s.Append("1", position(100+i, float64(i), 0), traffic.Green))
+	}
+
+	// 9 changes are in the snapshot, one in the log
+	require.NoError(t, s.wait())
+	info, err := os.Stat(filepath.Join(dir, snapshotFileName))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+
+	s, err = NewFileStore(dir, 3)
+	require.NoError(t, err)
+	history, _ := s.History("1")
+	assert.Len(t, history, 10)
+	assert.Equal(t, 109, history[9].Time)
+}
+
+func TestFileStoreSnapshotWithoutTruncatedLog(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("1", position(100, 0, 0), traffic.Green))
+	require.NoError(t, s.Append("1", position(101, 1, 0), traffic.Green))
+
+	// crash right after snapshot was renamed but log is not truncated yet
+	log, err := os.ReadFile(filepath.Join(dir, logFileName))
+	require.NoError(t, err)
+	require.NoError(t, s.Snapshot())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, logFileName), log, 0o644))
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	history, _ := s.History("1")
+	assert.Len(t, history, 2)
+}
+
+func TestFileStoreRotatedLog(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("1", position(100, 0, 0), traffic.Green))
+	require.NoError(t, s.Snapshot())
+	require.NoError(t, s.Append("1", position(101, 1, 0), traffic.Green))
+	require.NoError(t, s.Append("2", position(101, 1, 0), traffic.Green))
+
+	// crash during compaction, rotated log is not in the snapshot yet
+	require.NoError(t, s.rotate())
+	require.NoError(t, s.Append("2", position(102, 2, 0), traffic.Green))
+
+	assertHistories := func(s *FileStore) {
+		history, _ := s.History("1")
+		assert.Equal(t, []traffic.ShipPosition{position(100, 0, 0), position(101, 1, 0)}, history)
+		history, _ = s.History("2")
+		assert.Equal(t, []traffic.ShipPosition{position(101, 1, 0), position(102, 2, 0)}, history)
+	}
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	assertHistories(s)
+
+	// compaction started on open puts rotated log into the snapshot
+	require.NoError(t, s.wait())
+	_, err = os.Stat(filepath.Join(dir, rotatedLogFileName))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	assertHistories(s)
+}
+
+func TestFileStoreTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("1", position(100, 0, 0), traffic.Green))
+
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":2,"op":"app`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("1", position(101, 1, 0), traffic.Green))
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	history, _ := s.History("1")
+	assert.Equal(t, []traffic.ShipPosition{position(100, 0, 0), position(101, 1, 0)}, history)
+}
+
+func TestFileStoreFlush(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("1", position(100, 0, 0), traffic.Green))
+	require.NoError(t, s.Flush())
+	require.NoError(t, s.Append("2", position(100, 0, 0), traffic.Green))
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	_, ok := s.History("1")
+	assert.False(t, ok)
+	_, ok = s.History("2")
+	assert.True(t, ok)
+}
+
+func TestFileStoreSplice(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("1", position(100, 0, 0), traffic.Green))
+	require.NoError(t, s.Append("1", position(110, 1, 0), traffic.Green))
+	require.NoError(t, s.Splice("1", 1, 2, []traffic.ShipPosition{position(105, 5, 5), position(110, 1, 1)}, traffic.Yellow))
+	require.NoError(t, s.SetStatus("1", traffic.Red))
+	require.NoError(t, s.Append("2", position(100, 0, 0), traffic.Green))
+	require.NoError(t, s.Delete("2"))
+	require.NoError(t, s.AppendAudit(traffic.AuditRecord{Operator: "jane", Action: traffic.AuditDelete, ShipID: "1"}))
+	// invalid change is not written to the log
+	require.Error(t, s.Splice("1", 2, 5, nil, traffic.Green))
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	history, _ := s.History("1")
+	assert.Equal(t, []traffic.ShipPosition{position(100, 0, 0), position(105, 5, 5), position(110, 1, 1)}, history)
+	assert.Equal(t, traffic.Red, s.Status("1"))
+	_, ok := s.History("2")
+	assert.False(t, ok)
+
+	var audit []traffic.AuditRecord
+	s.RangeAudit(func(record traffic.AuditRecord) bool {
+		audit = append(audit, record)
+		return true
+	})
+	assert.Equal(t, []traffic.AuditRecord{{Operator: "jane", Action: traffic.AuditDelete, ShipID: "1"}}, audit)
+}
+
+func TestFileStoreHazards(t *testing.T) {
+	dir := t.TempDir()
+
+	buoy := traffic.Hazard{ID: "buoy", Position: traffic.Vector{X: 10, Y: 10}, RedRadius: 1, YellowRadius: 2}
+	wreck := traffic.Hazard{ID: "wreck", Position: traffic.Vector{X: -5, Y: 5}, Radius: 3, RedRadius: 1, YellowRadius: 2}
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.PutHazard(buoy))
+	require.NoError(t, s.PutHazard(wreck))
+	require.NoError(t, s.DeleteHazard("wreck"))
+	// flush keeps hazards
+	require.NoError(t, s.Flush())
+	buoy.Radius = 4
+	require.NoError(t, s.PutHazard(buoy))
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	h, ok := s.Hazard("buoy")
+	require.True(t, ok)
+	assert.Equal(t, buoy, h)
+	_, ok = s.Hazard("wreck")
+	assert.False(t, ok)
+}
+
+func TestFileStoreSeeded(t *testing.T) {
+	// restart from the log and from the snapshot
+	for _, snapshotEvery := range []int{100, 1} {
+		dir := t.TempDir()
+
+		s, err := NewFileStore(dir, snapshotEvery)
+		require.NoError(t, err)
+		tr, err := traffic.NewTraffic(traffic.DefaultConfig(), s)
+		require.NoError(t, err)
+		require.NoError(t, tr.DeleteHazard(traffic.TowerID))
+		require.NoError(t, tr.Close())
+
+		s, err = NewFileStore(dir, snapshotEvery)
+		require.NoError(t, err)
+		assert.True(t, s.Seeded())
+		tr, err = traffic.NewTraffic(traffic.DefaultConfig(), s)
+		require.NoError(t, err)
+		assert.Empty(t, tr.Hazards(), "snapshot every %d", snapshotEvery)
+		require.NoError(t, tr.Close())
+	}
+}
+
+func TestFileStoreGeofences(t *testing.T) {
+	dir := t.TempDir()
+
+	zone := traffic.Geofence{ID: "zone", Polygon: []traffic.Vector{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}, Status: traffic.Red}
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.PutGeofence(zone))
+	require.NoError(t, s.PutGeofence(traffic.Geofence{ID: "other", Polygon: zone.Polygon, Status: traffic.Yellow}))
+	require.NoError(t, s.DeleteGeofence("other"))
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	g, ok := s.Geofence("zone")
+	require.True(t, ok)
+	assert.Equal(t, zone, g)
+	_, ok = s.Geofence("other")
+	assert.False(t, ok)
+}
+
+func TestFileStoreProfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	tanker := traffic.VesselProfile{Type: "tanker", Length: 250, Beam: 40}
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.PutProfile("1", tanker))
+	require.NoError(t, s.PutProfile("2", tanker))
+	require.NoError(t, s.DeleteProfile("2"))
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	p, ok := s.Profile("1")
+	require.True(t, ok)
+	assert.Equal(t, tanker, p)
+	_, ok = s.Profile("2")
+	assert.False(t, ok)
+}
+
+func TestFileStoreAnomalies(t *testing.T) {
+	dir := t.TempDir()
+
+	anomaly := traffic.SpeedAnomaly{Time: 2, RawSpeed: 250, Speed: 100, MaxSpeed: 100}
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("1", position(1, 0, 0), traffic.Green))
+	require.NoError(t, s.Append("1", position(2, 250, 0), traffic.Green))
+	require.NoError(t, s.AppendAnomaly("1", anomaly))
+	require.NoError(t, s.Append("2", position(1, 0, 0), traffic.Green))
+	require.NoError(t, s.AppendAnomaly("2", anomaly))
+	require.NoError(t, s.Delete("2"))
+
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []traffic.SpeedAnomaly{anomaly}, s.Anomalies("1"))
+	assert.Empty(t, s.Anomalies("2"))
+}
+
+func TestFileStoreIncidents(t *testing.T) {
+	dir := t.TempDir()
+
+	incidents := []traffic.Incident{
+		{ShipID: "1", Time: 1, From: traffic.Green, To: traffic.Red, Counterpart: &traffic.Conflict{
+			Kind: traffic.KindShip, ID: "2", Status: traffic.Red, Distance: 0.5, Time: 1, OtherPosition: traffic.Vector{X: 0.5},
+		}},
+		{ShipID: "1", Time: 2, From: traffic.Red, To: traffic.Green},
+		{ShipID: "2", Time: 3, From: traffic.Green, To: traffic.Yellow},
+	}
+
+	// log is replayed over the snapshot
+	s, err := NewFileStore(dir, 2)
+	require.NoError(t, err)
+	for _, i := range incidents {
+		require.NoError(t, s.AppendIncident(i))
+	}
+	require.NoError(t, s.Append("4", position(1, 0, 0), traffic.Green))
+	require.NoError(t, s.AppendIncident(traffic.Incident{ShipID: "4", Time: 1, From: traffic.Green, To: traffic.Red}))
+	require.NoError(t, s.Delete("1"))
+	require.NoError(t, s.TrimIncidents(2))
+	require.NoError(t, s.wait())
+
+	s, err = NewFileStore(dir, 2)
+	require.NoError(t, err)
+	var restored []traffic.Incident
+	s.RangeIncidents(func(i traffic.Incident) bool {
+		restored = append(restored, i)
+		return true
+	})
+	// incidents of the deleted ship are gone, incidents of its counterparts are kept
+	assert.Equal(t, incidents[2:], restored)
+}
+
+func TestFileStoreWithTraffic(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, 100)
+	require.NoError(t, err)
+	tr, err := traffic.NewTraffic(traffic.DefaultConfig(), s)
+	require.NoError(t, err)
+
+	_, err = tr.PositionShip(traffic.PositionShip{ID: "1", Time: 100, Point: traffic.Vector{X: 10, Y: 10}})
+	require.NoError(t, err)
+	require.NoError(t, tr.Close())
+
+	// restarted traffic knows about ship 1
+	s, err = NewFileStore(dir, 100)
+	require.NoError(t, err)
+	tr, err = traffic.NewTraffic(traffic.DefaultConfig(), s)
+	require.NoError(t, err)
+
+	res, err := tr.PositionShip(traffic.PositionShip{ID: "2", Time: 100, Point: traffic.Vector{X: 10, Y: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, traffic.Red, res.Status)
+
+	_, err = tr.PositionShip(traffic.PositionShip{ID: "1", Time: 100, Point: traffic.Vector{X: 10, Y: 10}})
+	assert.ErrorIs(t, err, traffic.ErrTimeInPast)
+}
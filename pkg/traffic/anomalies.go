@@ -0,0 +1,60 @@
+package traffic
+
+import "slices"
+
+// SpeedAnomaly is recorded when a fix implies speed above the limit of the vessel class,
+// usually a spoofed or mis-reported position. Speed stored with the fix is clamped to the limit.
+type SpeedAnomaly struct {
+	Time     int     // time of the fix
+	RawSpeed float64 // speed implied by the distance from the previous fix
+	Speed    float64 // clamped speed stored with the fix
+	MaxSpeed float64 // limit of the vessel class at the time the fix was recorded
+}
+
+// Anomalies returns speed anomalies of the ship, oldest first
+func (t *Traffic) Anomalies(id string) ([]SpeedAnomaly, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if _, ok := t.store.History(id); !ok {
+		return nil, ErrNotFound
+	}
+
+	return slices.Clone(t.store.Anomalies(id)), nil
+}
+
+// maxSpeed returns speed limit of the vessel class of the ship, global limit for ships without one
+func (t *Traffic) maxSpeed(id string) float64 {
+	p, _ := t.store.Profile(id)
+	if limit, ok := t.cfg.ClassMaxSpeed[p.Type]; ok && p.Type != "" {
+		return limit
+	}
+
+	return t.cfg.MaxSpeed
+}
+
+// limitSpeed clamps raw speed of the fix to the limit of the ship and returns anomaly if it had to
+func (t *Traffic) limitSpeed(id string, time int, raw Vector) (Vector, *SpeedAnomaly) {
+	maxSpeed := t.maxSpeed(id)
+	speed := clampSpeed(raw, maxSpeed)
+	if raw.Magnitude() <= maxSpeed {
+		return speed, nil
+	}
+
+	return speed, &SpeedAnomaly{
+		Time:     time,
+		RawSpeed: raw.Magnitude(),
+		Speed:    speed.Magnitude(),
+		MaxSpeed: maxSpeed,
+	}
+}
+
+// recordAnomaly stores anomaly unless the same one is already known, speeds around
+// late and corrected positions are recalculated and can find it again
+func (t *Traffic) recordAnomaly(id string, a SpeedAnomaly) error {
+	if slices.Contains(t.store.Anomalies(id), a) {
+		return nil
+	}
+
+	return t.store.AppendAnomaly(id, a)
+}
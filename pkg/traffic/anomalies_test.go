@@ -0,0 +1,104 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeedAnomaly(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	_, err := tr.PositionShip(PositionShip{ID: "1", Time: 1000, Point: Vector{X: 0, Y: 0}})
+	require.NoError(t, err)
+	res, err := tr.PositionShip(PositionShip{ID: "1", Time: 1001, Point: Vector{X: 50, Y: 0}})
+	require.NoError(t, err)
+	assert.False(t, res.Anomaly)
+
+	// jump of 450 in a second is above max speed of 100
+	res, err = tr.PositionShip(PositionShip{ID: "1", Time: 1002, Point: Vector{X: 500, Y: 0}})
+	require.NoError(t, err)
+	assert.True(t, res.Anomaly)
+	assert.Equal(t, 100.0, res.Speed)
+
+	anomalies, err := tr.Anomalies("1")
+	require.NoError(t, err)
+	assert.Equal(t, []SpeedAnomaly{{Time: 1002, RawSpeed: 450, Speed: 100, MaxSpeed: 100}}, anomalies)
+
+	_, err = tr.Anomalies("2")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, tr.DeleteShip("1"))
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 1003, Point: Vector{X: 0, Y: 0}})
+	require.NoError(t, err)
+	anomalies, err = tr.Anomalies("1")
+	require.NoError(t, err)
+	assert.Empty(t, anomalies)
+}
+
+func TestClassMaxSpeed(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ClassMaxSpeed = map[string]float64{"tanker": 10}
+	tr := mustNewTraffic(t, cfg)
+
+	require.NoError(t, tr.SetProfile("tanker", VesselProfile{Type: "tanker", Length: 10}))
+	require.NoError(t, tr.SetProfile("pilot", VesselProfile{Type: "pilot", Length: 10}))
+	for _, id := range []string{"tanker", "pilot", "unknown"} {
+		_, err := tr.PositionShip(PositionShip{ID: id, Time: 1000, Point: Vector{X: 0, Y: 1000}})
+		require.NoError(t, err)
+		res, err := tr.PositionShip(PositionShip{ID: id, Time: 1001, Point: Vector{X: 20, Y: 1000}})
+		require.NoError(t, err)
+
+		anomalies, err := tr.Anomalies(id)
+		require.NoError(t, err)
+		if id != "tanker" {
+			assert.False(t, res.Anomaly, id)
+			assert.Equal(t, 20.0, res.Speed, id)
+			assert.Empty(t, anomalies, id)
+			continue
+		}
+		assert.True(t, res.Anomaly)
+		assert.Equal(t, 10.0, res.Speed)
+		assert.Equal(t, []SpeedAnomaly{{Time: 1001, RawSpeed: 20, Speed: 10, MaxSpeed: 10}}, anomalies)
+	}
+}
+
+func TestSpeedAnomalyLatePosition(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LatePositions = true
+	tr := mustNewTraffic(t, cfg)
+
+	_, err := tr.PositionShip(PositionShip{ID: "1", Time: 1000, Point: Vector{X: 0, Y: 0}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 1010, Point: Vector{X: 10, Y: 0}})
+	require.NoError(t, err)
+
+	// late fix far away makes both its own speed and speed of the next fix impossible
+	res, err := tr.PositionShip(PositionShip{ID: "1", Time: 1005, Point: Vector{X: 1000, Y: 0}})
+	require.NoError(t, err)
+	assert.True(t, res.Late)
+	assert.True(t, res.Anomaly)
+	assert.Equal(t, 100.0, res.Speed)
+
+	anomalies, err := tr.Anomalies("1")
+	require.NoError(t, err)
+	assert.Equal(t, []SpeedAnomaly{
+		{Time: 1005, RawSpeed: 200, Speed: 100, MaxSpeed: 100},
+		{Time: 1010, RawSpeed: 198, Speed: 100, MaxSpeed: 100},
+	}, anomalies)
+
+	// correction recalculates the same speeds, known anomalies are not repeated
+	_, err = tr.CorrectPosition(PositionShip{ID: "1", Time: 1005, Point: Vector{X: 1000, Y: 0}}, "jane")
+	require.NoError(t, err)
+	anomalies, err = tr.Anomalies("1")
+	require.NoError(t, err)
+	assert.Len(t, anomalies, 2)
+
+	// anomalies stay for investigation after the glitch is corrected
+	_, err = tr.CorrectPosition(PositionShip{ID: "1", Time: 1005, Point: Vector{X: 5, Y: 0}}, "jane")
+	require.NoError(t, err)
+	anomalies, err = tr.Anomalies("1")
+	require.NoError(t, err)
+	assert.Len(t, anomalies, 2)
+}
@@ -0,0 +1,137 @@
+package traffic
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditCorrect AuditAction = "correct"
+	AuditDelete  AuditAction = "delete"
+)
+
+type (
+	// AuditRecord describes manual change of the ship history, After is empty for deleted position
+	AuditRecord struct {
+		Time     time.Time       `json:"time"`
+		Operator string          `json:"operator"`
+		Action   AuditAction     `json:"action"`
+		ShipID   string          `json:"ship_id"`
+		Before   PositionRecord  `json:"before"`
+		After    *PositionRecord `json:"after,omitempty"`
+	}
+
+	// Correction is the state of the ship after its history was changed
+	Correction struct {
+		Status        Status
+		StatusChanged bool
+		Conflicts     []Conflict // set only if status was re-evaluated
+		Audit         AuditRecord
+	}
+)
+
+var ErrPositionNotFound = errors.New("position not found")
+
+// CorrectPosition moves the ship position at ps.Time to ps.Point.
+// Speeds of the position and of the next one are recalculated, statuses of the ship
+// and of the ships which could have seen the change are re-evaluated, change is recorded to the audit log.
+func (t *Traffic) CorrectPosition(ps PositionShip, operator string) (Correction, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i, before, err := t.findPosition(ps.ID, ps.Time)
+	if err != nil {
+		return Correction{}, err
+	}
+
+	res, err := t.splice(ps.ID, i, i+1, []ShipPosition{{Time: ps.Time, Position: ps.Point}})
+	if err != nil {
+		return Correction{}, err
+	}
+	after := NewPositionRecord(res.positions[0])
+
+	return t.audit(ps.ID, res, AuditRecord{
+		Operator: operator,
+		Action:   AuditCorrect,
+		Before:   NewPositionRecord(before),
+		After:    &after,
+	})
+}
+
+// DeletePosition removes the ship position at time, same as CorrectPosition
+// speed of the next position and statuses are recalculated and change is recorded to the audit log
+func (t *Traffic) DeletePosition(id string, time int, operator string) (Correction, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i, before, err := t.findPosition(id, time)
+	if err != nil {
+		return Correction{}, err
+	}
+
+	res, err := t.splice(id, i, i+1, nil)
+	if err != nil {
+		return Correction{}, err
+	}
+
+	return t.audit(id, res, AuditRecord{
+		Operator: operator,
+		Action:   AuditDelete,
+		Before:   NewPositionRecord(before),
+	})
+}
+
+// Audit returns changes of the ship history made by operators, oldest first
+func (t *Traffic) Audit(id string) []AuditRecord {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	records := []AuditRecord{}
+	t.store.RangeAudit(func(record AuditRecord) bool {
+		if record.ShipID == id {
+			records = append(records, record)
+		}
+		return true
+	})
+
+	return records
+}
+
+func (t *Traffic) findPosition(id string, time int) (int, ShipPosition, error) {
+	history, ok := t.store.History(id)
+	if !ok {
+		return 0, ShipPosition{}, ErrNotFound
+	}
+
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].Time >= time
+	})
+	if i == len(history) || history[i].Time != time {
+		return 0, ShipPosition{}, ErrPositionNotFound
+	}
+
+	return i, history[i], nil
+}
+
+// audit records change which is already applied and publishes its consequences
+func (t *Traffic) audit(id string, res spliceResult, record AuditRecord) (Correction, error) {
+	record.Time = time.Now().UTC()
+	record.ShipID = id
+	if err := t.store.AppendAudit(record); err != nil {
+		return Correction{}, err
+	}
+
+	if err := t.publishChanges(id, res); err != nil {
+		return Correction{}, err
+	}
+
+	return Correction{
+		Status:        res.status,
+		StatusChanged: res.status != res.previousStatus,
+		Conflicts:     res.conflicts,
+		Audit:         record,
+	}, nil
+}
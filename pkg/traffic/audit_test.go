@@ -0,0 +1,96 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletePosition(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+	sub := tr.Subscribe(EventFilter{MinStatus: Yellow})
+	defer sub.Close()
+
+	_, err := tr.PositionShip(PositionShip{ID: "2", Time: 100, Point: Vector{X: 3000, Y: 0}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 1000, Y: 0}})
+	require.NoError(t, err)
+	// GPS glitch puts ship 1 on collision course with ship 2
+	res, err := tr.PositionShip(PositionShip{ID: "1", Time: 110, Point: Vector{X: 2900, Y: 0}})
+	require.NoError(t, err)
+	require.Equal(t, Red, res.Status)
+
+	correction, err := tr.DeletePosition("1", 110, "jane")
+	require.NoError(t, err)
+	assert.Equal(t, Green, correction.Status)
+	assert.True(t, correction.StatusChanged)
+	assert.Equal(t, "jane", correction.Audit.Operator)
+	assert.Equal(t, AuditDelete, correction.Audit.Action)
+	assert.Equal(t, "1", correction.Audit.ShipID)
+	assert.Equal(t, PositionRecord{Time: 110, X: 2900, VX: 100}, correction.Audit.Before)
+	assert.Nil(t, correction.Audit.After)
+	assert.False(t, correction.Audit.Time.IsZero())
+
+	events := receive(t, sub)
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, EventStatus, last.Kind)
+	assert.Equal(t, Green, last.Status)
+	assert.Equal(t, 100, last.Time)
+
+	history, err := tr.GetShipPositions("1")
+	require.NoError(t, err)
+	assert.Equal(t, []ShipPosition{{Time: 100, Position: Vector{X: 1000, Y: 0}}}, history)
+
+	_, err = tr.DeletePosition("1", 110, "jane")
+	assert.ErrorIs(t, err, ErrPositionNotFound)
+	_, err = tr.DeletePosition("3", 110, "jane")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// ship without positions is still known and can be positioned again
+	_, err = tr.DeletePosition("1", 100, "jane")
+	require.NoError(t, err)
+	history, err = tr.GetShipPositions("1")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	res, err = tr.PositionShip(PositionShip{ID: "1", Time: 90, Point: Vector{X: 1000, Y: 0}})
+	require.NoError(t, err)
+	assert.Zero(t, res.Speed)
+
+	assert.Len(t, tr.Audit("1"), 2)
+	assert.Empty(t, tr.Audit("2"))
+}
+
+func TestCorrectPosition(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	for i, x := range []float64{1000, 5000, 1020, 1030} {
+		_, err := tr.PositionShip(PositionShip{ID: "1", Time: 100 + 10*i, Point: Vector{X: x, Y: 0}})
+		require.NoError(t, err)
+	}
+
+	correction, err := tr.CorrectPosition(PositionShip{ID: "1", Time: 110, Point: Vector{X: 1010, Y: 0}}, "jane")
+	require.NoError(t, err)
+	assert.False(t, correction.StatusChanged)
+	assert.Equal(t, AuditCorrect, correction.Audit.Action)
+	assert.Equal(t, PositionRecord{Time: 110, X: 5000, VX: 100}, correction.Audit.Before)
+	assert.Equal(t, &PositionRecord{Time: 110, X: 1010, VX: 1}, correction.Audit.After)
+
+	history, err := tr.GetShipPositions("1")
+	require.NoError(t, err)
+	assert.Equal(t, []ShipPosition{
+		{Time: 100, Position: Vector{X: 1000}},
+		{Time: 110, Position: Vector{X: 1010}, Speed: Vector{X: 1}},
+		{Time: 120, Position: Vector{X: 1020}, Speed: Vector{X: 1}},
+		{Time: 130, Position: Vector{X: 1030}, Speed: Vector{X: 1}},
+	}, history)
+
+	_, err = tr.CorrectPosition(PositionShip{ID: "1", Time: 115, Point: Vector{X: 1010, Y: 0}}, "jane")
+	assert.ErrorIs(t, err, ErrPositionNotFound)
+
+	audit := tr.Audit("1")
+	require.Len(t, audit, 1)
+	assert.Equal(t, correction.Audit, audit[0])
+}
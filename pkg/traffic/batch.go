@@ -0,0 +1,43 @@
+package traffic
+
+import (
+	"sort"
+	"time"
+)
+
+// BatchResult is the outcome of a single position of the batch
+type BatchResult struct {
+	Result PositionResult
+	Err    error
+}
+
+// PositionShips records batch of positions under a single lock acquisition.
+// Positions are processed in time order, so batch doesn't have to be sorted,
+// positions with the same time keep batch order. Failed position doesn't affect others.
+// Results are in the same order as positions.
+func (t *Traffic) PositionShips(batch []PositionShip) []BatchResult {
+	results := make([]BatchResult, len(batch))
+
+	order := make([]int, len(batch))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return batch[order[i]].Time < batch[order[j]].Time
+	})
+
+	now := int(time.Now().Unix())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, i := range order {
+		if batch[i].Time > now {
+			results[i].Err = ErrTimeInFuture
+			continue
+		}
+		results[i].Result, results[i].Err = t.positionShip(batch[i])
+	}
+
+	return results
+}
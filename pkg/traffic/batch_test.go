@@ -0,0 +1,46 @@
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPositionShips(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+	future := int(time.Now().Unix()) + 100
+
+	results := tr.PositionShips([]PositionShip{
+		{ID: "1", Time: 110, Point: Vector{X: 110, Y: 100}},
+		{ID: "2", Time: 105, Point: Vector{X: 110, Y: 100}},
+		{ID: "1", Time: 100, Point: Vector{X: 100, Y: 100}},
+		{ID: "1", Time: 110, Point: Vector{X: 500, Y: 500}},
+		{ID: "3", Time: future, Point: Vector{X: 500, Y: 500}},
+	})
+	require.Len(t, results, 5)
+
+	// processed as 1@100, 2@105, 1@110, 1@110
+	require.NoError(t, results[2].Err)
+	assert.Equal(t, Green, results[2].Result.Status)
+
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, Green, results[1].Result.Status)
+
+	require.NoError(t, results[0].Err)
+	assert.InDelta(t, 1.0, results[0].Result.Speed, epsilon)
+	// ship 1 moved towards ship 2 which was processed before it
+	assert.Equal(t, Red, results[0].Result.Status)
+
+	assert.ErrorIs(t, results[3].Err, ErrTimeInPast)
+	assert.ErrorIs(t, results[4].Err, ErrTimeInFuture)
+
+	history, err := tr.GetShipPositions("1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, Vector{X: 110, Y: 100}, history[1].Position)
+
+	_, err = tr.GetShipPositions("3")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
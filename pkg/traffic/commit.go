@@ -0,0 +1,84 @@
+package traffic
+
+import "slices"
+
+// commitLogSize is the number of last commits kept for validation,
+// evaluation which took longer is repeated under write lock
+const commitLogSize = 4096
+
+type (
+	// commitLog remembers ships changed by the last commits, so evaluation done
+	// under read lock can be validated against changes which happened after it
+	commitLog struct {
+		version uint64 // version of the last commit
+		base    uint64 // changes up to base are unknown
+		ids     []string
+	}
+
+	// evaluation of a position against state at version
+	evaluation struct {
+		version        uint64
+		speed          Vector
+		previousStatus Status
+		conflicts      []Conflict    // yellow and red conflicts with other ships
+		anomaly        *SpeedAnomaly // set if speed was clamped
+	}
+)
+
+func newCommitLog() *commitLog {
+	return &commitLog{
+		ids: make([]string, commitLogSize),
+	}
+}
+
+// record remembers that the ship was changed
+func (l *commitLog) record(id string) {
+	l.version++
+	l.ids[l.version%commitLogSize] = id
+	if l.version-l.base > commitLogSize {
+		l.base = l.version - commitLogSize
+	}
+}
+
+// reset invalidates all evaluations, used for changes which are not about a single ship
+func (l *commitLog) reset() {
+	l.version++
+	l.base = l.version
+}
+
+// since returns ships changed after version, false if changes are unknown
+func (l *commitLog) since(version uint64) ([]string, bool) {
+	if version < l.base {
+		return nil, false
+	}
+
+	ids := make([]string, 0, l.version-version)
+	for v := version + 1; v <= l.version; v++ {
+		ids = append(ids, l.ids[v%commitLogSize])
+	}
+
+	return ids, true
+}
+
+// revalidate replaces conflicts with changed ships by conflicts with their current positions,
+// ships which didn't change have the same history, so their conflicts are still valid
+func (e *evaluation) revalidate(t *Traffic, ps PositionShip, changed []string) {
+	if len(changed) == 0 {
+		return
+	}
+
+	set := make(map[string]struct{}, len(changed))
+	for _, id := range changed {
+		set[id] = struct{}{}
+	}
+
+	e.conflicts = slices.DeleteFunc(e.conflicts, func(c Conflict) bool {
+		_, ok := set[c.ID]
+		return ok
+	})
+	for id := range set {
+		if conflict, ok := t.evaluateShip(ps, e.speed, id); ok {
+			e.conflicts = append(e.conflicts, conflict)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package traffic
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitLog(t *testing.T) {
+	l := newCommitLog()
+
+	ids, ok := l.since(0)
+	require.True(t, ok)
+	assert.Empty(t, ids)
+
+	l.record("1")
+	l.record("2")
+	l.record("1")
+	ids, ok = l.since(1)
+	require.True(t, ok)
+	assert.Equal(t, []string{"2", "1"}, ids)
+
+	l.reset()
+	_, ok = l.since(3)
+	assert.False(t, ok)
+	ids, ok = l.since(4)
+	require.True(t, ok)
+	assert.Empty(t, ids)
+
+	for i := range commitLogSize + 1 {
+		l.record(fmt.Sprint(i))
+	}
+	_, ok = l.since(4)
+	assert.False(t, ok)
+	ids, ok = l.since(5)
+	require.True(t, ok)
+	assert.Len(t, ids, commitLogSize)
+	assert.Equal(t, fmt.Sprint(commitLogSize), ids[len(ids)-1])
+}
+
+// TestPositionShipConcurrent records positions from many goroutines and replays them
+// one by one in the order they were committed, results must be the same
+func TestPositionShipConcurrent(t *testing.T) {
+	const (
+		workers   = 8
+		positions = 500
+		roundSize = 50 // positions of every worker in a round, two events at most for each
+		area      = 300.0
+		// roundEnd is published after the round, drainer has got all events of the round when it gets this one
+		roundEnd EventKind = "round_end"
+	)
+
+	tr := mustNewTraffic(t, DefaultConfig())
+	sub := tr.Subscribe(EventFilter{})
+
+	var (
+		events []Event
+		drain  sync.WaitGroup
+		rounds = make(chan struct{})
+	)
+	drain.Add(1)
+	go func() {
+		defer drain.Done()
+		for e := range sub.C {
+			switch e.Kind {
+			case EventPosition:
+				events = append(events, e)
+			case roundEnd:
+				rounds <- struct{}{}
+			}
+		}
+	}()
+
+	base := int(time.Now().Unix()) - 100000
+	var clock atomic.Int64
+
+	rands := make([]*rand.Rand, workers)
+	for w := range rands {
+		rands[w] = rand.New(rand.NewPCG(uint64(w), 1))
+	}
+
+	// every round publishes less events than subscription buffer fits,
+	// so drainer starved by workers is not dropped as a slow subscriber
+	for round := range positions / roundSize {
+		var wg sync.WaitGroup
+		for w := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r := rands[w]
+				for i := round * roundSize; i < (round+1)*roundSize; i++ {
+					id := fmt.Sprintf("%d-%d", w, i%20)
+					if i%10 == 0 {
+						// same ships from different workers
+						id = fmt.Sprintf("shared-%d", i%3)
+					}
+					_, err := tr.PositionShip(PositionShip{
+						ID:    id,
+						Time:  base + int(clock.Add(1)),
+						Point: Vector{X: r.Float64() * area, Y: r.Float64() * area},
+					})
+					if err != nil {
+						assert.ErrorIs(t, err, ErrTimeInPast)
+					}
+
+					// readers don't block the writers
+					if i%50 == 0 {
+						_, err := tr.GetShips(ShipsQuery{})
+						assert.NoError(t, err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		tr.events.Publish(Event{Kind: roundEnd})
+		<-rounds
+	}
+
+	require.NoError(t, tr.Close())
+	drain.Wait()
+	// not dropped as a slow subscriber
+	require.ErrorIs(t, sub.Err(), ErrBusClosed)
+	require.NotEmpty(t, events)
+
+	replay := mustNewTraffic(t, DefaultConfig())
+	for _, e := range events {
+		res, err := replay.PositionShip(PositionShip{ID: e.ID, Time: e.Time, Point: e.Position})
+		require.NoError(t, err)
+		assert.Equal(t, e.Status, res.Status, "ship %s at %d", e.ID, e.Time)
+		assert.Equal(t, e.Conflicts, res.Conflicts, "ship %s at %d", e.ID, e.Time)
+	}
+}
+
+func BenchmarkPositionShipParallel(b *testing.B) {
+	// same density as BenchmarkEvaluateTrafficStatus
+	const (
+		ships = 10_000
+		area  = 10_000 * ships
+	)
+
+	positionLocked := func(t *Traffic, ps PositionShip) (PositionResult, error) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.positionShip(ps)
+	}
+
+	for _, bm := range []struct {
+		name     string
+		position func(t *Traffic, ps PositionShip) (PositionResult, error)
+	}{
+		{"optimistic", (*Traffic).PositionShip},
+		{"locked", positionLocked},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			t := mustNewTraffic(b, DefaultConfig())
+			randomFleet(rand.New(rand.NewPCG(1, 2)), t, ships, area)
+
+			var worker atomic.Int64
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				w := worker.Add(1)
+				r := rand.New(rand.NewPCG(uint64(w), 3))
+				for i := 0; pb.Next(); i++ {
+					// fleet is at 1010, every worker moves its own ships
+					ps := PositionShip{
+						ID:    fmt.Sprintf("bench-%d-%d", w, i%100),
+						Time:  1011 + i/100,
+						Point: Vector{X: r.Float64() * area, Y: r.Float64() * area},
+					}
+					if _, err := bm.position(t, ps); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Config defines safety envelope of the traffic
+type Config struct {
+	YellowThreshold  float64 // Distance threshold for yellow status
+	RedThreshold     float64 // Distance threshold for red status
+	MaxSpeed         float64 // Maximum speed of a ship in units per second
+	PredictionWindow int     // How far ahead collisions are predicted in seconds
+	LatePositions    bool    // Insert positions older than the last one into history instead of rejecting them
+
+	ClassMaxSpeed map[string]float64 // Maximum speed of the vessel class by profile type, can't exceed MaxSpeed
+
+	Retention          int // Positions older than this many seconds are removed by Compact, 0 keeps everything
+	DownsampleAfter    int // Positions older than this many seconds are downsampled by Compact, 0 disables downsampling
+	DownsampleInterval int // Downsampled history keeps one position per this many seconds
+}
+
+var ErrInvalidConfig = errors.New("invalid traffic config")
+
+func DefaultConfig() Config {
+	return Config{
+		YellowThreshold:  2,
+		RedThreshold:     1,
+		MaxSpeed:         100,
+		PredictionWindow: 60,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.RedThreshold <= 0 {
+		return fmt.Errorf("%w: red threshold must be positive", ErrInvalidConfig)
+	}
+
+	if c.YellowThreshold < c.RedThreshold {
+		return fmt.Errorf("%w: yellow threshold can not be less than red threshold", ErrInvalidConfig)
+	}
+
+	if c.MaxSpeed <= 0 {
+		return fmt.Errorf("%w: max speed must be positive", ErrInvalidConfig)
+	}
+
+	for class, maxSpeed := range c.ClassMaxSpeed {
+		// spatial index relies on MaxSpeed being the limit of every ship
+		if maxSpeed <= 0 || maxSpeed > c.MaxSpeed {
+			return fmt.Errorf("%w: max speed of %q must be positive and not above max speed", ErrInvalidConfig, class)
+		}
+	}
+
+	if c.PredictionWindow <= 0 {
+		return fmt.Errorf("%w: prediction window must be positive", ErrInvalidConfig)
+	}
+
+	if c.Retention < 0 || c.DownsampleAfter < 0 {
+		return fmt.Errorf("%w: retention and downsample age can not be negative", ErrInvalidConfig)
+	}
+
+	if c.DownsampleAfter > 0 && c.DownsampleInterval <= 0 {
+		return fmt.Errorf("%w: downsample interval must be positive", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// statusForDist compares distance with thresholds extended by margin
+func (c Config) statusForDist(minDist, margin float64) Status {
+	if minDist < c.RedThreshold+margin {
+		return Red
+	}
+	if minDist < c.YellowThreshold+margin {
+		return Yellow
+	}
+
+	return Green
+}
@@ -0,0 +1,59 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		valid  bool
+	}{
+		{name: "default", modify: func(cfg *Config) {}, valid: true},
+		{name: "yellow equals red", modify: func(cfg *Config) { cfg.YellowThreshold = cfg.RedThreshold }, valid: true},
+		{name: "zero red", modify: func(cfg *Config) { cfg.RedThreshold = 0 }},
+		{name: "yellow less than red", modify: func(cfg *Config) { cfg.YellowThreshold = 0.5 }},
+		{name: "zero max speed", modify: func(cfg *Config) { cfg.MaxSpeed = 0 }},
+		{name: "class max speed", modify: func(cfg *Config) { cfg.ClassMaxSpeed = map[string]float64{"tanker": 20} }, valid: true},
+		{name: "zero class max speed", modify: func(cfg *Config) { cfg.ClassMaxSpeed = map[string]float64{"tanker": 0} }},
+		{name: "class max speed above max speed", modify: func(cfg *Config) { cfg.ClassMaxSpeed = map[string]float64{"pilot": 101} }},
+		{name: "negative prediction window", modify: func(cfg *Config) { cfg.PredictionWindow = -1 }},
+		{name: "negative retention", modify: func(cfg *Config) { cfg.Retention = -1 }},
+		{name: "downsample without interval", modify: func(cfg *Config) { cfg.DownsampleAfter = 3600 }},
+		{name: "downsample", modify: func(cfg *Config) { cfg.DownsampleAfter, cfg.DownsampleInterval = 3600, 60 }, valid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+			}
+
+			_, err = NewTraffic(cfg, NewMemoryStore())
+			assert.Equal(t, tt.valid, err == nil)
+		})
+	}
+}
+
+func TestStatusForDist(t *testing.T) {
+	cfg := Config{YellowThreshold: 500, RedThreshold: 100}
+
+	assert.Equal(t, Red, cfg.statusForDist(99, 0))
+	assert.Equal(t, Yellow, cfg.statusForDist(100, 0))
+	assert.Equal(t, Yellow, cfg.statusForDist(499, 0))
+	assert.Equal(t, Green, cfg.statusForDist(500, 0))
+
+	// combined domains of the ships extend both thresholds
+	assert.Equal(t, Red, cfg.statusForDist(149, 50))
+	assert.Equal(t, Yellow, cfg.statusForDist(150, 50))
+	assert.Equal(t, Green, cfg.statusForDist(550, 50))
+}
@@ -0,0 +1,222 @@
+package traffic
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+const (
+	EventPosition EventKind = "position"
+	EventStatus   EventKind = "status"
+)
+
+// subscriptionBuffer is the number of events subscriber can lag behind before it is dropped
+const subscriptionBuffer = 1024
+
+var (
+	ErrSlowSubscriber = errors.New("subscriber is too slow, events were dropped")
+	ErrBusClosed      = errors.New("event bus is closed")
+	ErrInvalidArea    = errors.New("area min corner must not be greater than max corner")
+)
+
+type (
+	EventKind string
+
+	// Event is published for every accepted position and every change of ship status.
+	// Status events carry status before the change in PreviousStatus,
+	// ship seen for the first time is considered green before.
+	Event struct {
+		Seq            uint64 // increasing number of the event, gaps mean events were filtered out
+		Kind           EventKind
+		ID             string
+		Time           int
+		Position       Vector
+		Speed          float64
+		Status         Status
+		PreviousStatus Status
+		Conflicts      []Conflict
+		Late           bool // position is older than the last position of the ship
+	}
+
+	// EventFilter selects events for subscription, zero value matches everything
+	EventFilter struct {
+		// IDs and Area select ships, event matches if ship is in IDs or its position is in Area,
+		// all ships match when both are empty
+		IDs  []string
+		Area *Area
+		// MinStatus drops events of ships below the status,
+		// status event also matches when ship leaves the status
+		MinStatus Status
+	}
+
+	// Area is a bounding box, edges are included
+	Area struct {
+		Min Vector
+		Max Vector
+	}
+
+	// Subscription receives events matching the filter until it is closed,
+	// C is closed when subscription ends, Err tells why
+	Subscription struct {
+		C <-chan Event
+
+		bus    *EventBus
+		c      chan Event
+		filter EventFilter
+		inArea map[string]struct{} // ships last delivered inside the area
+		err    error
+	}
+
+	// EventBus fans out events to subscribers without blocking the publisher,
+	// subscriber which can't keep up is dropped with ErrSlowSubscriber
+	EventBus struct {
+		mu     sync.Mutex
+		seq    uint64
+		subs   map[*Subscription]struct{}
+		closed bool
+	}
+)
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+func (f EventFilter) Match(e Event) bool {
+	return f.matchStatus(e) && f.matchShip(e)
+}
+
+func (f EventFilter) matchStatus(e Event) bool {
+	return e.Status >= f.MinStatus || (e.Kind == EventStatus && e.PreviousStatus >= f.MinStatus)
+}
+
+func (f EventFilter) matchShip(e Event) bool {
+	if len(f.IDs) == 0 && f.Area == nil {
+		return true
+	}
+
+	return slices.Contains(f.IDs, e.ID) || (f.Area != nil && f.Area.Contains(e.Position))
+}
+
+func (a Area) Contains(p Vector) bool {
+	return p.X >= a.Min.X && p.X <= a.Max.X && p.Y >= a.Min.Y && p.Y <= a.Max.Y
+}
+
+// Validate checks that min corner is not greater than max corner
+func (a Area) Validate() error {
+	if a.Min.X > a.Max.X || a.Min.Y > a.Max.Y {
+		return ErrInvalidArea
+	}
+	return nil
+}
+
+func (b *EventBus) Subscribe(filter EventFilter) *Subscription {
+	c := make(chan Event, subscriptionBuffer)
+	s := &Subscription{
+		C:      c,
+		bus:    b,
+		c:      c,
+		filter: filter,
+		inArea: make(map[string]struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		s.err = ErrBusClosed
+		close(c)
+		return s
+	}
+	b.subs[s] = struct{}{}
+
+	return s
+}
+
+// Publish assigns sequence number to the event and sends it to all matching subscribers
+func (b *EventBus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.Seq = b.seq
+	for s := range b.subs {
+		if !s.match(e) {
+			continue
+		}
+
+		select {
+		case s.c <- e:
+		default:
+			b.unsubscribe(s, ErrSlowSubscriber)
+		}
+	}
+}
+
+// Close ends all subscriptions, publishing after close is a no-op
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		b.unsubscribe(s, ErrBusClosed)
+	}
+}
+
+func (b *EventBus) unsubscribe(s *Subscription, err error) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+
+	delete(b.subs, s)
+	s.err = err
+	close(s.c)
+}
+
+// match filters event and tracks ships in the area,
+// ship which leaves the area gets one more event, so subscriber knows where it went
+func (s *Subscription) match(e Event) bool {
+	if !s.filter.matchStatus(e) {
+		return false
+	}
+
+	_, wasInArea := s.inArea[e.ID]
+	if s.filter.Area != nil {
+		if s.filter.Area.Contains(e.Position) {
+			s.inArea[e.ID] = struct{}{}
+		} else {
+			delete(s.inArea, e.ID)
+		}
+	}
+
+	return wasInArea || s.filter.matchShip(e)
+}
+
+// SetFilter replaces filter of the subscription, no events are lost while filter is changed
+func (s *Subscription) SetFilter(filter EventFilter) {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.filter = filter
+	s.inArea = make(map[string]struct{})
+}
+
+// Close stops the subscription, safe to call more than once
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.unsubscribe(s, nil)
+}
+
+// Err returns reason subscription ended, nil if it was closed by subscriber,
+// valid after C is closed
+func (s *Subscription) Err() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	return s.err
+}
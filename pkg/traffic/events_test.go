@@ -0,0 +1,174 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, sub *Subscription) []Event {
+	t.Helper()
+
+	var events []Event
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestEventFilterMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter EventFilter
+		event  Event
+		match  bool
+	}{
+		{"empty filter", EventFilter{}, Event{Kind: EventPosition, ID: "1"}, true},
+		{"id matches", EventFilter{IDs: []string{"2", "1"}}, Event{Kind: EventPosition, ID: "1"}, true},
+		{"id doesn't match", EventFilter{IDs: []string{"2"}}, Event{Kind: EventPosition, ID: "1"}, false},
+		{"status below", EventFilter{MinStatus: Red}, Event{Kind: EventPosition, Status: Yellow}, false},
+		{"status above", EventFilter{MinStatus: Yellow}, Event{Kind: EventPosition, Status: Red}, true},
+		{"position from red", EventFilter{MinStatus: Red}, Event{Kind: EventPosition, Status: Green, PreviousStatus: Red}, false},
+		{"status leaves red", EventFilter{MinStatus: Red}, Event{Kind: EventStatus, Status: Green, PreviousStatus: Red}, true},
+		{"status below both", EventFilter{MinStatus: Red}, Event{Kind: EventStatus, Status: Green, PreviousStatus: Yellow}, false},
+		{"in area", EventFilter{Area: &Area{Max: Vector{X: 10, Y: 10}}}, Event{Kind: EventPosition, Position: Vector{X: 10, Y: 0}}, true},
+		{"out of area", EventFilter{Area: &Area{Max: Vector{X: 10, Y: 10}}}, Event{Kind: EventPosition, Position: Vector{X: 11, Y: 0}}, false},
+		{"id or area", EventFilter{IDs: []string{"1"}, Area: &Area{Max: Vector{X: 10, Y: 10}}}, Event{Kind: EventPosition, ID: "1", Position: Vector{X: 11, Y: 0}}, true},
+		{"area and status", EventFilter{Area: &Area{Max: Vector{X: 10, Y: 10}}, MinStatus: Yellow}, Event{Kind: EventPosition, Position: Vector{X: 1, Y: 1}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.match, tt.filter.Match(tt.event))
+		})
+	}
+}
+
+func TestEventBusSlowSubscriber(t *testing.T) {
+	bus := NewEventBus()
+	slow := bus.Subscribe(EventFilter{})
+	other := bus.Subscribe(EventFilter{IDs: []string{"2"}})
+
+	for range subscriptionBuffer + 1 {
+		bus.Publish(Event{Kind: EventPosition, ID: "1"})
+	}
+
+	assert.Len(t, receive(t, slow), subscriptionBuffer)
+	assert.ErrorIs(t, slow.Err(), ErrSlowSubscriber)
+
+	// publisher is not blocked and other subscribers are not affected
+	bus.Publish(Event{Kind: EventPosition, ID: "2"})
+	events := receive(t, other)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(subscriptionBuffer+2), events[0].Seq)
+	assert.NoError(t, other.Err())
+
+	other.Close()
+	other.Close()
+	_, ok := <-other.C
+	assert.False(t, ok)
+	assert.NoError(t, other.Err())
+
+	bus.Close()
+	closed := bus.Subscribe(EventFilter{})
+	_, ok = <-closed.C
+	assert.False(t, ok)
+	assert.ErrorIs(t, closed.Err(), ErrBusClosed)
+}
+
+func TestSubscriptionArea(t *testing.T) {
+	bus := NewEventBus()
+	sub := bus.Subscribe(EventFilter{Area: &Area{Min: Vector{X: -10, Y: -10}, Max: Vector{X: 10, Y: 10}}})
+	defer sub.Close()
+
+	publish := func(id string, x, y float64) {
+		bus.Publish(Event{Kind: EventPosition, ID: id, Position: Vector{X: x, Y: y}})
+	}
+
+	publish("1", 20, 20) // outside
+	publish("1", 5, 5)   // enters
+	publish("1", 20, 5)  // leaves, delivered once
+	publish("1", 30, 5)  // outside
+	publish("2", 0, 0)
+
+	var got []Vector
+	for _, e := range receive(t, sub) {
+		got = append(got, e.Position)
+	}
+	assert.Equal(t, []Vector{{X: 5, Y: 5}, {X: 20, Y: 5}, {X: 0, Y: 0}}, got)
+
+	// new filter forgets ships in the old area
+	sub.SetFilter(EventFilter{IDs: []string{"1"}})
+	publish("2", 20, 20)
+	publish("1", 30, 5)
+	events := receive(t, sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, "1", events[0].ID)
+
+	assert.ErrorIs(t, Area{Min: Vector{X: 1}}.Validate(), ErrInvalidArea)
+	assert.NoError(t, Area{Min: Vector{X: 1}, Max: Vector{X: 1}}.Validate())
+}
+
+func TestTrafficPublishesEvents(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+	all := tr.Subscribe(EventFilter{})
+	red := tr.Subscribe(EventFilter{MinStatus: Red})
+	defer all.Close()
+	defer red.Close()
+
+	_, err := tr.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 100, Y: 100}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "2", Time: 100, Point: Vector{X: 100, Y: 100}})
+	require.NoError(t, err)
+	// ship 2 moves away, ship 1 keeps red status until its next position
+	_, err = tr.PositionShip(PositionShip{ID: "2", Time: 101, Point: Vector{X: 150, Y: 150}})
+	require.NoError(t, err)
+	// time in past is not accepted, nothing is published
+	_, err = tr.PositionShip(PositionShip{ID: "2", Time: 101, Point: Vector{X: 150, Y: 150}})
+	require.ErrorIs(t, err, ErrTimeInPast)
+
+	events := receive(t, all)
+	require.Len(t, events, 5)
+
+	kinds := make([]EventKind, len(events))
+	for i, e := range events {
+		kinds[i] = e.Kind
+		if i > 0 {
+			assert.Greater(t, e.Seq, events[i-1].Seq)
+		}
+	}
+	assert.Equal(t, []EventKind{EventPosition, EventPosition, EventStatus, EventPosition, EventStatus}, kinds)
+
+	assert.Equal(t, "2", events[2].ID)
+	assert.Equal(t, Red, events[2].Status)
+	assert.Equal(t, Green, events[2].PreviousStatus)
+	assert.Len(t, events[2].Conflicts, 1)
+
+	assert.Equal(t, "2", events[4].ID)
+	assert.Equal(t, Green, events[4].Status)
+	assert.Equal(t, Red, events[4].PreviousStatus)
+	assert.Equal(t, 101, events[4].Time)
+
+	events = receive(t, red)
+	require.Len(t, events, 3)
+	assert.Equal(t, []EventKind{EventPosition, EventStatus, EventStatus}, []EventKind{events[0].Kind, events[1].Kind, events[2].Kind})
+}
+
+func TestTrafficCloseEndsSubscriptions(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+	sub := tr.Subscribe(EventFilter{})
+
+	require.NoError(t, tr.Close())
+
+	_, ok := <-sub.C
+	assert.False(t, ok)
+	assert.ErrorIs(t, sub.Err(), ErrBusClosed)
+}
@@ -0,0 +1,67 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	earthRadius = 6371008.8 // mean radius in metres
+	// Knot in metres per second
+	Knot = 1852.0 / 3600
+)
+
+var ErrInvalidProjection = errors.New("invalid projection")
+
+// Projection maps WGS84 coordinates to the traffic plane in metres, x points east and y points north.
+// Equirectangular projection around origin is precise enough within tens of kilometres from it,
+// distances further away are stretched or shrunk by cos(lat) / cos(origin lat).
+type Projection struct {
+	OriginLat float64
+	OriginLon float64
+}
+
+// Validate checks the origin, east-west scale of the projection degenerates at the poles
+func (p Projection) Validate() error {
+	if !(p.OriginLat > -90 && p.OriginLat < 90) {
+		return fmt.Errorf("%w: origin latitude must be within (-90, 90)", ErrInvalidProjection)
+	}
+	if !(p.OriginLon >= -180 && p.OriginLon <= 180) {
+		return fmt.Errorf("%w: origin longitude must be within [-180, 180]", ErrInvalidProjection)
+	}
+
+	return nil
+}
+
+func (p Projection) Project(lat, lon float64) Vector {
+	dLon := lon - p.OriginLon
+	// shortest way around antimeridian
+	if dLon > 180 {
+		dLon -= 360
+	} else if dLon < -180 {
+		dLon += 360
+	}
+
+	return Vector{
+		X: earthRadius * degToRad(dLon) * math.Cos(degToRad(p.OriginLat)),
+		Y: earthRadius * degToRad(lat-p.OriginLat),
+	}
+}
+
+// Unproject is the inverse of Project, longitude is normalized to [-180, 180)
+func (p Projection) Unproject(v Vector) (lat, lon float64) {
+	lat = p.OriginLat + radToDeg(v.Y/earthRadius)
+	lon = p.OriginLon + radToDeg(v.X/(earthRadius*math.Cos(degToRad(p.OriginLat))))
+	lon = math.Mod(lon+540, 360) - 180
+
+	return lat, lon
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
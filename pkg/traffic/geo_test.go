@@ -0,0 +1,39 @@
+package traffic
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjection(t *testing.T) {
+	p := Projection{OriginLat: 60, OriginLon: 10}
+
+	assert.Equal(t, Vector{}, p.Project(60, 10))
+
+	// degree of latitude is ~111km everywhere, degree of longitude is ~55.6km at 60N
+	assert.InDelta(t, 111195, p.Project(61, 10).Y, 1)
+	assert.InDelta(t, 55597, p.Project(60, 11).X, 1)
+
+	for _, latLon := range [][2]float64{{60.5, 10.3}, {59.1, 9.2}, {-10, -170}} {
+		lat, lon := p.Unproject(p.Project(latLon[0], latLon[1]))
+		assert.InDelta(t, latLon[0], lat, 1e-9)
+		assert.InDelta(t, latLon[1], lon, 1e-9)
+	}
+
+	// across antimeridian
+	p = Projection{OriginLon: 179.999}
+	v := p.Project(0, -179.999)
+	assert.InDelta(t, 222.4, v.X, 0.1)
+	_, lon := p.Unproject(v)
+	assert.InDelta(t, -179.999, lon, 1e-9)
+}
+
+func TestProjectionValidate(t *testing.T) {
+	assert.NoError(t, Projection{OriginLat: 60, OriginLon: 10}.Validate())
+	assert.NoError(t, Projection{OriginLon: 180}.Validate())
+	for _, p := range []Projection{{OriginLat: 90}, {OriginLat: -91}, {OriginLon: 181}, {OriginLat: math.NaN()}} {
+		assert.ErrorIs(t, p.Validate(), ErrInvalidProjection, "%+v", p)
+	}
+}
@@ -0,0 +1,280 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+const KindGeofence ConflictKind = "geofence"
+
+type GeofenceAlert string
+
+const (
+	// GeofenceEnter ship track crossed into the geofence since the previous position
+	GeofenceEnter GeofenceAlert = "enter"
+	// GeofenceExit ship track crossed out of the geofence since the previous position
+	GeofenceExit GeofenceAlert = "exit"
+	// GeofenceInside ship was and still is inside the geofence
+	GeofenceInside GeofenceAlert = "inside"
+	// GeofencePredictedEnter ship is going to enter the geofence within prediction window
+	GeofencePredictedEnter GeofenceAlert = "predicted_enter"
+)
+
+// Geofence is a restricted area: anchorage, military zone, shallow water.
+// Polygon is a simple polygon, vertices go in order and the last one connects to the first.
+// Status is the status of the ship inside the geofence, yellow for advisory areas, red for closed ones.
+type Geofence struct {
+	ID      string
+	Polygon []Vector
+	Status  Status
+}
+
+var (
+	ErrGeofenceNotFound = errors.New("geofence not found")
+	ErrGeofenceExists   = errors.New("geofence already exists")
+	ErrInvalidGeofence  = errors.New("invalid geofence")
+)
+
+func (g Geofence) Validate() error {
+	if g.ID == "" {
+		return fmt.Errorf("%w: id can not be empty", ErrInvalidGeofence)
+	}
+
+	if len(g.Polygon) < 3 {
+		return fmt.Errorf("%w: polygon must have at least 3 vertices", ErrInvalidGeofence)
+	}
+
+	if math.Abs(g.area()) < epsilon {
+		return fmt.Errorf("%w: polygon must have non-zero area", ErrInvalidGeofence)
+	}
+
+	if g.Status != Yellow && g.Status != Red {
+		return fmt.Errorf("%w: status must be yellow or red", ErrInvalidGeofence)
+	}
+
+	return nil
+}
+
+// area is the signed shoelace area of the polygon
+func (g Geofence) area() float64 {
+	area := 0.0
+	for i, a := range g.Polygon {
+		b := g.Polygon[(i+1)%len(g.Polygon)]
+		area += a.Cross(b)
+	}
+
+	return area / 2
+}
+
+// contains tells if point is inside the polygon, ray casting
+func (g Geofence) contains(p Vector) bool {
+	inside := false
+	for i, a := range g.Polygon {
+		b := g.Polygon[(i+1)%len(g.Polygon)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// crossings returns sorted fractions of the segment from a to b where it crosses polygon edges
+func (g Geofence) crossings(a, b Vector) []float64 {
+	r := b.Subtract(a)
+
+	var res []float64
+	for i, q := range g.Polygon {
+		e := g.Polygon[(i+1)%len(g.Polygon)].Subtract(q)
+		denom := r.Cross(e)
+		if math.Abs(denom) < epsilon {
+			continue // parallel
+		}
+
+		qa := q.Subtract(a)
+		s := qa.Cross(e) / denom
+		u := qa.Cross(r) / denom
+		if s >= 0 && s <= 1 && u >= 0 && u <= 1 {
+			res = append(res, s)
+		}
+	}
+	slices.Sort(res)
+
+	// track through a vertex crosses both its edges
+	return slices.CompactFunc(res, func(x, y float64) bool {
+		return math.Abs(x-y) < epsilon
+	})
+}
+
+// Geofences returns all geofences sorted by id
+func (t *Traffic) Geofences() []Geofence {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var geofences []Geofence
+	t.store.RangeGeofences(func(g Geofence) bool {
+		geofences = append(geofences, g)
+		return true
+	})
+	sort.Slice(geofences, func(i, j int) bool {
+		return geofences[i].ID < geofences[j].ID
+	})
+
+	return geofences
+}
+
+func (t *Traffic) Geofence(id string) (Geofence, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	g, ok := t.store.Geofence(id)
+	if !ok {
+		return Geofence{}, ErrGeofenceNotFound
+	}
+
+	return g, nil
+}
+
+// AddGeofence registers new geofence, it applies to positions recorded after the call
+func (t *Traffic) AddGeofence(g Geofence) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.store.Geofence(g.ID); ok {
+		return ErrGeofenceExists
+	}
+
+	return t.store.PutGeofence(g)
+}
+
+// UpdateGeofence replaces existing geofence, it applies to positions recorded after the call
+func (t *Traffic) UpdateGeofence(g Geofence) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.store.Geofence(g.ID); !ok {
+		return ErrGeofenceNotFound
+	}
+
+	return t.store.PutGeofence(g)
+}
+
+func (t *Traffic) DeleteGeofence(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.store.Geofence(id); !ok {
+		return ErrGeofenceNotFound
+	}
+
+	return t.store.DeleteGeofence(id)
+}
+
+// evaluateGeofences returns geofence alerts for the track from the previous position of the ship
+// to ps and for its predicted trajectory, must be called under read or write lock
+func (t *Traffic) evaluateGeofences(ps PositionShip, speed Vector) []Conflict {
+	prev, hasPrev := t.previousPosition(ps.ID, ps.Time)
+
+	var conflicts []Conflict
+	t.store.RangeGeofences(func(g Geofence) bool {
+		if hasPrev {
+			conflicts = append(conflicts, evaluateTrack(ps, prev, g)...)
+		} else if g.contains(ps.Point) {
+			conflicts = append(conflicts, geofenceConflict(g.ID, GeofenceInside, g.Status, float64(ps.Time), ps.Point))
+		}
+
+		if conflict, ok := evaluatePredictedEntry(ps, speed, g, t.cfg.PredictionWindow); ok {
+			conflicts = append(conflicts, conflict)
+		}
+		return true
+	})
+
+	return conflicts
+}
+
+// evaluateTrack finds where straight track from prev to ps crossed the geofence.
+// Alert has the status of the geofence while the ship is inside, track which left the geofence again before ps is only reported.
+func evaluateTrack(ps PositionShip, prev ShipPosition, g Geofence) []Conflict {
+	wasInside, isInside := g.contains(prev.Position), g.contains(ps.Point)
+	crossings := g.crossings(prev.Position, ps.Point)
+	if len(crossings) == 0 {
+		if isInside {
+			return []Conflict{geofenceConflict(g.ID, GeofenceInside, g.Status, float64(ps.Time), ps.Point)}
+		}
+		return nil
+	}
+
+	// alert at the fraction s of the track, the last entry has the status of the geofence if the ship is still inside
+	alert := func(s float64, entered bool) Conflict {
+		time := float64(prev.Time) + s*float64(ps.Time-prev.Time)
+		p := prev.Position.Add(ps.Point.Subtract(prev.Position).ScalarMultiply(s))
+		if !entered {
+			return geofenceConflict(g.ID, GeofenceExit, Green, time, p)
+		}
+		if isInside && s == crossings[len(crossings)-1] {
+			return geofenceConflict(g.ID, GeofenceEnter, g.Status, time, p)
+		}
+		return geofenceConflict(g.ID, GeofenceEnter, Green, time, p)
+	}
+
+	// first crossing leaves the state ship was in, last one gets it to the state it is in now
+	conflicts := []Conflict{alert(crossings[0], !wasInside)}
+	if len(crossings) > 1 {
+		conflicts = append(conflicts, alert(crossings[len(crossings)-1], isInside))
+	}
+
+	return conflicts
+}
+
+// evaluatePredictedEntry finds where linear trajectory of the ship outside the geofence enters it within window
+func evaluatePredictedEntry(ps PositionShip, speed Vector, g Geofence, window int) (Conflict, bool) {
+	if speed.MagnitudeSquared() < epsilon || g.contains(ps.Point) {
+		return Conflict{}, false
+	}
+
+	end := ps.Point.Add(speed.ScalarMultiply(float64(window)))
+	crossings := g.crossings(ps.Point, end)
+	if len(crossings) == 0 {
+		return Conflict{}, false
+	}
+
+	s := crossings[0]
+	return geofenceConflict(g.ID, GeofencePredictedEnter, Yellow, float64(ps.Time)+s*float64(window), ps.Point.Add(end.Subtract(ps.Point).ScalarMultiply(s))), true
+}
+
+// geofenceConflict is an alert at the point of the ship track, distance to the geofence is zero there
+func geofenceConflict(id string, alert GeofenceAlert, status Status, time float64, p Vector) Conflict {
+	return Conflict{
+		Kind:          KindGeofence,
+		ID:            id,
+		Alert:         alert,
+		Status:        status,
+		Time:          time,
+		Position:      p,
+		OtherPosition: p,
+	}
+}
+
+// previousPosition returns the last position of the ship before time
+func (t *Traffic) previousPosition(id string, time int) (ShipPosition, bool) {
+	history, _ := t.store.History(id)
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].Time >= time
+	})
+	if i == 0 {
+		return ShipPosition{}, false
+	}
+
+	return history[i-1], true
+}
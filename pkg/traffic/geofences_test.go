@@ -0,0 +1,151 @@
+package traffic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func square(id string, min, max float64) Geofence {
+	return Geofence{ID: id, Polygon: []Vector{{X: min, Y: min}, {X: max, Y: min}, {X: max, Y: max}, {X: min, Y: max}}, Status: Red}
+}
+
+func TestGeofenceValidate(t *testing.T) {
+	assert.NoError(t, square("zone", 0, 10).Validate())
+	assert.ErrorIs(t, square("", 0, 10).Validate(), ErrInvalidGeofence)
+	assert.ErrorIs(t, Geofence{ID: "zone", Polygon: []Vector{{X: 0, Y: 0}, {X: 1, Y: 1}}}.Validate(), ErrInvalidGeofence)
+	// all vertices on a line
+	assert.ErrorIs(t, Geofence{ID: "zone", Polygon: []Vector{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 2}}, Status: Red}.Validate(), ErrInvalidGeofence)
+	// green geofence would never alert
+	zone := square("zone", 0, 10)
+	zone.Status = Green
+	assert.ErrorIs(t, zone.Validate(), ErrInvalidGeofence)
+}
+
+func TestGeofenceGeometry(t *testing.T) {
+	// L-shaped zone
+	g := Geofence{ID: "zone", Polygon: []Vector{{X: 0, Y: 0}, {X: 20, Y: 0}, {X: 20, Y: 10}, {X: 10, Y: 10}, {X: 10, Y: 20}, {X: 0, Y: 20}}}
+
+	assert.True(t, g.contains(Vector{X: 5, Y: 15}))
+	assert.True(t, g.contains(Vector{X: 15, Y: 5}))
+	assert.False(t, g.contains(Vector{X: 15, Y: 15}))
+	assert.False(t, g.contains(Vector{X: -1, Y: 5}))
+
+	assert.Equal(t, []float64{0.25, 0.5}, g.crossings(Vector{X: -10, Y: 15}, Vector{X: 30, Y: 15}))
+	assert.Empty(t, g.crossings(Vector{X: 15, Y: 15}, Vector{X: 30, Y: 30}))
+	// through the vertex
+	crossings := g.crossings(Vector{X: 30, Y: -10}, Vector{X: 15, Y: 5})
+	require.Len(t, crossings, 1)
+	assert.InDelta(t, 2.0/3, crossings[0], 1e-9)
+}
+
+func TestPositionShipGeofence(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+	require.NoError(t, tr.AddGeofence(square("zone", 100, 200)))
+
+	position := func(time int, x float64) PositionResult {
+		res, err := tr.PositionShip(PositionShip{ID: "1", Time: time, Point: Vector{X: x, Y: 150}})
+		require.NoError(t, err)
+		return res
+	}
+	assertAlert := func(res PositionResult, status Status, alerts ...GeofenceAlert) {
+		t.Helper()
+		assert.Equal(t, status, res.Status)
+		var actual []GeofenceAlert
+		for _, c := range res.Conflicts {
+			assert.Equal(t, KindGeofence, c.Kind)
+			assert.Equal(t, "zone", c.ID)
+			actual = append(actual, c.Alert)
+		}
+		assert.Equal(t, alerts, actual)
+	}
+
+	assertAlert(position(100, 50), Green)
+
+	// heading to the zone at 10 per second
+	res := position(101, 60)
+	assertAlert(res, Yellow, GeofencePredictedEnter)
+	assert.Equal(t, 105.0, res.Conflicts[0].Time)
+	assert.Equal(t, Vector{X: 100, Y: 150}, res.Conflicts[0].Position)
+
+	res = position(102, 150)
+	assertAlert(res, Red, GeofenceEnter)
+	assert.InDelta(t, 101+4.0/9, res.Conflicts[0].Time, 1e-9)
+	assert.Equal(t, Vector{X: 100, Y: 150}, res.Conflicts[0].Position)
+
+	assertAlert(position(103, 160), Red, GeofenceInside)
+
+	res = position(104, 250)
+	assertAlert(res, Green, GeofenceExit)
+	assert.Equal(t, Vector{X: 200, Y: 150}, res.Conflicts[0].Position)
+
+	// passed through the zone between positions
+	res = position(106, 50)
+	assertAlert(res, Green, GeofenceEnter, GeofenceExit)
+	assert.Equal(t, Vector{X: 200, Y: 150}, res.Conflicts[0].Position)
+	assert.Equal(t, Vector{X: 100, Y: 150}, res.Conflicts[1].Position)
+
+	// new ship in the zone
+	res, err := tr.PositionShip(PositionShip{ID: "2", Time: 106, Point: Vector{X: 180, Y: 120}})
+	require.NoError(t, err)
+	assert.Equal(t, Red, res.Status)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, GeofenceInside, res.Conflicts[0].Alert)
+}
+
+func TestPositionShipYellowGeofence(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+	zone := square("anchorage", 100, 200)
+	zone.Status = Yellow
+	require.NoError(t, tr.AddGeofence(zone))
+
+	for i, x := range []float64{50, 150, 160} {
+		res, err := tr.PositionShip(PositionShip{ID: "1", Time: 100 + i, Point: Vector{X: x, Y: 150}})
+		require.NoError(t, err)
+		if i == 0 {
+			continue
+		}
+		// ship inside the advisory area is yellow, not red
+		assert.Equal(t, Yellow, res.Status)
+		require.Len(t, res.Conflicts, 1)
+		assert.Equal(t, Yellow, res.Conflicts[0].Status)
+	}
+}
+
+func TestGeofenceRecordWithoutStatus(t *testing.T) {
+	// geofences recorded before status was added are red
+	store := NewMemoryStore()
+	_, err := ReadSnapshot(strings.NewReader(`{"version":2}`+"\n"+
+		`{"kind":"geofence","geofence":{"id":"anchorage","polygon":[[0,0],[10,0],[10,10]]}}`+"\n"), store)
+	require.NoError(t, err)
+
+	g, ok := store.Geofence("anchorage")
+	require.True(t, ok)
+	assert.Equal(t, Red, g.Status)
+}
+
+func TestGeofencesCRUD(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	zone := square("zone", 100, 200)
+	require.NoError(t, tr.AddGeofence(zone))
+	assert.ErrorIs(t, tr.AddGeofence(zone), ErrGeofenceExists)
+	assert.ErrorIs(t, tr.AddGeofence(Geofence{ID: "line"}), ErrInvalidGeofence)
+
+	zone = square("zone", 300, 400)
+	require.NoError(t, tr.UpdateGeofence(zone))
+	assert.ErrorIs(t, tr.UpdateGeofence(square("other", 0, 1)), ErrGeofenceNotFound)
+
+	g, err := tr.Geofence("zone")
+	require.NoError(t, err)
+	assert.Equal(t, zone, g)
+	assert.Equal(t, []Geofence{zone}, tr.Geofences())
+
+	require.NoError(t, tr.DeleteGeofence("zone"))
+	assert.ErrorIs(t, tr.DeleteGeofence("zone"), ErrGeofenceNotFound)
+	_, err = tr.Geofence("zone")
+	assert.ErrorIs(t, err, ErrGeofenceNotFound)
+	assert.Empty(t, tr.Geofences())
+}
@@ -0,0 +1,185 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const KindHazard ConflictKind = "hazard"
+
+// TowerID is the hazard every new store starts with, the tower at the origin
+const TowerID = "tower"
+
+// Hazard is a static obstacle: tower, buoy, pier, wreck.
+// Point hazard has zero radius, circle hazard covers Radius around Position.
+// Red and yellow zones extend RedRadius and YellowRadius beyond the edge of the hazard.
+type Hazard struct {
+	ID           string
+	Position     Vector
+	Radius       float64
+	RedRadius    float64
+	YellowRadius float64
+}
+
+var (
+	ErrHazardNotFound = errors.New("hazard not found")
+	ErrHazardExists   = errors.New("hazard already exists")
+	ErrInvalidHazard  = errors.New("invalid hazard")
+)
+
+func (h Hazard) Validate() error {
+	if h.ID == "" {
+		return fmt.Errorf("%w: id can not be empty", ErrInvalidHazard)
+	}
+
+	if h.Radius < 0 {
+		return fmt.Errorf("%w: radius can not be negative", ErrInvalidHazard)
+	}
+
+	if h.RedRadius <= 0 {
+		return fmt.Errorf("%w: red radius must be positive", ErrInvalidHazard)
+	}
+
+	if h.YellowRadius < h.RedRadius {
+		return fmt.Errorf("%w: yellow radius can not be less than red radius", ErrInvalidHazard)
+	}
+
+	return nil
+}
+
+func (h Hazard) statusForDist(minDist float64) Status {
+	if minDist < h.RedRadius {
+		return Red
+	}
+	if minDist < h.YellowRadius {
+		return Yellow
+	}
+
+	return Green
+}
+
+// tower is the hazard which used to be hard-coded, thresholds come from the config
+func tower(cfg Config) Hazard {
+	return Hazard{
+		ID:           TowerID,
+		RedRadius:    cfg.RedThreshold,
+		YellowRadius: cfg.YellowThreshold,
+	}
+}
+
+func hasHazards(store Store) bool {
+	found := false
+	store.RangeHazards(func(Hazard) bool {
+		found = true
+		return false
+	})
+
+	return found
+}
+
+// Hazards returns all hazards sorted by id
+func (t *Traffic) Hazards() []Hazard {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var hazards []Hazard
+	t.store.RangeHazards(func(h Hazard) bool {
+		hazards = append(hazards, h)
+		return true
+	})
+	sort.Slice(hazards, func(i, j int) bool {
+		return hazards[i].ID < hazards[j].ID
+	})
+
+	return hazards
+}
+
+func (t *Traffic) Hazard(id string) (Hazard, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	h, ok := t.store.Hazard(id)
+	if !ok {
+		return Hazard{}, ErrHazardNotFound
+	}
+
+	return h, nil
+}
+
+// AddHazard registers new hazard, it applies to positions recorded after the call
+func (t *Traffic) AddHazard(h Hazard) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.store.Hazard(h.ID); ok {
+		return ErrHazardExists
+	}
+
+	return t.store.PutHazard(h)
+}
+
+// UpdateHazard replaces existing hazard, it applies to positions recorded after the call
+func (t *Traffic) UpdateHazard(h Hazard) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.store.Hazard(h.ID); !ok {
+		return ErrHazardNotFound
+	}
+
+	return t.store.PutHazard(h)
+}
+
+func (t *Traffic) DeleteHazard(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.store.Hazard(id); !ok {
+		return ErrHazardNotFound
+	}
+
+	return t.store.DeleteHazard(id)
+}
+
+// evaluateHazards returns yellow and red conflicts with hazards
+func (t *Traffic) evaluateHazards(ps PositionShip, speed Vector) []Conflict {
+	var conflicts []Conflict
+	t.store.RangeHazards(func(h Hazard) bool {
+		if conflict := evaluateHazard(ps, speed, h, t.cfg.PredictionWindow); conflict.Status != Green {
+			conflicts = append(conflicts, conflict)
+		}
+		return true
+	})
+
+	return conflicts
+}
+
+// evaluateHazard finds closest point of approach of the ship to the edge of the hazard
+func evaluateHazard(ps PositionShip, speed Vector, h Hazard, window int) Conflict {
+	minDist, at := calculateMinDistance(ShipPosition{
+		Position: h.Position,
+	}, ShipPosition{
+		Position: ps.Point,
+		Speed:    speed,
+	}, float64(window))
+	minDist = max(minDist-h.Radius, 0)
+
+	return Conflict{
+		Kind:          KindHazard,
+		ID:            h.ID,
+		Status:        h.statusForDist(minDist),
+		Distance:      minDist,
+		Time:          float64(ps.Time) + at,
+		Position:      ps.Point.Add(speed.ScalarMultiply(at)),
+		OtherPosition: h.Position,
+	}
+}
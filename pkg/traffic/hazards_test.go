@@ -0,0 +1,80 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTrafficTower(t *testing.T) {
+	cfg := DefaultConfig()
+	tr := mustNewTraffic(t, cfg)
+	assert.Equal(t, []Hazard{{ID: TowerID, RedRadius: cfg.RedThreshold, YellowRadius: cfg.YellowThreshold}}, tr.Hazards())
+
+	// store with hazards keeps them as they are
+	store := NewMemoryStore()
+	require.NoError(t, store.PutHazard(Hazard{ID: "buoy", Position: Vector{X: 10, Y: 10}, RedRadius: 1, YellowRadius: 2}))
+	tr, err := NewTraffic(cfg, store)
+	require.NoError(t, err)
+	assert.Equal(t, []Hazard{{ID: "buoy", Position: Vector{X: 10, Y: 10}, RedRadius: 1, YellowRadius: 2}}, tr.Hazards())
+
+	// deleted tower doesn't come back on restart
+	store = NewMemoryStore()
+	tr, err = NewTraffic(cfg, store)
+	require.NoError(t, err)
+	require.NoError(t, tr.DeleteHazard(TowerID))
+	tr, err = NewTraffic(cfg, store)
+	require.NoError(t, err)
+	assert.Empty(t, tr.Hazards())
+}
+
+func TestHazardsCRUD(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	buoy := Hazard{ID: "buoy", Position: Vector{X: 10, Y: 10}, RedRadius: 1, YellowRadius: 2}
+	require.NoError(t, tr.AddHazard(buoy))
+	assert.ErrorIs(t, tr.AddHazard(buoy), ErrHazardExists)
+	assert.ErrorIs(t, tr.AddHazard(Hazard{ID: "pier", RedRadius: 2, YellowRadius: 1}), ErrInvalidHazard)
+	assert.ErrorIs(t, tr.AddHazard(Hazard{ID: "pier", Radius: -1, RedRadius: 1, YellowRadius: 2}), ErrInvalidHazard)
+	assert.ErrorIs(t, tr.AddHazard(Hazard{RedRadius: 1, YellowRadius: 2}), ErrInvalidHazard)
+
+	buoy.YellowRadius = 5
+	require.NoError(t, tr.UpdateHazard(buoy))
+	assert.ErrorIs(t, tr.UpdateHazard(Hazard{ID: "pier", RedRadius: 1, YellowRadius: 2}), ErrHazardNotFound)
+
+	h, err := tr.Hazard("buoy")
+	require.NoError(t, err)
+	assert.Equal(t, buoy, h)
+
+	require.NoError(t, tr.DeleteHazard(TowerID))
+	assert.ErrorIs(t, tr.DeleteHazard(TowerID), ErrHazardNotFound)
+	_, err = tr.Hazard(TowerID)
+	assert.ErrorIs(t, err, ErrHazardNotFound)
+	assert.Equal(t, []Hazard{buoy}, tr.Hazards())
+
+	// tower is gone, ship at the origin is fine
+	res, err := tr.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 0, Y: 0}})
+	require.NoError(t, err)
+	assert.Equal(t, Green, res.Status)
+}
+
+func TestEvaluateTrafficStatusHazardConflict(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+	require.NoError(t, tr.AddHazard(Hazard{ID: "wreck", Position: Vector{X: 100, Y: 10}, Radius: 5, RedRadius: 1, YellowRadius: 3}))
+	require.NoError(t, tr.AddHazard(Hazard{ID: "pier", Position: Vector{X: 200, Y: 0}, Radius: 10, RedRadius: 2, YellowRadius: 4}))
+
+	// passes 2 units from the edge of the wreck and far from the pier
+	status, conflicts := tr.evaluateTrafficStatus(PositionShip{ID: "1", Time: 100, Point: Vector{X: 90, Y: 3}}, Vector{X: 1, Y: 0})
+	assert.Equal(t, Yellow, status)
+	assert.Equal(t, []Conflict{
+		{Kind: KindHazard, ID: "wreck", Status: Yellow, Distance: 2, Time: 110, Position: Vector{X: 100, Y: 3}, OtherPosition: Vector{X: 100, Y: 10}},
+	}, conflicts)
+
+	// inside the pier
+	status, conflicts = tr.evaluateTrafficStatus(PositionShip{ID: "1", Time: 100, Point: Vector{X: 195, Y: 0}}, Vector{})
+	assert.Equal(t, Red, status)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "pier", conflicts[0].ID)
+	assert.Zero(t, conflicts[0].Distance)
+}
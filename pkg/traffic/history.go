@@ -0,0 +1,201 @@
+package traffic
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// spliceResult describes change of the ship history
+type spliceResult struct {
+	positions      []ShipPosition // new positions with recalculated speeds, followed by the next position if any
+	tail           ShipPosition   // last position of the ship, zero if history is empty
+	status         Status
+	previousStatus Status
+	conflicts      []Conflict     // set only if status was re-evaluated
+	anomalies      []SpeedAnomaly // found while speeds were recalculated
+
+	// ships last seen within [since, until] could have seen changed part of the trajectory
+	since, until    int
+	previousHistory []ShipPosition
+}
+
+// splice replaces history[from:to] of the ship with positions sorted by time, must be called under write lock.
+//
+// New positions change trajectory of the ship between their neighbours, speeds of the new positions
+// and of the next position are recalculated, so when the next position is the last one trajectory changes after it as well.
+// Status is kept only for the last position of every ship, so it is re-evaluated only if the last position changed,
+// other affected ships are re-evaluated by reevaluateAffected.
+func (t *Traffic) splice(id string, from, to int, positions []ShipPosition) (spliceResult, error) {
+	history, _ := t.store.History(id)
+	res := spliceResult{
+		positions:       slices.Clone(positions),
+		previousStatus:  t.store.Status(id),
+		since:           math.MinInt,
+		until:           math.MaxInt,
+		previousHistory: history,
+	}
+	res.status = res.previousStatus
+
+	var prev *ShipPosition
+	if from > 0 {
+		prev = &history[from-1]
+		res.since = prev.Time - t.cfg.PredictionWindow
+	}
+	if to < len(history) {
+		if to < len(history)-1 {
+			res.until = history[to].Time
+		}
+		res.positions = append(res.positions, history[to])
+		to++
+	}
+	for i := range res.positions {
+		p := &res.positions[i]
+		p.Speed = Vector{}
+		if prev != nil {
+			var anomaly *SpeedAnomaly
+			p.Speed, anomaly = t.limitSpeed(id, p.Time, shipSpeed(float64(p.Time-prev.Time), p.Position, prev.Position))
+			if anomaly != nil {
+				res.anomalies = append(res.anomalies, *anomaly)
+			}
+		}
+		prev = p
+	}
+
+	lastChanged := to == len(history)
+	empty := lastChanged && prev == nil
+	switch {
+	case !lastChanged:
+		res.tail = history[len(history)-1]
+	case !empty:
+		res.tail = *prev
+		res.status, res.conflicts = t.evaluateTrafficStatus(PositionShip{ID: id, Time: res.tail.Time, Point: res.tail.Position}, res.tail.Speed)
+	default:
+		res.status = Green
+	}
+
+	if err := t.store.Splice(id, from, to, res.positions, res.status); err != nil {
+		return res, err
+	}
+	for _, a := range res.anomalies {
+		if err := t.recordAnomaly(id, a); err != nil {
+			return res, err
+		}
+	}
+	if empty {
+		t.index.remove(id)
+	} else if lastChanged {
+		t.index.update(id, res.tail)
+	}
+	t.commits.record(id)
+
+	return res, nil
+}
+
+// insertLate puts position older than the last one into the ship history, must be called under write lock
+func (t *Traffic) insertLate(ps PositionShip) (PositionResult, error) {
+	history, _ := t.store.History(ps.ID)
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].Time >= ps.Time
+	})
+	if history[i].Time == ps.Time {
+		return PositionResult{}, ErrDuplicateTime
+	}
+
+	res, err := t.splice(ps.ID, i, i, []ShipPosition{{Time: ps.Time, Position: ps.Point}})
+	if err != nil {
+		return PositionResult{}, err
+	}
+	speed := res.positions[0].Speed.Magnitude()
+
+	t.events.Publish(Event{
+		Kind:           EventPosition,
+		ID:             ps.ID,
+		Time:           ps.Time,
+		Position:       ps.Point,
+		Speed:          speed,
+		Status:         res.status,
+		PreviousStatus: res.previousStatus,
+		Conflicts:      res.conflicts,
+		Late:           true,
+	})
+	if err := t.publishChanges(ps.ID, res); err != nil {
+		return PositionResult{}, err
+	}
+
+	return PositionResult{
+		Speed:         speed,
+		Status:        res.status,
+		Conflicts:     res.conflicts,
+		Late:          true,
+		StatusChanged: res.status != res.previousStatus,
+		Anomaly: slices.ContainsFunc(res.anomalies, func(a SpeedAnomaly) bool {
+			return a.Time == ps.Time
+		}),
+	}, nil
+}
+
+// publishChanges publishes status change of the ship and re-evaluates other affected ships
+func (t *Traffic) publishChanges(id string, res spliceResult) error {
+	if res.status != res.previousStatus {
+		if err := t.publishStatus(id, res.tail, res.status, res.previousStatus, res.conflicts); err != nil {
+			return err
+		}
+	}
+
+	return t.reevaluateAffected(id, res)
+}
+
+// reevaluateAffected updates status of the ships last seen within [res.since, res.until]
+// if their conflict with the ship changed compared to its previous history
+func (t *Traffic) reevaluateAffected(id string, res spliceResult) error {
+	var affected []string
+	t.index.lastSeenBetween(res.since, res.until, func(other string) {
+		if other != id {
+			affected = append(affected, other)
+		}
+	})
+	// map order is random, keep events in stable order
+	slices.Sort(affected)
+
+	history, _ := t.store.History(id)
+	for _, other := range affected {
+		otherHistory, _ := t.store.History(other)
+		tail := otherHistory[len(otherHistory)-1]
+		ps := PositionShip{ID: other, Time: tail.Time, Point: tail.Position}
+
+		before, _ := t.evaluateShipStatus(ps, tail.Speed, id, res.previousHistory)
+		after, _ := t.evaluateShipStatus(ps, tail.Speed, id, history)
+		if before.Status == after.Status {
+			continue
+		}
+
+		previousStatus := t.store.Status(other)
+		status, conflicts := t.evaluateTrafficStatus(ps, tail.Speed)
+		if status == previousStatus {
+			continue
+		}
+		if err := t.store.SetStatus(other, status); err != nil {
+			return err
+		}
+		t.commits.record(other)
+		if err := t.publishStatus(other, tail, status, previousStatus, conflicts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publishStatus records and publishes status change of the ship re-evaluated at its last position
+func (t *Traffic) publishStatus(id string, tail ShipPosition, status, previousStatus Status, conflicts []Conflict) error {
+	return t.changeStatus(Event{
+		ID:             id,
+		Time:           tail.Time,
+		Position:       tail.Position,
+		Speed:          tail.Speed.Magnitude(),
+		Status:         status,
+		PreviousStatus: previousStatus,
+		Conflicts:      conflicts,
+	})
+}
@@ -0,0 +1,143 @@
+package traffic
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lateConfig() Config {
+	cfg := DefaultConfig()
+	cfg.LatePositions = true
+	return cfg
+}
+
+func TestInsertLate(t *testing.T) {
+	tr := mustNewTraffic(t, lateConfig())
+
+	_, err := tr.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 1000, Y: 1000}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 110, Point: Vector{X: 1010, Y: 1000}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 120, Point: Vector{X: 1020, Y: 1000}})
+	require.NoError(t, err)
+
+	res, err := tr.PositionShip(PositionShip{ID: "1", Time: 105, Point: Vector{X: 1005, Y: 1005}})
+	require.NoError(t, err)
+	assert.True(t, res.Late)
+	assert.False(t, res.StatusChanged)
+	assert.Equal(t, Green, res.Status)
+	assert.InDelta(t, math.Sqrt2, res.Speed, epsilon)
+
+	res, err = tr.PositionShip(PositionShip{ID: "1", Time: 90, Point: Vector{X: 990, Y: 1000}})
+	require.NoError(t, err)
+	assert.True(t, res.Late)
+	assert.Zero(t, res.Speed)
+
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 110, Point: Vector{X: 0, Y: 0}})
+	assert.ErrorIs(t, err, ErrDuplicateTime)
+
+	history, err := tr.GetShipPositions("1")
+	require.NoError(t, err)
+	assert.Equal(t, []ShipPosition{
+		{Time: 90, Position: Vector{X: 990, Y: 1000}},
+		{Time: 100, Position: Vector{X: 1000, Y: 1000}, Speed: Vector{X: 1, Y: 0}},
+		{Time: 105, Position: Vector{X: 1005, Y: 1005}, Speed: Vector{X: 1, Y: 1}},
+		{Time: 110, Position: Vector{X: 1010, Y: 1000}, Speed: Vector{X: 1, Y: -1}},
+		{Time: 120, Position: Vector{X: 1020, Y: 1000}, Speed: Vector{X: 1, Y: 0}},
+	}, history)
+
+	// disabled by default
+	tr = mustNewTraffic(t, DefaultConfig())
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 1000, Y: 1000}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 90, Point: Vector{X: 1000, Y: 1000}})
+	assert.ErrorIs(t, err, ErrTimeInPast)
+}
+
+func TestInsertLateChangesOwnStatus(t *testing.T) {
+	tr := mustNewTraffic(t, lateConfig())
+	sub := tr.Subscribe(EventFilter{IDs: []string{"1"}})
+	defer sub.Close()
+
+	_, err := tr.PositionShip(PositionShip{ID: "2", Time: 100, Point: Vector{X: 3000, Y: 0}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 2000, Y: 0}})
+	require.NoError(t, err)
+	// heading right into ship 2
+	res, err := tr.PositionShip(PositionShip{ID: "1", Time: 200, Point: Vector{X: 2950, Y: 0}})
+	require.NoError(t, err)
+	require.Equal(t, Red, res.Status)
+
+	// ship 1 actually came from below and is turning away from ship 2
+	res, err = tr.PositionShip(PositionShip{ID: "1", Time: 150, Point: Vector{X: 2950, Y: -100}})
+	require.NoError(t, err)
+	assert.True(t, res.Late)
+	assert.True(t, res.StatusChanged)
+	assert.Equal(t, Green, res.Status)
+	assert.Empty(t, res.Conflicts)
+
+	page, err := tr.GetShips(ShipsQuery{})
+	require.NoError(t, err)
+	for _, ship := range page.Ships {
+		if ship.ID == "1" {
+			assert.Equal(t, Green, ship.LastStatus)
+			assert.Equal(t, "200", ship.LastSeen)
+			assert.InDelta(t, 2.0, ship.LastSpeed, epsilon)
+		}
+	}
+
+	events := receive(t, sub)
+	require.Len(t, events, 5)
+	assert.Equal(t, EventPosition, events[3].Kind)
+	assert.True(t, events[3].Late)
+	assert.Equal(t, 150, events[3].Time)
+	assert.Equal(t, EventStatus, events[4].Kind)
+	assert.Equal(t, 200, events[4].Time)
+	assert.Equal(t, Green, events[4].Status)
+	assert.Equal(t, Red, events[4].PreviousStatus)
+}
+
+func TestInsertLateReevaluatesAffectedShips(t *testing.T) {
+	tr := mustNewTraffic(t, lateConfig())
+
+	_, err := tr.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 1000, Y: 0}})
+	require.NoError(t, err)
+	// far from ship 1 wherever it goes within the window
+	_, err = tr.PositionShip(PositionShip{ID: "2", Time: 150, Point: Vector{X: 1500, Y: 500}})
+	require.NoError(t, err)
+	// last seen long before the change, can't be affected
+	_, err = tr.PositionShip(PositionShip{ID: "3", Time: 10, Point: Vector{X: 1500, Y: 500}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 200, Point: Vector{X: 2000, Y: 0}})
+	require.NoError(t, err)
+
+	sub := tr.Subscribe(EventFilter{IDs: []string{"2", "3"}})
+	defer sub.Close()
+
+	// ship 1 was right at ship 2 at that time
+	res, err := tr.PositionShip(PositionShip{ID: "1", Time: 150, Point: Vector{X: 1500, Y: 500}})
+	require.NoError(t, err)
+	assert.True(t, res.Late)
+	assert.False(t, res.StatusChanged)
+	assert.Equal(t, Green, res.Status)
+
+	events := receive(t, sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventStatus, events[0].Kind)
+	assert.Equal(t, "2", events[0].ID)
+	assert.Equal(t, Red, events[0].Status)
+	assert.Equal(t, Green, events[0].PreviousStatus)
+	require.NotEmpty(t, events[0].Conflicts)
+	assert.Equal(t, "1", events[0].Conflicts[0].ID)
+
+	page, err := tr.GetShips(ShipsQuery{})
+	require.NoError(t, err)
+	statuses := make(map[string]Status)
+	for _, ship := range page.Ships {
+		statuses[ship.ID] = ship.LastStatus
+	}
+	assert.Equal(t, map[string]Status{"1": Green, "2": Red, "3": Green}, statuses)
+}
@@ -0,0 +1,74 @@
+package traffic
+
+import "slices"
+
+type (
+	// Incident is a transition of the ship status, kept after the ship is green again
+	Incident struct {
+		ShipID string
+		Time   int // time of the position the status was evaluated at
+		From   Status
+		To     Status
+		// Counterpart is the closest conflict with the new status: ship, hazard or geofence with its CPA,
+		// nil when there is none, e.g. ship became green
+		Counterpart *Conflict
+	}
+
+	// IncidentQuery selects incidents, zero value is all of them
+	IncidentQuery struct {
+		// ShipIDs keeps incidents of the ships or with the ships as counterparts, any ship when empty
+		ShipIDs []string
+		// From and To limit incident times, both included
+		From, To *int
+	}
+)
+
+// Incidents returns status transitions matching the query in the order they happened
+func (t *Traffic) Incidents(q IncidentQuery) []Incident {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	incidents := []Incident{}
+	t.store.RangeIncidents(func(i Incident) bool {
+		if q.match(i) {
+			incidents = append(incidents, i)
+		}
+		return true
+	})
+
+	return incidents
+}
+
+func (q IncidentQuery) match(i Incident) bool {
+	if q.From != nil && i.Time < *q.From || q.To != nil && i.Time > *q.To {
+		return false
+	}
+	if len(q.ShipIDs) == 0 || slices.Contains(q.ShipIDs, i.ShipID) {
+		return true
+	}
+
+	return i.Counterpart != nil && i.Counterpart.Kind == KindShip && slices.Contains(q.ShipIDs, i.Counterpart.ID)
+}
+
+// changeStatus records transition of the ship status at its last position to the incident log
+// and publishes it, must be called under write lock
+func (t *Traffic) changeStatus(event Event) error {
+	incident := Incident{
+		ShipID: event.ID,
+		Time:   event.Time,
+		From:   event.PreviousStatus,
+		To:     event.Status,
+	}
+	// conflicts are sorted closest first
+	if i := slices.IndexFunc(event.Conflicts, func(c Conflict) bool { return c.Status == event.Status }); i >= 0 {
+		counterpart := event.Conflicts[i]
+		incident.Counterpart = &counterpart
+	}
+	if err := t.store.AppendIncident(incident); err != nil {
+		return err
+	}
+
+	event.Kind = EventStatus
+	t.events.Publish(event)
+	return nil
+}
@@ -0,0 +1,92 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncidents(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	for _, ps := range []PositionShip{
+		{ID: "1", Time: 1000, Point: Vector{X: 1000, Y: 1000}},
+		{ID: "2", Time: 1000, Point: Vector{X: 1000.5, Y: 1000}},
+		{ID: "2", Time: 1001, Point: Vector{X: 1100, Y: 1000}},
+		{ID: "3", Time: 1002, Point: Vector{X: 5000, Y: 5000}},
+	} {
+		_, err := tr.PositionShip(ps)
+		require.NoError(t, err)
+	}
+
+	incidents := tr.Incidents(IncidentQuery{})
+	require.Len(t, incidents, 2)
+
+	red := incidents[0]
+	assert.Equal(t, "2", red.ShipID)
+	assert.Equal(t, 1000, red.Time)
+	assert.Equal(t, Green, red.From)
+	assert.Equal(t, Red, red.To)
+	require.NotNil(t, red.Counterpart)
+	assert.Equal(t, KindShip, red.Counterpart.Kind)
+	assert.Equal(t, "1", red.Counterpart.ID)
+	assert.InDelta(t, 0.5, red.Counterpart.Distance, epsilon)
+
+	// evidence is kept after the ship is green again
+	assert.Equal(t, Incident{ShipID: "2", Time: 1001, From: Red, To: Green}, incidents[1])
+
+	// corrections change status as well
+	_, err := tr.CorrectPosition(PositionShip{ID: "2", Time: 1001, Point: Vector{X: 1001, Y: 1000}}, "jane")
+	require.NoError(t, err)
+	incidents = tr.Incidents(IncidentQuery{})
+	require.Len(t, incidents, 3)
+	assert.Equal(t, Yellow, incidents[2].To)
+
+	// ship is matched as counterpart too
+	assert.Len(t, tr.Incidents(IncidentQuery{ShipIDs: []string{"1"}}), 2)
+	assert.Empty(t, tr.Incidents(IncidentQuery{ShipIDs: []string{"3"}}))
+
+	from, to := 1001, 1001
+	assert.Len(t, tr.Incidents(IncidentQuery{From: &from, To: &to}), 2)
+
+	require.NoError(t, tr.Flush())
+	assert.Empty(t, tr.Incidents(IncidentQuery{}))
+}
+
+func TestIncidentsRetention(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Retention = 1000
+	tr := mustNewTraffic(t, cfg)
+
+	for _, ps := range []PositionShip{
+		{ID: "1", Time: 1000, Point: Vector{X: 1000, Y: 1000}},
+		{ID: "2", Time: 1000, Point: Vector{X: 1000.5, Y: 1000}},
+		{ID: "2", Time: 1500, Point: Vector{X: 1100, Y: 1000}},
+		{ID: "3", Time: 1600, Point: Vector{X: 5000, Y: 5000}},
+		{ID: "4", Time: 1600, Point: Vector{X: 5000.5, Y: 5000}},
+	} {
+		_, err := tr.PositionShip(ps)
+		require.NoError(t, err)
+	}
+	require.Len(t, tr.Incidents(IncidentQuery{}), 3)
+
+	// red incident of ship 2 is older than retention
+	res, err := tr.Compact(2100)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Incidents)
+	incidents := tr.Incidents(IncidentQuery{})
+	require.Len(t, incidents, 2)
+	assert.Equal(t, Incident{ShipID: "2", Time: 1500, From: Red, To: Green}, incidents[0])
+	assert.Equal(t, "4", incidents[1].ShipID)
+
+	// deleted ship takes its incidents with it
+	require.NoError(t, tr.DeleteShip("2"))
+	incidents = tr.Incidents(IncidentQuery{})
+	require.Len(t, incidents, 1)
+	assert.Equal(t, "4", incidents[0].ShipID)
+
+	res, err = tr.Compact(2100)
+	require.NoError(t, err)
+	assert.Zero(t, res.Incidents)
+}
@@ -0,0 +1,324 @@
+package traffic
+
+import (
+	"container/heap"
+	"math"
+)
+
+const (
+	indexLevels     = 12   // number of grid levels, last one is never expired
+	indexLevelScale = 16.0 // each level cell is this many times bigger than previous one
+)
+
+type (
+	cell struct {
+		X int64
+		Y int64
+	}
+
+	indexLevel struct {
+		cellSize float64
+		cells    map[cell]map[string]struct{}
+		ships    map[string]struct{}
+	}
+
+	indexEntry struct {
+		tail    ShipPosition
+		level   int
+		cell    cell
+		expires int
+		seq     uint64
+	}
+
+	expiryItem struct {
+		expires int
+		id      string
+		seq     uint64
+	}
+
+	expiryHeap []expiryItem
+
+	// spatialIndex keeps last known position of every ship in a hierarchy of uniform grids
+	// so evaluateTrafficStatus examines only ships that could physically come
+	// within yellow threshold during the prediction window.
+	//
+	// Ship moving with speed v, last seen at t0 in p0, is at most |v| * (t - t0) away from p0 at time t.
+	// The older the fix the larger the area ship could be in, so ship is stored
+	// on the smallest level whose cell covers that area and is promoted to the next level
+	// when the area outgrows the cell. Standing still ships never move up.
+	//
+	// Bound holds only when the last fix is not after the requested time,
+	// ships with fixes after it (past predictions) are tracked by last seen time and always examined.
+	spatialIndex struct {
+		cfg      Config
+		levels   []indexLevel
+		ships    map[string]*indexEntry
+		lastSeen map[int]map[string]struct{}
+		maxTime  int
+		expiry   expiryHeap
+		now      int // entries expiring before now are already promoted
+		seq      uint64
+	}
+)
+
+func newSpatialIndex(cfg Config) *spatialIndex {
+	idx := &spatialIndex{
+		cfg:      cfg,
+		levels:   make([]indexLevel, indexLevels),
+		ships:    make(map[string]*indexEntry),
+		lastSeen: make(map[int]map[string]struct{}),
+		now:      math.MinInt,
+	}
+
+	// smallest cell must fit both ships moving at max speed for a whole window
+	// plus another window of slack, so a freshly updated ship stays on level 0 for a while
+	cellSize := 4*idx.cfg.MaxSpeed*float64(idx.cfg.PredictionWindow) + idx.cfg.YellowThreshold
+	for i := range idx.levels {
+		idx.levels[i] = indexLevel{
+			cellSize: cellSize,
+			cells:    make(map[cell]map[string]struct{}),
+			ships:    make(map[string]struct{}),
+		}
+		cellSize *= indexLevelScale
+	}
+
+	return idx
+}
+
+// update replaces last known position of the ship
+func (idx *spatialIndex) update(id string, tail ShipPosition) {
+	idx.remove(id)
+
+	idx.seq++
+	entry := &indexEntry{
+		tail: tail,
+		seq:  idx.seq,
+	}
+	idx.ships[id] = entry
+
+	if idx.lastSeen[tail.Time] == nil {
+		idx.lastSeen[tail.Time] = make(map[string]struct{})
+	}
+	idx.lastSeen[tail.Time][id] = struct{}{}
+	if len(idx.ships) == 1 || tail.Time > idx.maxTime {
+		idx.maxTime = tail.Time
+	}
+
+	idx.place(id, entry, 0)
+}
+
+func (idx *spatialIndex) remove(id string) {
+	entry, ok := idx.ships[id]
+	if !ok {
+		return
+	}
+
+	idx.unplace(id, entry)
+	delete(idx.lastSeen[entry.tail.Time], id)
+	if len(idx.lastSeen[entry.tail.Time]) == 0 {
+		delete(idx.lastSeen, entry.tail.Time)
+	}
+	delete(idx.ships, id)
+}
+
+// place puts entry to the first level starting from level where it is still valid at idx.now
+func (idx *spatialIndex) place(id string, entry *indexEntry, level int) {
+	for ; level < len(idx.levels)-1; level++ {
+		entry.expires = idx.expiresAt(entry.tail, level)
+		if entry.expires >= idx.now {
+			break
+		}
+	}
+	if level == len(idx.levels)-1 {
+		entry.expires = math.MaxInt
+	}
+
+	l := &idx.levels[level]
+	entry.level = level
+	entry.cell = l.cellOf(entry.tail.Position)
+	if l.cells[entry.cell] == nil {
+		l.cells[entry.cell] = make(map[string]struct{})
+	}
+	l.cells[entry.cell][id] = struct{}{}
+	l.ships[id] = struct{}{}
+
+	if entry.expires != math.MaxInt {
+		heap.Push(&idx.expiry, expiryItem{expires: entry.expires, id: id, seq: entry.seq})
+	}
+}
+
+func (idx *spatialIndex) unplace(id string, entry *indexEntry) {
+	l := &idx.levels[entry.level]
+	delete(l.cells[entry.cell], id)
+	if len(l.cells[entry.cell]) == 0 {
+		delete(l.cells, entry.cell)
+	}
+	delete(l.ships, id)
+}
+
+// expiresAt returns the last time when every ship position within the prediction window
+// stays inside of the level cell radius
+func (idx *spatialIndex) expiresAt(tail ShipPosition, level int) int {
+	speed := tail.Speed.Magnitude()
+	if speed < epsilon {
+		return math.MaxInt
+	}
+
+	window := float64(idx.cfg.PredictionWindow)
+	horizon := (idx.levels[level].cellSize-idx.cfg.YellowThreshold-idx.cfg.MaxSpeed*window)/speed - window
+	if horizon >= float64(math.MaxInt-tail.Time) {
+		return math.MaxInt
+	}
+
+	return tail.Time + int(math.Floor(horizon))
+}
+
+// advance promotes ships which outgrew their level by the time now
+func (idx *spatialIndex) advance(now int) {
+	if now <= idx.now {
+		return
+	}
+	idx.now = now
+
+	for len(idx.expiry) > 0 && idx.expiry[0].expires < now {
+		item := heap.Pop(&idx.expiry).(expiryItem)
+		entry, ok := idx.ships[item.id]
+		if !ok || entry.seq != item.seq || entry.expires != item.expires {
+			continue // stale item, ship was updated or removed since
+		}
+
+		idx.unplace(item.id, entry)
+		idx.place(item.id, entry, entry.level+1)
+	}
+}
+
+// candidates calls fn once for every ship which could come within yellow threshold plus margin
+// of the ship moving from ps.Point with speed during the prediction window
+func (idx *spatialIndex) candidates(ps PositionShip, speed Vector, margin float64, fn func(id string)) {
+	seen := make(map[string]struct{})
+	visit := func(ships map[string]struct{}) {
+		for id := range ships {
+			if id == ps.ID {
+				continue
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			fn(id)
+		}
+	}
+
+	// ships which are not bounded by the grid, they have positions after ps.Time
+	if len(idx.ships) > 0 && ps.Time < idx.maxTime {
+		if idx.maxTime-ps.Time <= len(idx.lastSeen) {
+			for ts := ps.Time + 1; ts <= idx.maxTime; ts++ {
+				visit(idx.lastSeen[ts])
+			}
+		} else {
+			for ts, ships := range idx.lastSeen {
+				if ts > ps.Time {
+					visit(ships)
+				}
+			}
+		}
+	}
+
+	// level cell covers ships moving at max speed, use actual speed of the ship instead.
+	// Ships are promoted only up to idx.now, so search radius grows with the lag
+	radiusDelta := (speed.Magnitude()-idx.cfg.MaxSpeed)*float64(idx.cfg.PredictionWindow) + margin
+	if ps.Time > idx.now {
+		radiusDelta += float64(ps.Time-idx.now) * idx.cfg.MaxSpeed
+	}
+
+	last := len(idx.levels) - 1
+	for i := range idx.levels {
+		l := &idx.levels[i]
+		if len(l.ships) == 0 {
+			continue
+		}
+
+		radius := l.cellSize + radiusDelta
+		lo := l.cellOf(ps.Point.Subtract(Vector{X: radius, Y: radius}))
+		hi := l.cellOf(ps.Point.Add(Vector{X: radius, Y: radius}))
+		cellsCount := float64(hi.X-lo.X+1) * float64(hi.Y-lo.Y+1)
+		if i == last || cellsCount > float64(len(l.ships)) {
+			visit(l.ships)
+			continue
+		}
+
+		for x := lo.X; x <= hi.X; x++ {
+			for y := lo.Y; y <= hi.Y; y++ {
+				visit(l.cells[cell{X: x, Y: y}])
+			}
+		}
+	}
+}
+
+// lastSeenBetween calls fn for every ship last seen within [from, to]
+func (idx *spatialIndex) lastSeenBetween(from, to int, fn func(id string)) {
+	if from != math.MinInt && to != math.MaxInt && to-from < len(idx.lastSeen) {
+		for ts := from; ts <= to; ts++ {
+			for id := range idx.lastSeen[ts] {
+				fn(id)
+			}
+		}
+		return
+	}
+
+	for ts, ships := range idx.lastSeen {
+		if ts >= from && ts <= to {
+			for id := range ships {
+				fn(id)
+			}
+		}
+	}
+}
+
+// around calls fn for every ship whose last known position could be within radius of p
+func (idx *spatialIndex) around(p Vector, radius float64, fn func(id string, tail ShipPosition)) {
+	visit := func(ships map[string]struct{}) {
+		for id := range ships {
+			fn(id, idx.ships[id].tail)
+		}
+	}
+
+	// every ship is in the cell of its last position on exactly one level
+	for i := range idx.levels {
+		l := &idx.levels[i]
+		if len(l.ships) == 0 {
+			continue
+		}
+
+		lo := l.cellOf(p.Subtract(Vector{X: radius, Y: radius}))
+		hi := l.cellOf(p.Add(Vector{X: radius, Y: radius}))
+		if cellsCount := float64(hi.X-lo.X+1) * float64(hi.Y-lo.Y+1); cellsCount > float64(len(l.ships)) {
+			visit(l.ships)
+			continue
+		}
+
+		for x := lo.X; x <= hi.X; x++ {
+			for y := lo.Y; y <= hi.Y; y++ {
+				visit(l.cells[cell{X: x, Y: y}])
+			}
+		}
+	}
+}
+
+func (l *indexLevel) cellOf(v Vector) cell {
+	return cell{
+		X: int64(math.Floor(v.X / l.cellSize)),
+		Y: int64(math.Floor(v.Y / l.cellSize)),
+	}
+}
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires < h[j].expires }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiryItem)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,110 @@
+package traffic
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomFleet positions ships with constant density at time 1000, every ship moves with random speed
+// and reports again at time 1010
+func randomFleet(r *rand.Rand, t *Traffic, size int, area float64) {
+	next := make([]Vector, size)
+	for i := range size {
+		start := Vector{X: r.Float64() * area, Y: r.Float64() * area}
+		speed := Vector{X: r.Float64()*20 - 10, Y: r.Float64()*20 - 10}
+		next[i] = start.Add(speed.ScalarMultiply(10))
+
+		_, _ = t.PositionShip(PositionShip{ID: fmt.Sprintf("ship-%d", i), Time: 1000, Point: start})
+	}
+
+	for i := range size {
+		_, _ = t.PositionShip(PositionShip{ID: fmt.Sprintf("ship-%d", i), Time: 1010, Point: next[i]})
+	}
+}
+
+func collectCandidates(t *Traffic, ps PositionShip, speed Vector) map[string]struct{} {
+	res := make(map[string]struct{})
+	t.index.candidates(ps, speed, 0, func(id string) {
+		res[id] = struct{}{}
+	})
+	return res
+}
+
+func TestIndexCandidatesCoverCollisions(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	traffic := mustNewTraffic(t, DefaultConfig())
+	randomFleet(r, traffic, 2000, 200_000)
+
+	for range 500 {
+		ps := PositionShip{
+			ID:    "probe",
+			Time:  1000 + r.IntN(400),
+			Point: Vector{X: r.Float64() * 200_000, Y: r.Float64() * 200_000},
+		}
+		speed := Vector{X: r.Float64()*200 - 100, Y: r.Float64()*200 - 100}
+		traffic.index.advance(ps.Time)
+
+		candidates := collectCandidates(traffic, ps, speed)
+		if ps.Time > 1010 {
+			// all fixes are in the past, only nearby ships are examined
+			assert.Less(t, len(candidates), traffic.store.Len()/2)
+		}
+
+		traffic.store.Range(func(id string, history []ShipPosition, _ Status) bool {
+			if conflict, _ := traffic.evaluateShipStatus(ps, speed, id, history); conflict.Status != Green {
+				_, ok := candidates[id]
+				assert.True(t, ok, "ship %s is not a candidate for %+v", id, ps)
+			}
+			return true
+		})
+	}
+}
+
+func TestIndexPromotesStaleShips(t *testing.T) {
+	traffic := mustNewTraffic(t, DefaultConfig())
+
+	// ship moving towards the origin, last seen long time ago far away
+	_, _ = traffic.PositionShip(PositionShip{ID: "old", Time: 100, Point: Vector{X: 990_100, Y: 1000}})
+	_, _ = traffic.PositionShip(PositionShip{ID: "old", Time: 101, Point: Vector{X: 990_000, Y: 1000}})
+
+	// standing still ship to let time go forward
+	_, _ = traffic.PositionShip(PositionShip{ID: "anchor", Time: 9000, Point: Vector{X: -50_000, Y: -50_000}})
+
+	// by now the old ship is at (100, 1000)
+	ps := PositionShip{ID: "new", Time: 10_000, Point: Vector{X: 100, Y: 1000}}
+	traffic.index.advance(ps.Time)
+	candidates := collectCandidates(traffic, ps, Vector{})
+
+	assert.Contains(t, candidates, "old")
+	status, _ := traffic.evaluateTrafficStatus(ps, Vector{})
+	assert.Equal(t, Red, status)
+}
+
+func TestIndexPastPrediction(t *testing.T) {
+	traffic := mustNewTraffic(t, DefaultConfig())
+
+	// ship jumps back and forth, bound by speed doesn't hold for its track
+	_, _ = traffic.PositionShip(PositionShip{ID: "jumper", Time: 100, Point: Vector{X: 10, Y: 10}})
+	_, _ = traffic.PositionShip(PositionShip{ID: "jumper", Time: 120, Point: Vector{X: 1_000_000, Y: 1_000_000}})
+
+	ps := PositionShip{ID: "new", Time: 100, Point: Vector{X: 10, Y: 10}}
+	candidates := collectCandidates(traffic, ps, Vector{})
+
+	assert.Contains(t, candidates, "jumper")
+	status, _ := traffic.evaluateTrafficStatus(ps, Vector{})
+	assert.Equal(t, Red, status)
+}
+
+func TestIndexFlush(t *testing.T) {
+	traffic := mustNewTraffic(t, DefaultConfig())
+	_, _ = traffic.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 10, Y: 10}})
+
+	require.NoError(t, traffic.Flush())
+
+	ps := PositionShip{ID: "2", Time: 100, Point: Vector{X: 10, Y: 10}}
+	assert.Empty(t, collectCandidates(traffic, ps, Vector{}))
+}
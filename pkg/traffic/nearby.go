@@ -0,0 +1,114 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var ErrInvalidNearby = errors.New("invalid proximity query")
+
+// NearbyShip is a ship Distance away from the query point,
+// at its last known position or at the requested time
+type NearbyShip struct {
+	ID       string
+	Position Vector
+	Speed    Vector
+	Distance float64
+	// LastSeen is the time of the last fix at or before the requested time
+	LastSeen int
+}
+
+// Nearby returns ships within radius of p ordered by distance,
+// at their last known positions or at positions at time when at is set
+func (t *Traffic) Nearby(p Vector, radius float64, at *int) ([]NearbyShip, error) {
+	if radius <= 0 {
+		return nil, fmt.Errorf("%w: radius must be positive", ErrInvalidNearby)
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	ships, _ := t.nearby(p, radius, at, "")
+	return ships, nil
+}
+
+// Neighbours returns k ships closest to the ship ordered by distance,
+// at their last known positions or at positions at time when at is set
+func (t *Traffic) Neighbours(id string, k int, at *int) ([]NearbyShip, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("%w: k must be positive", ErrInvalidNearby)
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	history, _ := t.store.History(id)
+	if len(history) == 0 {
+		return nil, ErrNotFound
+	}
+	p := history[len(history)-1]
+	if at != nil {
+		var ok bool
+		if p, _, ok = t.positionAt(id, history, *at); !ok {
+			return nil, ErrNotFound
+		}
+	}
+
+	others := len(t.index.ships) - 1
+	// search area grows until it has k ships, then the closest ones are inside for sure
+	for radius := t.index.levels[0].cellSize; ; radius *= indexLevelScale {
+		ships, examined := t.nearby(p.Position, radius, at, id)
+		if len(ships) >= k || examined >= others || math.IsInf(radius, 1) {
+			return ships[:min(k, len(ships))], nil
+		}
+	}
+}
+
+// nearby returns ships other than exclude within radius of p sorted by distance and number of ships examined,
+// must be called under read or write lock
+func (t *Traffic) nearby(p Vector, radius float64, at *int, exclude string) ([]NearbyShip, int) {
+	var ships []NearbyShip
+	add := func(id string, pos ShipPosition, lastSeen int) {
+		if d := pos.Position.Subtract(p).Magnitude(); d <= radius {
+			ships = append(ships, NearbyShip{
+				ID:       id,
+				Position: pos.Position,
+				Speed:    pos.Speed,
+				Distance: d,
+				LastSeen: lastSeen,
+			})
+		}
+	}
+
+	examined := 0
+	if at == nil {
+		t.index.around(p, radius, func(id string, tail ShipPosition) {
+			if id != exclude {
+				examined++
+				add(id, tail, tail.Time)
+			}
+		})
+	} else {
+		// ship within radius at the time is within yellow threshold plus margin of the standing probe,
+		// so it is a collision candidate
+		probe := PositionShip{ID: exclude, Time: *at, Point: p}
+		t.index.candidates(probe, Vector{}, max(radius-t.cfg.YellowThreshold, 0), func(id string) {
+			examined++
+			history, _ := t.store.History(id)
+			if pos, lastSeen, ok := t.positionAt(id, history, *at); ok {
+				add(id, pos, lastSeen)
+			}
+		})
+	}
+
+	sort.Slice(ships, func(i, j int) bool {
+		if ships[i].Distance != ships[j].Distance {
+			return ships[i].Distance < ships[j].Distance
+		}
+		return ships[i].ID < ships[j].ID
+	})
+
+	return ships, examined
+}
@@ -0,0 +1,133 @@
+package traffic
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scanNearby finds ships within radius by going over every history
+func scanNearby(t *Traffic, p Vector, radius float64, at *int, exclude string) []string {
+	var ids []string
+	t.store.Range(func(id string, history []ShipPosition, _ Status) bool {
+		pos := history[len(history)-1]
+		if at != nil {
+			var ok bool
+			if pos, _, ok = t.positionAt(id, history, *at); !ok {
+				return true
+			}
+		}
+		if id != exclude && pos.Position.Subtract(p).Magnitude() <= radius {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	sort.Strings(ids)
+
+	return ids
+}
+
+func nearbyIDs(ships []NearbyShip) []string {
+	var ids []string
+	for _, ship := range ships {
+		ids = append(ids, ship.ID)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestNearby(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	for _, ps := range []PositionShip{
+		{ID: "a", Time: 1000, Point: Vector{X: 0, Y: 100}},
+		{ID: "b", Time: 1000, Point: Vector{X: 30, Y: 100}},
+		{ID: "c", Time: 1000, Point: Vector{X: 10, Y: 110}},
+		{ID: "a", Time: 1010, Point: Vector{X: 100, Y: 100}},
+	} {
+		_, err := tr.PositionShip(ps)
+		require.NoError(t, err)
+	}
+
+	ships, err := tr.Nearby(Vector{X: 0, Y: 100}, 30, nil)
+	require.NoError(t, err)
+	require.Len(t, ships, 2)
+	assert.Equal(t, NearbyShip{ID: "c", Position: Vector{X: 10, Y: 110}, Distance: ships[0].Distance, LastSeen: 1000}, ships[0])
+	assert.Equal(t, "b", ships[1].ID)
+	assert.Equal(t, 30.0, ships[1].Distance)
+
+	// a was next to the point at 1000 and passed b at 1003
+	at := 1003
+	ships, err = tr.Nearby(Vector{X: 30, Y: 100}, 5, &at)
+	require.NoError(t, err)
+	require.Len(t, ships, 2)
+	assert.Equal(t, "a", ships[0].ID)
+	assert.Equal(t, Vector{X: 30, Y: 100}, ships[0].Position)
+	assert.Equal(t, 1000, ships[0].LastSeen)
+	assert.Equal(t, "b", ships[1].ID)
+
+	ships, err = tr.Neighbours("b", 1, &at)
+	require.NoError(t, err)
+	require.Len(t, ships, 1)
+	assert.Equal(t, "a", ships[0].ID)
+
+	ships, err = tr.Neighbours("b", 5, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, []string{ships[0].ID, ships[1].ID})
+
+	_, err = tr.Nearby(Vector{}, 0, nil)
+	assert.ErrorIs(t, err, ErrInvalidNearby)
+	_, err = tr.Neighbours("b", 0, nil)
+	assert.ErrorIs(t, err, ErrInvalidNearby)
+	_, err = tr.Neighbours("d", 1, nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+	before := 999
+	_, err = tr.Neighbours("b", 1, &before)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestNearbyMatchesScan(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	tr := mustNewTraffic(t, DefaultConfig())
+	randomFleet(r, tr, 2000, 200_000)
+
+	for i := range 200 {
+		p := Vector{X: r.Float64() * 200_000, Y: r.Float64() * 200_000}
+		radius := r.Float64() * 20_000
+		var at *int
+		if i%2 == 1 {
+			time := 1000 + r.IntN(400)
+			at = &time
+		}
+
+		ships, err := tr.Nearby(p, radius, at)
+		require.NoError(t, err)
+		assert.Equal(t, scanNearby(tr, p, radius, at, ""), nearbyIDs(ships), "%v within %f at %v", p, radius, at)
+		assert.True(t, sort.SliceIsSorted(ships, func(i, j int) bool {
+			return ships[i].Distance < ships[j].Distance
+		}))
+
+		if at == nil {
+			// only ships around the point are examined
+			_, examined := tr.nearby(p, radius, nil, "")
+			assert.Less(t, examined, tr.store.Len()/2)
+		}
+
+		// k closest ships are the ones within distance to the k-th one
+		id := fmt.Sprintf("ship-%d", r.IntN(2000))
+		neighbours, err := tr.Neighbours(id, 5, at)
+		require.NoError(t, err)
+		require.Len(t, neighbours, 5)
+		history, _ := tr.store.History(id)
+		self := history[len(history)-1]
+		if at != nil {
+			self, _, _ = tr.positionAt(id, history, *at)
+		}
+		assert.Equal(t, scanNearby(tr, self.Position, neighbours[4].Distance, at, id), nearbyIDs(neighbours))
+	}
+}
@@ -0,0 +1,64 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxPredictionPoints limits size of the predicted trajectory
+const maxPredictionPoints = 10000
+
+var ErrInvalidPrediction = errors.New("invalid prediction parameters")
+
+// Prediction is the trajectory collision evaluation assumes for the ship,
+// straight line from its last position with its last speed within [From, To]
+type Prediction struct {
+	ID        string
+	From, To  int
+	Speed     Vector
+	Positions []ShipPosition
+}
+
+// Predict returns positions of the ship every step seconds from its last position until horizon,
+// the end of the horizon is always included. Zero horizon is the prediction window.
+func (t *Traffic) Predict(id string, horizon, step int) (Prediction, error) {
+	if horizon == 0 {
+		horizon = t.cfg.PredictionWindow
+	}
+	if horizon < 0 || step <= 0 {
+		return Prediction{}, fmt.Errorf("%w: horizon and step must be positive", ErrInvalidPrediction)
+	}
+	if horizon/step >= maxPredictionPoints {
+		return Prediction{}, fmt.Errorf("%w: more than %d positions", ErrInvalidPrediction, maxPredictionPoints)
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	history, ok := t.store.History(id)
+	if !ok || len(history) == 0 {
+		return Prediction{}, ErrNotFound
+	}
+	last := history[len(history)-1]
+	// limit of the vessel class could be lowered after the position was recorded
+	speed := clampSpeed(last.Speed, t.maxSpeed(id))
+
+	p := Prediction{
+		ID:    id,
+		From:  last.Time,
+		To:    last.Time + horizon,
+		Speed: speed,
+	}
+	for offset := 0; ; offset = min(offset+step, horizon) {
+		p.Positions = append(p.Positions, ShipPosition{
+			Time:     last.Time + offset,
+			Speed:    speed,
+			Position: last.Position.Add(speed.ScalarMultiply(float64(offset))),
+		})
+		if offset == horizon {
+			break
+		}
+	}
+
+	return p, nil
+}
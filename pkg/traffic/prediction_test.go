@@ -0,0 +1,53 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredict(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ClassMaxSpeed = map[string]float64{"tanker": 5}
+	tr := mustNewTraffic(t, cfg)
+
+	_, err := tr.Predict("1", 60, 5)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 1000, Point: Vector{X: 0, Y: 100}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 1010, Point: Vector{X: 100, Y: 100}})
+	require.NoError(t, err)
+
+	p, err := tr.Predict("1", 25, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1010, p.From)
+	assert.Equal(t, 1035, p.To)
+	assert.Equal(t, Vector{X: 10, Y: 0}, p.Speed)
+	speed := Vector{X: 10, Y: 0}
+	assert.Equal(t, []ShipPosition{
+		{Time: 1010, Speed: speed, Position: Vector{X: 100, Y: 100}},
+		{Time: 1020, Speed: speed, Position: Vector{X: 200, Y: 100}},
+		{Time: 1030, Speed: speed, Position: Vector{X: 300, Y: 100}},
+		{Time: 1035, Speed: speed, Position: Vector{X: 350, Y: 100}},
+	}, p.Positions)
+
+	// prediction window by default
+	p, err = tr.Predict("1", 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1070, p.To)
+	assert.Len(t, p.Positions, 61)
+
+	// new limit of the vessel class applies to the recorded speed
+	require.NoError(t, tr.SetProfile("1", VesselProfile{Type: "tanker"}))
+	p, err = tr.Predict("1", 10, 10)
+	require.NoError(t, err)
+	assert.Equal(t, Vector{X: 5, Y: 0}, p.Speed)
+	assert.Equal(t, Vector{X: 150, Y: 100}, p.Positions[1].Position)
+
+	for _, params := range [][2]int{{-1, 1}, {60, 0}, {60, -5}, {maxPredictionPoints, 1}} {
+		_, err = tr.Predict("1", params[0], params[1])
+		assert.ErrorIs(t, err, ErrInvalidPrediction, params)
+	}
+}
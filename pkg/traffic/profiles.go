@@ -0,0 +1,89 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VesselProfile describes dimensions of the vessel, ship without profile is a point.
+// Safety domain is a circle around the ship position, its radius is SafetyRadius when set
+// or half of the largest dimension otherwise.
+type VesselProfile struct {
+	Type         string // tanker, cargo, pilot, ...
+	Length       float64
+	Beam         float64
+	SafetyRadius float64
+}
+
+var (
+	ErrProfileNotFound = errors.New("vessel profile not found")
+	ErrInvalidProfile  = errors.New("invalid vessel profile")
+)
+
+func (p VesselProfile) Validate() error {
+	if p.Length < 0 || p.Beam < 0 {
+		return fmt.Errorf("%w: dimensions can not be negative", ErrInvalidProfile)
+	}
+
+	if p.SafetyRadius < 0 {
+		return fmt.Errorf("%w: safety radius can not be negative", ErrInvalidProfile)
+	}
+
+	return nil
+}
+
+// Domain is the radius of the vessel safety domain
+func (p VesselProfile) Domain() float64 {
+	if p.SafetyRadius > 0 {
+		return p.SafetyRadius
+	}
+
+	return max(p.Length, p.Beam) / 2
+}
+
+// Profile returns vessel profile of the ship, ship doesn't have to have positions
+func (t *Traffic) Profile(id string) (VesselProfile, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	p, ok := t.store.Profile(id)
+	if !ok {
+		return VesselProfile{}, ErrProfileNotFound
+	}
+
+	return p, nil
+}
+
+// SetProfile adds or replaces vessel profile of the ship, it applies to positions recorded after the call
+func (t *Traffic) SetProfile(id string, p VesselProfile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.store.PutProfile(id, p); err != nil {
+		return err
+	}
+	// evaluations in flight used the old domain
+	t.commits.record(id)
+	t.updateMaxDomain()
+
+	return nil
+}
+
+// domain returns safety domain radius of the ship, zero for ship without profile
+func (t *Traffic) domain(id string) float64 {
+	p, _ := t.store.Profile(id)
+	return p.Domain()
+}
+
+// updateMaxDomain remembers the largest domain, spatial index widens the search by it
+func (t *Traffic) updateMaxDomain() {
+	t.maxDomain = 0
+	t.store.RangeProfiles(func(_ string, p VesselProfile) bool {
+		t.maxDomain = max(t.maxDomain, p.Domain())
+		return true
+	})
+}
@@ -0,0 +1,76 @@
+package traffic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVesselProfileDomain(t *testing.T) {
+	assert.Zero(t, VesselProfile{}.Domain())
+	assert.Equal(t, 150.0, VesselProfile{Type: "tanker", Length: 300, Beam: 50}.Domain())
+	assert.Equal(t, 500.0, VesselProfile{Type: "tanker", Length: 300, Beam: 50, SafetyRadius: 500}.Domain())
+
+	assert.NoError(t, VesselProfile{Length: 10}.Validate())
+	assert.ErrorIs(t, VesselProfile{Length: -1}.Validate(), ErrInvalidProfile)
+	assert.ErrorIs(t, VesselProfile{SafetyRadius: -1}.Validate(), ErrInvalidProfile)
+}
+
+func TestPositionShipProfiles(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	_, err := tr.PositionShip(PositionShip{ID: "pilot", Time: 100, Point: Vector{X: 1000, Y: 1000}})
+	require.NoError(t, err)
+	res, err := tr.PositionShip(PositionShip{ID: "tanker", Time: 100, Point: Vector{X: 1010, Y: 1000}})
+	require.NoError(t, err)
+	assert.Equal(t, Green, res.Status)
+
+	// 4 + 5 domains extend yellow threshold to 11 and red one to 10
+	require.NoError(t, tr.SetProfile("pilot", VesselProfile{Type: "pilot", Length: 8, Beam: 3}))
+	require.NoError(t, tr.SetProfile("tanker", VesselProfile{Type: "tanker", Length: 250, Beam: 40, SafetyRadius: 5}))
+	res, err = tr.PositionShip(PositionShip{ID: "tanker", Time: 101, Point: Vector{X: 1010, Y: 1000}})
+	require.NoError(t, err)
+	assert.Equal(t, Yellow, res.Status)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, 10.0, res.Conflicts[0].Distance)
+
+	// 4 + 6 extend red threshold to 11
+	require.NoError(t, tr.SetProfile("tanker", VesselProfile{Type: "tanker", Length: 250, Beam: 40, SafetyRadius: 6}))
+	res, err = tr.PositionShip(PositionShip{ID: "tanker", Time: 102, Point: Vector{X: 1010, Y: 1000}})
+	require.NoError(t, err)
+	assert.Equal(t, Red, res.Status)
+
+	p, err := tr.Profile("tanker")
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, p.Domain())
+	assert.ErrorIs(t, tr.SetProfile("tanker", VesselProfile{Beam: -1}), ErrInvalidProfile)
+
+	require.NoError(t, tr.DeleteShip("tanker"))
+	_, err = tr.Profile("tanker")
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+
+	require.NoError(t, tr.Flush())
+	_, err = tr.Profile("pilot")
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+}
+
+func TestPositionShipLargeDomain(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	// far away ships, so the index looks up cells instead of visiting everyone
+	for i := range 20 {
+		_, err := tr.PositionShip(PositionShip{ID: fmt.Sprintf("far-%d", i), Time: 100, Point: Vector{X: -1_000_000 * float64(i+1), Y: 0}})
+		require.NoError(t, err)
+	}
+
+	// domain is larger than the grid cell of the spatial index
+	require.NoError(t, tr.SetProfile("carrier", VesselProfile{SafetyRadius: 50_000}))
+	_, err := tr.PositionShip(PositionShip{ID: "carrier", Time: 100, Point: Vector{X: 100_000, Y: 100_000}})
+	require.NoError(t, err)
+
+	res, err := tr.PositionShip(PositionShip{ID: "boat", Time: 100, Point: Vector{X: 140_000, Y: 100_000}})
+	require.NoError(t, err)
+	assert.Equal(t, Red, res.Status)
+}
@@ -0,0 +1,231 @@
+package traffic
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// CompactResult is the number of removed positions, ships and incidents
+type CompactResult struct {
+	Positions int
+	Ships     int
+	Incidents int
+}
+
+// DeleteShip removes the ship with its whole history, incidents and vessel profile,
+// statuses of ships which could have seen it are re-evaluated
+func (t *Traffic) DeleteShip(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	history, ok := t.store.History(id)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := t.store.Delete(id); err != nil {
+		return err
+	}
+	t.index.remove(id)
+	t.commits.record(id)
+
+	// previous conflicts are compared using the domain of the ship, profile goes last
+	if err := t.reevaluateAffected(id, spliceResult{
+		since:           math.MinInt,
+		until:           math.MaxInt,
+		previousHistory: history,
+	}); err != nil {
+		return err
+	}
+	deleted, err := t.deleteProfile(id)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		t.updateMaxDomain()
+	}
+
+	return nil
+}
+
+// deleteProfile removes vessel profile of the removed ship, max domain is updated by the caller
+func (t *Traffic) deleteProfile(id string) (bool, error) {
+	if _, ok := t.store.Profile(id); !ok {
+		return false, nil
+	}
+
+	return true, t.store.DeleteProfile(id)
+}
+
+// Compact removes positions older than retention and keeps one position per downsample interval
+// for positions older than downsample age, ships without positions left are removed with their vessel profiles.
+// Incidents older than retention are removed as well.
+//
+// Last position of the ship is never downsampled and stored speeds are kept as they were reported,
+// so statuses don't change. Old history is used only by predictions in the past.
+// Ships which were in conflict with removed ships are re-evaluated, same as after DeleteShip.
+func (t *Traffic) Compact(now int) (CompactResult, error) {
+	var res CompactResult
+	if t.cfg.Retention == 0 && t.cfg.DownsampleAfter == 0 {
+		return res, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	type change struct {
+		id       string
+		from, to int
+		kept     []ShipPosition
+		status   Status
+		deleted  bool
+		history  []ShipPosition
+	}
+	var changes []change
+
+	// store can't be changed while ranging over it
+	t.store.Range(func(id string, history []ShipPosition, status Status) bool {
+		compacted := t.compactHistory(history, now)
+		if len(compacted) == len(history) {
+			return true
+		}
+
+		// history keeps its order, so only the changed range is written
+		prefix := 0
+		for prefix < len(compacted) && compacted[prefix] == history[prefix] {
+			prefix++
+		}
+		suffix := 0
+		for suffix < len(compacted)-prefix && compacted[len(compacted)-1-suffix] == history[len(history)-1-suffix] {
+			suffix++
+		}
+
+		res.Positions += len(history) - len(compacted)
+		changes = append(changes, change{
+			id:      id,
+			from:    prefix,
+			to:      len(history) - suffix,
+			kept:    compacted[prefix : len(compacted)-suffix],
+			status:  status,
+			deleted: len(compacted) == 0,
+			history: history,
+		})
+		return true
+	})
+
+	profilesDeleted := false
+	defer func() {
+		if profilesDeleted {
+			t.updateMaxDomain()
+		}
+	}()
+
+	var deleted []change
+	for _, c := range changes {
+		if c.deleted {
+			res.Ships++
+			if err := t.store.Delete(c.id); err != nil {
+				return res, err
+			}
+			t.index.remove(c.id)
+			deleted = append(deleted, c)
+		} else if err := t.store.Splice(c.id, c.from, c.to, c.kept, c.status); err != nil {
+			return res, err
+		}
+		t.commits.record(c.id)
+	}
+
+	// same as DeleteShip, all removed ships are out of the index first, so they are never re-evaluated
+	for _, c := range deleted {
+		if err := t.reevaluateAffected(c.id, spliceResult{
+			since:           math.MinInt,
+			until:           math.MaxInt,
+			previousHistory: c.history,
+		}); err != nil {
+			return res, err
+		}
+	}
+	for _, c := range deleted {
+		profileDeleted, err := t.deleteProfile(c.id)
+		if err != nil {
+			return res, err
+		}
+		profilesDeleted = profilesDeleted || profileDeleted
+	}
+
+	return res, t.compactIncidents(now, &res)
+}
+
+// compactIncidents removes incidents older than retention, log is trimmed only when there is something to remove
+func (t *Traffic) compactIncidents(now int, res *CompactResult) error {
+	if t.cfg.Retention == 0 {
+		return nil
+	}
+
+	before := now - t.cfg.Retention
+	t.store.RangeIncidents(func(i Incident) bool {
+		if i.Time < before {
+			res.Incidents++
+		}
+		return true
+	})
+	if res.Incidents == 0 {
+		return nil
+	}
+
+	return t.store.TrimIncidents(before)
+}
+
+// compactHistory returns history without expired positions and downsampled, history is not modified
+func (t *Traffic) compactHistory(history []ShipPosition, now int) []ShipPosition {
+	start := 0
+	if t.cfg.Retention > 0 {
+		for start < len(history) && history[start].Time < now-t.cfg.Retention {
+			start++
+		}
+	}
+	if start == len(history) {
+		return nil
+	}
+
+	if t.cfg.DownsampleAfter == 0 {
+		return history[start:]
+	}
+
+	var compacted []ShipPosition
+	for i := start; i < len(history); i++ {
+		pos := history[i]
+		old := pos.Time < now-t.cfg.DownsampleAfter && i < len(history)-1
+		// first position of every interval is kept
+		if old && len(compacted) > 0 && compacted[len(compacted)-1].Time/t.cfg.DownsampleInterval == pos.Time/t.cfg.DownsampleInterval {
+			continue
+		}
+		compacted = append(compacted, pos)
+	}
+
+	return compacted
+}
+
+// RunCompaction compacts history every interval until ctx is done
+func (t *Traffic) RunCompaction(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			res, err := t.Compact(int(now.Unix()))
+			if err != nil {
+				slog.Error("failed to compact history", "error", err)
+				continue
+			}
+			if res.Positions > 0 || res.Incidents > 0 {
+				slog.Info("history compacted", "positions", res.Positions, "ships", res.Ships, "incidents", res.Incidents)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteShip(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	_, err := tr.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 100, Y: 100}})
+	require.NoError(t, err)
+	res, err := tr.PositionShip(PositionShip{ID: "2", Time: 100, Point: Vector{X: 100, Y: 100}})
+	require.NoError(t, err)
+	require.Equal(t, Red, res.Status)
+
+	sub := tr.Subscribe(EventFilter{})
+	defer sub.Close()
+
+	require.NoError(t, tr.DeleteShip("1"))
+	assert.ErrorIs(t, tr.DeleteShip("1"), ErrNotFound)
+
+	_, err = tr.GetShipPositions("1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// ship 2 was red only because of ship 1
+	events := receive(t, sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventStatus, events[0].Kind)
+	assert.Equal(t, "2", events[0].ID)
+	assert.Equal(t, Green, events[0].Status)
+
+	res, err = tr.PositionShip(PositionShip{ID: "3", Time: 101, Point: Vector{X: 200, Y: 200}})
+	require.NoError(t, err)
+	assert.Equal(t, Green, res.Status)
+}
+
+func TestCompact(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Retention = 1000
+	cfg.DownsampleAfter = 100
+	cfg.DownsampleInterval = 60
+
+	history := func(times ...int) []ShipPosition {
+		positions := make([]ShipPosition, len(times))
+		for i, ts := range times {
+			positions[i] = ShipPosition{Time: ts, Position: Vector{X: float64(ts), Y: 5000}, Speed: Vector{X: 1}}
+		}
+		return positions
+	}
+
+	tr := newTrafficWithHistory(t, cfg, map[string][]ShipPosition{
+		"expired":   history(100, 200),
+		"old":       history(900, 1000, 1500, 1510, 1530, 1570, 1590, 1600, 1900, 1950, 1960, 1970),
+		"recent":    history(1950, 1960, 1970),
+		"stale":     history(1100, 1110, 1120),
+		"untouched": history(1020, 1900),
+	})
+	require.NoError(t, tr.SetProfile("expired", VesselProfile{SafetyRadius: 50_000}))
+	require.Equal(t, 50_000.0, tr.maxDomain)
+
+	res, err := tr.Compact(2000)
+	require.NoError(t, err)
+	assert.Equal(t, CompactResult{Positions: 2 + 5 + 1, Ships: 1}, res)
+
+	_, err = tr.GetShipPositions("expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+	// removed ship takes its profile and domain with it
+	_, err = tr.Profile("expired")
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+	assert.Zero(t, tr.maxDomain)
+
+	for id, times := range map[string][]int{
+		// 900 is expired, one per minute is kept before 1900, 1900 and later are kept
+		"old":    {1000, 1500, 1570, 1900, 1950, 1960, 1970},
+		"recent": {1950, 1960, 1970},
+		// last position is never downsampled
+		"stale":     {1100, 1120},
+		"untouched": {1020, 1900},
+	} {
+		positions, err := tr.GetShipPositions(id)
+		require.NoError(t, err)
+		assert.Equal(t, history(times...), positions, id)
+	}
+
+	// compaction is idempotent
+	res, err = tr.Compact(2000)
+	require.NoError(t, err)
+	assert.Zero(t, res)
+
+	// disabled by default
+	tr = newTrafficWithHistory(t, DefaultConfig(), map[string][]ShipPosition{"expired": history(100, 200)})
+	res, err = tr.Compact(2000)
+	require.NoError(t, err)
+	assert.Zero(t, res)
+}
+
+func TestCompactReevaluatesAffected(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Retention = 1000
+	tr := mustNewTraffic(t, cfg)
+
+	_, err := tr.PositionShip(PositionShip{ID: "expired", Time: 100, Point: Vector{X: 5000, Y: 5000}})
+	require.NoError(t, err)
+	res, err := tr.PositionShip(PositionShip{ID: "1", Time: 1050, Point: Vector{X: 5000.5, Y: 5000}})
+	require.NoError(t, err)
+	require.Equal(t, Red, res.Status)
+
+	sub := tr.Subscribe(EventFilter{})
+	defer sub.Close()
+
+	compacted, err := tr.Compact(1200)
+	require.NoError(t, err)
+	assert.Equal(t, 1, compacted.Ships)
+
+	// ship 1 was red only because of the expired ship
+	events := receive(t, sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventStatus, events[0].Kind)
+	assert.Equal(t, "1", events[0].ID)
+	assert.Equal(t, Green, events[0].Status)
+
+	page, err := tr.GetShips(ShipsQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Ships, 1)
+	assert.Equal(t, Green, page.Ships[0].LastStatus)
+}
@@ -0,0 +1,83 @@
+package traffic
+
+import "sort"
+
+// ShipState is the ship as of some moment, position is interpolated between its fixes
+// or extrapolated from the last one
+type ShipState struct {
+	ID        string
+	Time      int
+	Position  Vector
+	Speed     Vector
+	Status    Status
+	Conflicts []Conflict
+	// LastSeen is the time of the last fix at or before Time
+	LastSeen int
+	// Extrapolated ship has no fixes after Time, position is predicted with the last known speed
+	Extrapolated bool
+}
+
+// ShipsAt reconstructs traffic picture at time sorted by ship id, ships first seen after time are skipped.
+// Status is evaluated at the reconstructed position the same way as for a new position,
+// fixes recorded after time are used only to know trajectories between fixes.
+func (t *Traffic) ShipsAt(time int) []ShipState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var states []ShipState
+	t.store.Range(func(id string, history []ShipPosition, _ Status) bool {
+		if state, ok := t.shipAt(id, history, time); ok {
+			states = append(states, state)
+		}
+		return true
+	})
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].ID < states[j].ID
+	})
+
+	return states
+}
+
+// shipAt moves the ship to time along its trajectory and evaluates it there,
+// must be called under read or write lock
+func (t *Traffic) shipAt(id string, history []ShipPosition, time int) (ShipState, bool) {
+	at, lastSeen, ok := t.positionAt(id, history, time)
+	if !ok {
+		return ShipState{}, false
+	}
+
+	status, conflicts := t.evaluateTrafficStatus(PositionShip{ID: id, Time: time, Point: at.Position}, at.Speed)
+
+	return ShipState{
+		ID:           id,
+		Time:         time,
+		Position:     at.Position,
+		Speed:        at.Speed,
+		Status:       status,
+		Conflicts:    conflicts,
+		LastSeen:     lastSeen,
+		Extrapolated: lastSeen == history[len(history)-1].Time,
+	}, true
+}
+
+// positionAt interpolates position of the ship at time between its fixes or extrapolates it from the last one,
+// returns time of the last fix at or before time, false if ship was first seen after time
+func (t *Traffic) positionAt(id string, history []ShipPosition, time int) (ShipPosition, int, bool) {
+	if len(history) == 0 || history[0].Time > time {
+		return ShipPosition{}, 0, false
+	}
+
+	// same rewind as for other ships during evaluation, speed between fixes is the real one
+	cfg := t.cfg
+	cfg.MaxSpeed = t.maxSpeed(id)
+	candidates := rewindShipBinarySearch(history, PositionShip{ID: id, Time: time}, cfg)
+	if len(candidates) == 0 {
+		return ShipPosition{}, 0, false
+	}
+
+	next := sort.Search(len(history), func(i int) bool {
+		return history[i].Time > time
+	})
+
+	return candidates[0], history[next-1].Time, true
+}
@@ -0,0 +1,55 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShipsAt(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	for _, ps := range []PositionShip{
+		{ID: "a", Time: 1000, Point: Vector{X: 0, Y: 100}},
+		{ID: "b", Time: 1000, Point: Vector{X: 50, Y: 100.5}},
+		{ID: "a", Time: 1010, Point: Vector{X: 100, Y: 100}},
+		{ID: "c", Time: 1015, Point: Vector{X: 500, Y: 500}},
+		{ID: "b", Time: 1020, Point: Vector{X: 50, Y: 100.5}},
+	} {
+		_, err := tr.PositionShip(ps)
+		require.NoError(t, err)
+	}
+
+	assert.Empty(t, tr.ShipsAt(999))
+
+	// a passes b in the middle of its track, c is not seen yet
+	states := tr.ShipsAt(1005)
+	require.Len(t, states, 2)
+	a, b := states[0], states[1]
+	assert.Equal(t, "a", a.ID)
+	assert.Equal(t, 1005, a.Time)
+	assert.Equal(t, Vector{X: 50, Y: 100}, a.Position)
+	assert.Equal(t, Vector{X: 10, Y: 0}, a.Speed)
+	assert.Equal(t, 1000, a.LastSeen)
+	assert.False(t, a.Extrapolated)
+	assert.Equal(t, Red, a.Status)
+	require.Len(t, a.Conflicts, 1)
+	assert.Equal(t, "b", a.Conflicts[0].ID)
+	assert.InDelta(t, 0.5, a.Conflicts[0].Distance, epsilon)
+
+	assert.Equal(t, "b", b.ID)
+	assert.Equal(t, Vector{X: 50, Y: 100.5}, b.Position)
+	assert.Equal(t, Red, b.Status)
+
+	// a is extrapolated with its last speed
+	states = tr.ShipsAt(1020)
+	require.Len(t, states, 3)
+	a = states[0]
+	assert.Equal(t, Vector{X: 200, Y: 100}, a.Position)
+	assert.Equal(t, 1010, a.LastSeen)
+	assert.True(t, a.Extrapolated)
+	assert.Equal(t, Green, a.Status)
+	assert.Equal(t, "c", states[2].ID)
+	assert.Equal(t, 1015, states[2].LastSeen)
+}
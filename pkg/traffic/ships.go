@@ -0,0 +1,273 @@
+package traffic
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+const (
+	SortByID       ShipsSort = "id"
+	SortByLastTime ShipsSort = "last_time"
+	SortBySpeed    ShipsSort = "speed"
+)
+
+const (
+	// DefaultShipsLimit is the page size of the query without limit
+	DefaultShipsLimit = 1000
+	// MaxShipsLimit bounds the page, so a single query doesn't copy and sort every ship under the lock
+	MaxShipsLimit = 10000
+)
+
+var ErrInvalidShipsQuery = errors.New("invalid ships query")
+
+type (
+	ShipsSort string
+
+	// ShipsQuery selects a page of ships, zero value is the first page of ships sorted by id
+	ShipsQuery struct {
+		// Statuses keeps ships with one of the last statuses, any status when empty
+		Statuses []Status
+		// Area keeps ships with the last position inside of it
+		Area *Area
+		// SeenFrom and SeenTo keep ships with the last position time within the range, both included
+		SeenFrom, SeenTo *int
+		// Sort is the sort key, id by default. Ties are broken by id, so order is stable between pages
+		Sort ShipsSort
+		Desc bool
+		// Limit is the page size, DefaultShipsLimit when 0
+		Limit int
+		// Cursor is Next of the previous page, first page when empty
+		Cursor string
+	}
+
+	// ShipsPage is a page of ships, Next is the cursor of the next page, empty for the last one
+	ShipsPage struct {
+		Ships []Ship
+		Next  string
+	}
+
+	// shipKey is what ships are sorted by, cursor is the key of the last ship of the page
+	shipKey struct {
+		Sort  ShipsSort `json:"sort"`
+		Desc  bool      `json:"desc,omitempty"`
+		ID    string    `json:"id"`
+		Time  int       `json:"time,omitempty"`
+		Speed float64   `json:"speed,omitempty"`
+	}
+
+	// shipsHeap keeps the last ships of the page on top, so the page is selected without sorting all ships
+	shipsHeap struct {
+		ships []Ship
+		less  func(a, b Ship) bool
+	}
+)
+
+// GetShips returns a page of ships matching the query, ships are selected in a single pass
+// keeping no more than a page of them
+func (t *Traffic) GetShips(q ShipsQuery) (ShipsPage, error) {
+	if err := q.validate(); err != nil {
+		return ShipsPage{}, err
+	}
+	var after *Ship
+	if q.Cursor != "" {
+		key, err := q.parseCursor()
+		if err != nil {
+			return ShipsPage{}, err
+		}
+		ship := key.ship()
+		after = &ship
+	}
+	less := q.less
+	limit := q.limit()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	// one extra ship tells whether there is a next page
+	page := &shipsHeap{less: less}
+	t.store.Range(func(id string, positions []ShipPosition, status Status) bool {
+		ship := newShip(id, positions, status)
+		if !q.match(ship, positions) || (after != nil && !less(*after, ship)) {
+			return true
+		}
+
+		heap.Push(page, ship)
+		if page.Len() > limit+1 {
+			heap.Pop(page)
+		}
+		return true
+	})
+
+	ships := page.ships
+	sort.Slice(ships, func(i, j int) bool {
+		return less(ships[i], ships[j])
+	})
+
+	var next string
+	if len(ships) > limit {
+		ships = ships[:limit]
+		next = q.cursor(ships[len(ships)-1])
+	}
+
+	return ShipsPage{Ships: ships, Next: next}, nil
+}
+
+func newShip(id string, positions []ShipPosition, status Status) Ship {
+	ship := Ship{
+		ID:         id,
+		LastStatus: status,
+	}
+
+	if len(positions) > 0 {
+		lastPosition := positions[len(positions)-1]
+
+		ship.LastSeen = strconv.Itoa(lastPosition.Time)
+		ship.LastSpeed = lastPosition.Speed.Magnitude()
+		ship.LastPosition = lastPosition.Position
+	}
+
+	return ship
+}
+
+func (q ShipsQuery) validate() error {
+	switch q.Sort {
+	case "", SortByID, SortByLastTime, SortBySpeed:
+	default:
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalidShipsQuery, q.Sort)
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: limit must not be negative", ErrInvalidShipsQuery)
+	}
+	if q.Limit > MaxShipsLimit {
+		return fmt.Errorf("%w: limit must not exceed %d", ErrInvalidShipsQuery, MaxShipsLimit)
+	}
+	if q.Area != nil {
+		if err := q.Area.Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidShipsQuery, err)
+		}
+	}
+	if q.SeenFrom != nil && q.SeenTo != nil && *q.SeenFrom > *q.SeenTo {
+		return fmt.Errorf("%w: seen from must not be after seen to", ErrInvalidShipsQuery)
+	}
+
+	return nil
+}
+
+func (q ShipsQuery) match(ship Ship, positions []ShipPosition) bool {
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, ship.LastStatus) {
+		return false
+	}
+	if q.Area == nil && q.SeenFrom == nil && q.SeenTo == nil {
+		return true
+	}
+	if len(positions) == 0 {
+		return false
+	}
+
+	last := positions[len(positions)-1]
+	return (q.Area == nil || q.Area.Contains(last.Position)) &&
+		(q.SeenFrom == nil || last.Time >= *q.SeenFrom) &&
+		(q.SeenTo == nil || last.Time <= *q.SeenTo)
+}
+
+// less tells whether ship a goes before ship b in the query order
+func (q ShipsQuery) less(a, b Ship) bool {
+	if q.Desc {
+		a, b = b, a
+	}
+
+	switch q.Sort {
+	case SortByLastTime:
+		if ta, tb := lastTime(a), lastTime(b); ta != tb {
+			return ta < tb
+		}
+	case SortBySpeed:
+		if a.LastSpeed != b.LastSpeed {
+			return a.LastSpeed < b.LastSpeed
+		}
+	}
+
+	return a.ID < b.ID
+}
+
+// lastTime of the ship without positions goes before any other time
+func lastTime(ship Ship) int {
+	time, err := strconv.Atoi(ship.LastSeen)
+	if err != nil {
+		return math.MinInt
+	}
+	return time
+}
+
+func (q ShipsQuery) limit() int {
+	if q.Limit == 0 {
+		return DefaultShipsLimit
+	}
+	return q.Limit
+}
+
+func (q ShipsQuery) sortKey() ShipsSort {
+	if q.Sort == "" {
+		return SortByID
+	}
+	return q.Sort
+}
+
+func (q ShipsQuery) cursor(ship Ship) string {
+	key := shipKey{Sort: q.sortKey(), Desc: q.Desc, ID: ship.ID}
+	switch key.Sort {
+	case SortByLastTime:
+		key.Time = lastTime(ship)
+	case SortBySpeed:
+		key.Speed = ship.LastSpeed
+	}
+
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseCursor decodes the cursor, it must come from the query with the same order
+func (q ShipsQuery) parseCursor() (shipKey, error) {
+	var key shipKey
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &key)
+	}
+	if err != nil {
+		return key, fmt.Errorf("%w: malformed cursor", ErrInvalidShipsQuery)
+	}
+	if key.Sort != q.sortKey() || key.Desc != q.Desc {
+		return key, fmt.Errorf("%w: cursor is for a different sort order", ErrInvalidShipsQuery)
+	}
+
+	return key, nil
+}
+
+// ship is a ship with the sort key of the cursor
+func (key shipKey) ship() Ship {
+	ship := Ship{ID: key.ID, LastSpeed: key.Speed}
+	if key.Time != math.MinInt {
+		ship.LastSeen = strconv.Itoa(key.Time)
+	}
+	return ship
+}
+
+func (h *shipsHeap) Len() int { return len(h.ships) }
+
+// Less puts the ship which goes last on top
+func (h *shipsHeap) Less(i, j int) bool { return h.less(h.ships[j], h.ships[i]) }
+func (h *shipsHeap) Swap(i, j int)      { h.ships[i], h.ships[j] = h.ships[j], h.ships[i] }
+func (h *shipsHeap) Push(x any)         { h.ships = append(h.ships, x.(Ship)) }
+func (h *shipsHeap) Pop() any {
+	old := h.ships
+	ship := old[len(old)-1]
+	h.ships = old[:len(old)-1]
+	return ship
+}
@@ -0,0 +1,129 @@
+package traffic
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shipIDs(ships []Ship) []string {
+	var ids []string
+	for _, ship := range ships {
+		ids = append(ids, ship.ID)
+	}
+	return ids
+}
+
+func TestGetShipsFilter(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	for _, ps := range []PositionShip{
+		{ID: "a", Time: 1000, Point: Vector{X: 1000, Y: 1000}},
+		{ID: "b", Time: 1000, Point: Vector{X: 1000.5, Y: 1000}},
+		{ID: "c", Time: 1010, Point: Vector{X: 5000, Y: 5000}},
+		{ID: "d", Time: 1020, Point: Vector{X: 9000, Y: 1000}},
+	} {
+		_, err := tr.PositionShip(ps)
+		require.NoError(t, err)
+	}
+
+	page, err := tr.GetShips(ShipsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, shipIDs(page.Ships))
+	assert.Empty(t, page.Next)
+
+	page, err = tr.GetShips(ShipsQuery{Statuses: []Status{Red}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, shipIDs(page.Ships))
+
+	page, err = tr.GetShips(ShipsQuery{Area: &Area{Min: Vector{X: 0, Y: 0}, Max: Vector{X: 5000, Y: 5000}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, shipIDs(page.Ships))
+
+	from, to := 1010, 1020
+	page, err = tr.GetShips(ShipsQuery{SeenFrom: &from, SeenTo: &to, Statuses: []Status{Green, Yellow}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, shipIDs(page.Ships))
+
+	page, err = tr.GetShips(ShipsQuery{Sort: SortByLastTime, Desc: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "c", "b", "a"}, shipIDs(page.Ships))
+
+	for _, q := range []ShipsQuery{
+		{Sort: "name"},
+		{Limit: -1},
+		{Limit: MaxShipsLimit + 1},
+		{Area: &Area{Min: Vector{X: 1, Y: 1}}},
+		{SeenFrom: &to, SeenTo: &from},
+		{Cursor: "not a cursor"},
+	} {
+		_, err := tr.GetShips(q)
+		assert.ErrorIs(t, err, ErrInvalidShipsQuery, "%+v", q)
+	}
+}
+
+func TestGetShipsPagination(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	// few distinct times and speeds, so ties are broken by id
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := range 100 {
+		id := fmt.Sprintf("%03d", i)
+		x, y := rnd.Float64()*1e6, rnd.Float64()*1e6
+		time := 1000 + rnd.IntN(5)
+		_, err := tr.PositionShip(PositionShip{ID: id, Time: time, Point: Vector{X: x, Y: y}})
+		require.NoError(t, err)
+		_, err = tr.PositionShip(PositionShip{ID: id, Time: time + 1, Point: Vector{X: x + float64(rnd.IntN(3)), Y: y}})
+		require.NoError(t, err)
+	}
+
+	for _, q := range []ShipsQuery{
+		{Sort: SortByID},
+		{Sort: SortByID, Desc: true},
+		{Sort: SortByLastTime},
+		{Sort: SortBySpeed, Desc: true},
+		{Sort: SortBySpeed, Statuses: []Status{Green}},
+	} {
+		all, err := tr.GetShips(q)
+		require.NoError(t, err)
+		require.NotEmpty(t, all.Ships)
+
+		var ships []Ship
+		pages := 0
+		for q.Limit = 7; ; pages++ {
+			page, err := tr.GetShips(q)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(page.Ships), q.Limit)
+			ships = append(ships, page.Ships...)
+			if page.Next == "" {
+				break
+			}
+			q.Cursor = page.Next
+		}
+
+		assert.Equal(t, all.Ships, ships, "%+v", q)
+		assert.Equal(t, (len(all.Ships)-1)/7, pages)
+	}
+
+	// default page size
+	for i := range DefaultShipsLimit {
+		_, err := tr.PositionShip(PositionShip{ID: fmt.Sprintf("x%04d", i), Time: 1000, Point: Vector{X: float64(i) * 1e4, Y: -1e6}})
+		require.NoError(t, err)
+	}
+	page, err := tr.GetShips(ShipsQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Ships, DefaultShipsLimit)
+	page, err = tr.GetShips(ShipsQuery{Cursor: page.Next})
+	require.NoError(t, err)
+	assert.Len(t, page.Ships, 100)
+	assert.Empty(t, page.Next)
+
+	// cursor belongs to the sort order
+	page, err = tr.GetShips(ShipsQuery{Sort: SortBySpeed, Limit: 10})
+	require.NoError(t, err)
+	_, err = tr.GetShips(ShipsQuery{Sort: SortBySpeed, Desc: true, Limit: 10, Cursor: page.Next})
+	assert.ErrorIs(t, err, ErrInvalidShipsQuery)
+}
@@ -0,0 +1,55 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	_, err := tr.PositionShip(PositionShip{ID: "a", Time: 1000, Point: Vector{X: 0, Y: 100}})
+	require.NoError(t, err)
+	_, err = tr.PositionShip(PositionShip{ID: "b", Time: 1000, Point: Vector{X: 10, Y: 100}})
+	require.NoError(t, err)
+
+	sub := tr.Subscribe(EventFilter{})
+	defer sub.Close()
+
+	// b moving next to a would be red
+	res, err := tr.Simulate(PositionShip{ID: "b", Time: 1001, Point: Vector{X: 0.5, Y: 100}})
+	require.NoError(t, err)
+	assert.Equal(t, Red, res.Status)
+	assert.True(t, res.StatusChanged)
+	assert.Equal(t, 9.5, res.Speed)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, "a", res.Conflicts[0].ID)
+
+	// unknown ship is evaluated as a new one
+	res, err = tr.Simulate(PositionShip{ID: "c", Time: 1001, Point: Vector{X: 0, Y: 101.5}})
+	require.NoError(t, err)
+	assert.Equal(t, Yellow, res.Status)
+
+	_, err = tr.Simulate(PositionShip{ID: "b", Time: 1000, Point: Vector{X: 0.5, Y: 100}})
+	assert.ErrorIs(t, err, ErrTimeInPast)
+
+	// nothing is recorded or published
+	history, err := tr.GetShipPositions("b")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+	assert.Equal(t, Green, tr.store.Status("b"))
+	assert.Equal(t, 2, tr.store.Len())
+	assert.Empty(t, sub.C)
+
+	// recording gives the simulated result
+	ps := PositionShip{ID: "b", Time: 1001, Point: Vector{X: 0.5, Y: 100}}
+	simulated, err := tr.Simulate(ps)
+	require.NoError(t, err)
+	recorded, err := tr.PositionShip(ps)
+	require.NoError(t, err)
+	assert.Equal(t, simulated.Status, recorded.Status)
+	assert.Equal(t, simulated.Speed, recorded.Speed)
+	assert.Equal(t, simulated.Conflicts, recorded.Conflicts)
+}
@@ -0,0 +1,606 @@
+package traffic
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SnapshotVersion of the JSON lines snapshot format, it is bumped when record kinds are added,
+// so older binaries reject snapshots they can't fully read. Older snapshots are still read.
+//
+//	1 - ships
+//	2 - speed anomalies, vessel profiles, hazards, geofences, audit and incident logs
+//
+// first line is a header, every next line is a record:
+//
+//	{"version":2,"seq":42}
+//	{"kind":"ship","ship":{"id":"123","status":1,"positions":[{"t":100,"x":1,"y":2,"vx":0,"vy":0}]}}
+//	{"kind":"hazard","hazard":{"id":"tower","x":0,"y":0,"radius":0,"red_radius":1,"yellow_radius":2}}
+//	{"kind":"geofence","geofence":{"id":"anchorage","polygon":[[0,0],[10,0],[10,10]],"status":2}}
+//	{"kind":"anomaly","anomaly":{"ship_id":"123","t":100,"raw_speed":250,"speed":100,"max_speed":100}}
+//	{"kind":"profile","profile":{"id":"123","type":"tanker","length":250,"beam":40,"safety_radius":0}}
+//	{"kind":"audit","audit":{"time":"2024-01-02T03:04:05Z","operator":"jane","action":"delete","ship_id":"123","before":{"t":90,"x":0,"y":0,"vx":0,"vy":0}}}
+//	{"kind":"incident","incident":{"ship_id":"123","t":100,"from":0,"to":2,"counterpart":{"kind":"ship","id":"345","status":2,"distance":0.5,"cpa_time":100,"x":1,"y":2,"other_x":1,"other_y":2.5}}}
+const SnapshotVersion = 2
+
+const (
+	recordKindShip     = "ship"
+	recordKindAnomaly  = "anomaly"
+	recordKindHazard   = "hazard"
+	recordKindGeofence = "geofence"
+	recordKindProfile  = "profile"
+	recordKindAudit    = "audit"
+	recordKindIncident = "incident"
+)
+
+// recordKindVersions is the snapshot version each record kind was added in
+var recordKindVersions = map[string]int{
+	recordKindShip:     1,
+	recordKindAnomaly:  2,
+	recordKindHazard:   2,
+	recordKindGeofence: 2,
+	recordKindProfile:  2,
+	recordKindAudit:    2,
+	recordKindIncident: 2,
+}
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+	ErrSnapshotVersion = errors.New("unsupported snapshot version")
+)
+
+type (
+	// SnapshotHeader describes snapshot, Seq is a sequence number of the last change
+	// included into the snapshot and Seeded is the seeded mark of the store, both used by stores with change logs
+	SnapshotHeader struct {
+		Version int    `json:"version"`
+		Seq     uint64 `json:"seq,omitempty"`
+		Seeded  bool   `json:"seeded,omitempty"`
+	}
+
+	ShipRecord struct {
+		ID        string           `json:"id"`
+		Status    Status           `json:"status"`
+		Positions []PositionRecord `json:"positions"`
+	}
+
+	PositionRecord struct {
+		Time int     `json:"t"`
+		X    float64 `json:"x"`
+		Y    float64 `json:"y"`
+		VX   float64 `json:"vx"`
+		VY   float64 `json:"vy"`
+	}
+
+	AnomalyRecord struct {
+		ShipID   string  `json:"ship_id"`
+		Time     int     `json:"t"`
+		RawSpeed float64 `json:"raw_speed"`
+		Speed    float64 `json:"speed"`
+		MaxSpeed float64 `json:"max_speed"`
+	}
+
+	HazardRecord struct {
+		ID           string  `json:"id"`
+		X            float64 `json:"x"`
+		Y            float64 `json:"y"`
+		Radius       float64 `json:"radius"`
+		RedRadius    float64 `json:"red_radius"`
+		YellowRadius float64 `json:"yellow_radius"`
+	}
+
+	// GeofenceRecord has polygon vertices as [x, y] pairs, geofence without status is red as it was before status was added
+	GeofenceRecord struct {
+		ID      string       `json:"id"`
+		Polygon [][2]float64 `json:"polygon"`
+		Status  *Status      `json:"status,omitempty"`
+	}
+
+	ProfileRecord struct {
+		ID           string  `json:"id"`
+		Type         string  `json:"type,omitempty"`
+		Length       float64 `json:"length"`
+		Beam         float64 `json:"beam"`
+		SafetyRadius float64 `json:"safety_radius"`
+	}
+
+	IncidentRecord struct {
+		ShipID      string          `json:"ship_id"`
+		Time        int             `json:"t"`
+		From        Status          `json:"from"`
+		To          Status          `json:"to"`
+		Counterpart *ConflictRecord `json:"counterpart,omitempty"`
+	}
+
+	ConflictRecord struct {
+		Kind     ConflictKind  `json:"kind"`
+		ID       string        `json:"id"`
+		Alert    GeofenceAlert `json:"alert,omitempty"`
+		Status   Status        `json:"status"`
+		Distance float64       `json:"distance"`
+		Time     float64       `json:"cpa_time"`
+		X        float64       `json:"x"`
+		Y        float64       `json:"y"`
+		OtherX   float64       `json:"other_x"`
+		OtherY   float64       `json:"other_y"`
+	}
+
+	snapshotRecord struct {
+		Kind     string          `json:"kind"`
+		Ship     *ShipRecord     `json:"ship,omitempty"`
+		Anomaly  *AnomalyRecord  `json:"anomaly,omitempty"`
+		Hazard   *HazardRecord   `json:"hazard,omitempty"`
+		Geofence *GeofenceRecord `json:"geofence,omitempty"`
+		Profile  *ProfileRecord  `json:"profile,omitempty"`
+		Audit    *AuditRecord    `json:"audit,omitempty"`
+		Incident *IncidentRecord `json:"incident,omitempty"`
+	}
+)
+
+func NewShipRecord(id string, history []ShipPosition, status Status) ShipRecord {
+	positions := make([]PositionRecord, len(history))
+	for i, pos := range history {
+		positions[i] = NewPositionRecord(pos)
+	}
+
+	return ShipRecord{
+		ID:        id,
+		Status:    status,
+		Positions: positions,
+	}
+}
+
+// Validate checks that positions are in strictly increasing time order, history lookups rely on it
+func (r ShipRecord) Validate() error {
+	for i := 1; i < len(r.Positions); i++ {
+		if r.Positions[i].Time <= r.Positions[i-1].Time {
+			return fmt.Errorf("ship %q positions must have strictly increasing times, %d goes after %d",
+				r.ID, r.Positions[i].Time, r.Positions[i-1].Time)
+		}
+	}
+
+	return nil
+}
+
+func (r ShipRecord) History() []ShipPosition {
+	history := make([]ShipPosition, len(r.Positions))
+	for i, pos := range r.Positions {
+		history[i] = pos.ShipPosition()
+	}
+
+	return history
+}
+
+func NewPositionRecord(pos ShipPosition) PositionRecord {
+	return PositionRecord{
+		Time: pos.Time,
+		X:    pos.Position.X,
+		Y:    pos.Position.Y,
+		VX:   pos.Speed.X,
+		VY:   pos.Speed.Y,
+	}
+}
+
+func (r PositionRecord) ShipPosition() ShipPosition {
+	return ShipPosition{
+		Time:     r.Time,
+		Position: Vector{X: r.X, Y: r.Y},
+		Speed:    Vector{X: r.VX, Y: r.VY},
+	}
+}
+
+func NewAnomalyRecord(id string, a SpeedAnomaly) AnomalyRecord {
+	return AnomalyRecord{
+		ShipID:   id,
+		Time:     a.Time,
+		RawSpeed: a.RawSpeed,
+		Speed:    a.Speed,
+		MaxSpeed: a.MaxSpeed,
+	}
+}
+
+func (r AnomalyRecord) SpeedAnomaly() SpeedAnomaly {
+	return SpeedAnomaly{
+		Time:     r.Time,
+		RawSpeed: r.RawSpeed,
+		Speed:    r.Speed,
+		MaxSpeed: r.MaxSpeed,
+	}
+}
+
+func NewHazardRecord(h Hazard) HazardRecord {
+	return HazardRecord{
+		ID:           h.ID,
+		X:            h.Position.X,
+		Y:            h.Position.Y,
+		Radius:       h.Radius,
+		RedRadius:    h.RedRadius,
+		YellowRadius: h.YellowRadius,
+	}
+}
+
+func (r HazardRecord) Hazard() Hazard {
+	return Hazard{
+		ID:           r.ID,
+		Position:     Vector{X: r.X, Y: r.Y},
+		Radius:       r.Radius,
+		RedRadius:    r.RedRadius,
+		YellowRadius: r.YellowRadius,
+	}
+}
+
+func NewGeofenceRecord(g Geofence) GeofenceRecord {
+	polygon := make([][2]float64, len(g.Polygon))
+	for i, v := range g.Polygon {
+		polygon[i] = [2]float64{v.X, v.Y}
+	}
+
+	return GeofenceRecord{ID: g.ID, Polygon: polygon, Status: &g.Status}
+}
+
+func (r GeofenceRecord) Geofence() Geofence {
+	polygon := make([]Vector, len(r.Polygon))
+	for i, v := range r.Polygon {
+		polygon[i] = Vector{X: v[0], Y: v[1]}
+	}
+
+	status := Red
+	if r.Status != nil {
+		status = *r.Status
+	}
+
+	return Geofence{ID: r.ID, Polygon: polygon, Status: status}
+}
+
+func NewProfileRecord(id string, p VesselProfile) ProfileRecord {
+	return ProfileRecord{
+		ID:           id,
+		Type:         p.Type,
+		Length:       p.Length,
+		Beam:         p.Beam,
+		SafetyRadius: p.SafetyRadius,
+	}
+}
+
+func (r ProfileRecord) Profile() VesselProfile {
+	return VesselProfile{
+		Type:         r.Type,
+		Length:       r.Length,
+		Beam:         r.Beam,
+		SafetyRadius: r.SafetyRadius,
+	}
+}
+
+func NewIncidentRecord(i Incident) IncidentRecord {
+	record := IncidentRecord{
+		ShipID: i.ShipID,
+		Time:   i.Time,
+		From:   i.From,
+		To:     i.To,
+	}
+	if c := i.Counterpart; c != nil {
+		record.Counterpart = &ConflictRecord{
+			Kind:     c.Kind,
+			ID:       c.ID,
+			Alert:    c.Alert,
+			Status:   c.Status,
+			Distance: c.Distance,
+			Time:     c.Time,
+			X:        c.Position.X,
+			Y:        c.Position.Y,
+			OtherX:   c.OtherPosition.X,
+			OtherY:   c.OtherPosition.Y,
+		}
+	}
+
+	return record
+}
+
+func (r IncidentRecord) Incident() Incident {
+	incident := Incident{
+		ShipID: r.ShipID,
+		Time:   r.Time,
+		From:   r.From,
+		To:     r.To,
+	}
+	if c := r.Counterpart; c != nil {
+		incident.Counterpart = &Conflict{
+			Kind:          c.Kind,
+			ID:            c.ID,
+			Alert:         c.Alert,
+			Status:        c.Status,
+			Distance:      c.Distance,
+			Time:          c.Time,
+			Position:      Vector{X: c.X, Y: c.Y},
+			OtherPosition: Vector{X: c.OtherX, Y: c.OtherY},
+		}
+	}
+
+	return incident
+}
+
+// WriteSnapshot writes all ships, speed anomalies, vessel profiles, hazards, geofences, audit and incident logs from the store
+func WriteSnapshot(w io.Writer, header SnapshotHeader, store Store) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	header.Version = SnapshotVersion
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+
+	var err error
+	store.Range(func(id string, history []ShipPosition, status Status) bool {
+		ship := NewShipRecord(id, history, status)
+		err = enc.Encode(snapshotRecord{Kind: recordKindShip, Ship: &ship})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store.RangeAnomalies(func(id string, a SpeedAnomaly) bool {
+		anomaly := NewAnomalyRecord(id, a)
+		err = enc.Encode(snapshotRecord{Kind: recordKindAnomaly, Anomaly: &anomaly})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store.RangeProfiles(func(id string, p VesselProfile) bool {
+		profile := NewProfileRecord(id, p)
+		err = enc.Encode(snapshotRecord{Kind: recordKindProfile, Profile: &profile})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store.RangeHazards(func(h Hazard) bool {
+		hazard := NewHazardRecord(h)
+		err = enc.Encode(snapshotRecord{Kind: recordKindHazard, Hazard: &hazard})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store.RangeGeofences(func(g Geofence) bool {
+		geofence := NewGeofenceRecord(g)
+		err = enc.Encode(snapshotRecord{Kind: recordKindGeofence, Geofence: &geofence})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store.RangeAudit(func(record AuditRecord) bool {
+		err = enc.Encode(snapshotRecord{Kind: recordKindAudit, Audit: &record})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store.RangeIncidents(func(i Incident) bool {
+		incident := NewIncidentRecord(i)
+		err = enc.Encode(snapshotRecord{Kind: recordKindIncident, Incident: &incident})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// ReadSnapshot puts all ships, speed anomalies, vessel profiles, hazards, geofences, audit and incident logs from the snapshot to the store, store is not flushed
+func ReadSnapshot(r io.Reader, store Store) (SnapshotHeader, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var header SnapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return header, fmt.Errorf("%w: failed to read header: %w", ErrInvalidSnapshot, err)
+	}
+	if header.Version < 1 || header.Version > SnapshotVersion {
+		return header, fmt.Errorf("%w: %w: %d", ErrInvalidSnapshot, ErrSnapshotVersion, header.Version)
+	}
+
+	for {
+		var record snapshotRecord
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			return header, nil
+		}
+		if err != nil {
+			return header, fmt.Errorf("%w: failed to read record: %w", ErrInvalidSnapshot, err)
+		}
+
+		if version, ok := recordKindVersions[record.Kind]; ok && version > header.Version {
+			return header, fmt.Errorf("%w: %q record in version %d snapshot", ErrInvalidSnapshot, record.Kind, header.Version)
+		}
+
+		switch record.Kind {
+		case recordKindShip:
+			if record.Ship == nil {
+				return header, fmt.Errorf("%w: ship record is empty", ErrInvalidSnapshot)
+			}
+			if err := record.Ship.Validate(); err != nil {
+				return header, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+			}
+			if err := store.Put(record.Ship.ID, record.Ship.History(), record.Ship.Status); err != nil {
+				return header, err
+			}
+		case recordKindAnomaly:
+			if record.Anomaly == nil {
+				return header, fmt.Errorf("%w: anomaly record is empty", ErrInvalidSnapshot)
+			}
+			if err := store.AppendAnomaly(record.Anomaly.ShipID, record.Anomaly.SpeedAnomaly()); err != nil {
+				return header, err
+			}
+		case recordKindHazard:
+			if record.Hazard == nil {
+				return header, fmt.Errorf("%w: hazard record is empty", ErrInvalidSnapshot)
+			}
+			if err := record.Hazard.Hazard().Validate(); err != nil {
+				return header, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+			}
+			if err := store.PutHazard(record.Hazard.Hazard()); err != nil {
+				return header, err
+			}
+		case recordKindGeofence:
+			if record.Geofence == nil {
+				return header, fmt.Errorf("%w: geofence record is empty", ErrInvalidSnapshot)
+			}
+			if err := record.Geofence.Geofence().Validate(); err != nil {
+				return header, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+			}
+			if err := store.PutGeofence(record.Geofence.Geofence()); err != nil {
+				return header, err
+			}
+		case recordKindProfile:
+			if record.Profile == nil {
+				return header, fmt.Errorf("%w: profile record is empty", ErrInvalidSnapshot)
+			}
+			if err := record.Profile.Profile().Validate(); err != nil {
+				return header, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+			}
+			if err := store.PutProfile(record.Profile.ID, record.Profile.Profile()); err != nil {
+				return header, err
+			}
+		case recordKindAudit:
+			if record.Audit == nil {
+				return header, fmt.Errorf("%w: audit record is empty", ErrInvalidSnapshot)
+			}
+			if err := store.AppendAudit(*record.Audit); err != nil {
+				return header, err
+			}
+		case recordKindIncident:
+			if record.Incident == nil {
+				return header, fmt.Errorf("%w: incident record is empty", ErrInvalidSnapshot)
+			}
+			if err := store.AppendIncident(record.Incident.Incident()); err != nil {
+				return header, err
+			}
+		default:
+			return header, fmt.Errorf("%w: unknown record kind %q", ErrInvalidSnapshot, record.Kind)
+		}
+	}
+}
+
+// Export writes snapshot of all ships, speed anomalies, vessel profiles, hazards, geofences, audit and incident logs
+func (t *Traffic) Export(w io.Writer) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return WriteSnapshot(w, SnapshotHeader{}, t.store)
+}
+
+// Import replaces all ships, speed anomalies, vessel profiles, hazards, geofences, audit and incident logs with the snapshot,
+// state is not changed if snapshot can't be read. Snapshot of the version without hazards and geofences keeps current ones.
+func (t *Traffic) Import(r io.Reader) error {
+	imported := NewMemoryStore()
+	header, err := ReadSnapshot(r, imported)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.store.Flush(); err != nil {
+		return err
+	}
+
+	imported.Range(func(id string, history []ShipPosition, status Status) bool {
+		err = t.store.Put(id, history, status)
+		return err == nil
+	})
+	if err == nil {
+		imported.RangeAnomalies(func(id string, a SpeedAnomaly) bool {
+			err = t.store.AppendAnomaly(id, a)
+			return err == nil
+		})
+	}
+	if err == nil {
+		imported.RangeProfiles(func(id string, p VesselProfile) bool {
+			err = t.store.PutProfile(id, p)
+			return err == nil
+		})
+	}
+	if err == nil && header.Version >= recordKindVersions[recordKindHazard] {
+		err = replaceHazards(t.store, imported)
+	}
+	if err == nil && header.Version >= recordKindVersions[recordKindGeofence] {
+		err = replaceGeofences(t.store, imported)
+	}
+	if err == nil {
+		imported.RangeAudit(func(record AuditRecord) bool {
+			err = t.store.AppendAudit(record)
+			return err == nil
+		})
+	}
+	if err == nil {
+		imported.RangeIncidents(func(i Incident) bool {
+			err = t.store.AppendIncident(i)
+			return err == nil
+		})
+	}
+	t.rebuildIndex()
+	t.updateMaxDomain()
+	t.commits.reset()
+
+	return err
+}
+
+// replaceHazards makes hazards of the store the same as hazards of the source
+func replaceHazards(store Store, source Store) error {
+	var ids []string
+	store.RangeHazards(func(h Hazard) bool {
+		if _, ok := source.Hazard(h.ID); !ok {
+			ids = append(ids, h.ID)
+		}
+		return true
+	})
+	for _, id := range ids {
+		if err := store.DeleteHazard(id); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	source.RangeHazards(func(h Hazard) bool {
+		err = store.PutHazard(h)
+		return err == nil
+	})
+
+	return err
+}
+
+// replaceGeofences makes geofences of the store the same as geofences of the source
+func replaceGeofences(store Store, source Store) error {
+	var ids []string
+	store.RangeGeofences(func(g Geofence) bool {
+		if _, ok := source.Geofence(g.ID); !ok {
+			ids = append(ids, g.ID)
+		}
+		return true
+	})
+	for _, id := range ids {
+		if err := store.DeleteGeofence(id); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	source.RangeGeofences(func(g Geofence) bool {
+		err = store.PutGeofence(g)
+		return err == nil
+	})
+
+	return err
+}
@@ -0,0 +1,160 @@
+package traffic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	store := NewMemoryStore()
+	require.NoError(t, store.Put("1", []ShipPosition{
+		{Time: 100, Position: Vector{X: 1, Y: 2}},
+		{Time: 101, Position: Vector{X: 2, Y: 3.5}, Speed: Vector{X: 1, Y: 1.5}},
+	}, Yellow))
+	require.NoError(t, store.Put("2", []ShipPosition{
+		{Time: 50, Position: Vector{X: -1, Y: -2}},
+	}, Red))
+	require.NoError(t, store.AppendAnomaly("1", SpeedAnomaly{Time: 101, RawSpeed: 250.5, Speed: 100, MaxSpeed: 100}))
+	require.NoError(t, store.PutHazard(Hazard{ID: "wreck", Position: Vector{X: 5, Y: -5}, Radius: 3, RedRadius: 1, YellowRadius: 4}))
+	require.NoError(t, store.PutProfile("1", VesselProfile{Type: "tanker", Length: 250, Beam: 40, SafetyRadius: 300}))
+	require.NoError(t, store.PutGeofence(Geofence{ID: "anchorage", Polygon: []Vector{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10.5}}, Status: Yellow}))
+	require.NoError(t, store.AppendAudit(AuditRecord{
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Operator: "jane",
+		Action:   AuditDelete,
+		ShipID:   "2",
+		Before:   PositionRecord{Time: 40, X: 5, Y: 5},
+	}))
+	require.NoError(t, store.AppendIncident(Incident{
+		ShipID: "2",
+		Time:   50,
+		From:   Green,
+		To:     Red,
+		Counterpart: &Conflict{
+			Kind:          KindGeofence,
+			ID:            "anchorage",
+			Alert:         GeofenceEnter,
+			Status:        Red,
+			Distance:      0.5,
+			Time:          50.5,
+			Position:      Vector{X: 1, Y: 2},
+			OtherPosition: Vector{X: 1, Y: 2.5},
+		},
+	}))
+	require.NoError(t, store.AppendIncident(Incident{ShipID: "2", Time: 60, From: Red, To: Green}))
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSnapshot(&buf, SnapshotHeader{Seq: 7}, store))
+
+	restored := NewMemoryStore()
+	header, err := ReadSnapshot(&buf, restored)
+	require.NoError(t, err)
+
+	assert.Equal(t, SnapshotHeader{Version: SnapshotVersion, Seq: 7}, header)
+	assert.Equal(t, store, restored)
+}
+
+func TestSnapshotUnsupportedVersion(t *testing.T) {
+	_, err := ReadSnapshot(strings.NewReader(`{"version":999}`+"\n"), NewMemoryStore())
+	assert.ErrorIs(t, err, ErrSnapshotVersion)
+}
+
+func TestSnapshotUnsortedHistory(t *testing.T) {
+	for _, positions := range []string{
+		`[{"t":101,"x":1,"y":2,"vx":0,"vy":0},{"t":100,"x":1,"y":2,"vx":0,"vy":0}]`,
+		`[{"t":100,"x":1,"y":2,"vx":0,"vy":0},{"t":100,"x":2,"y":2,"vx":0,"vy":0}]`,
+	} {
+		_, err := ReadSnapshot(strings.NewReader(`{"version":2}`+"\n"+
+			`{"kind":"ship","ship":{"id":"1","status":0,"positions":`+positions+`}}`+"\n"), NewMemoryStore())
+		assert.ErrorIs(t, err, ErrInvalidSnapshot, positions)
+	}
+}
+
+func TestSnapshotOlderVersion(t *testing.T) {
+	store := NewMemoryStore()
+	header, err := ReadSnapshot(strings.NewReader(`{"version":1}`+"\n"+
+		`{"kind":"ship","ship":{"id":"1","status":0,"positions":[{"t":100,"x":1,"y":2,"vx":0,"vy":0}]}}`+"\n"), store)
+	require.NoError(t, err)
+	assert.Equal(t, 1, header.Version)
+	assert.Equal(t, 1, store.Len())
+
+	// record kinds added later are not expected in older snapshots
+	_, err = ReadSnapshot(strings.NewReader(`{"version":1}`+"\n"+
+		`{"kind":"hazard","hazard":{"id":"tower","x":0,"y":0,"radius":0,"red_radius":1,"yellow_radius":2}}`+"\n"), NewMemoryStore())
+	assert.ErrorIs(t, err, ErrInvalidSnapshot)
+}
+
+func TestSnapshotUnknownRecord(t *testing.T) {
+	_, err := ReadSnapshot(strings.NewReader(`{"version":1}`+"\n"+`{"kind":"unknown"}`+"\n"), NewMemoryStore())
+	assert.ErrorIs(t, err, ErrInvalidSnapshot)
+}
+
+func TestTrafficExportImport(t *testing.T) {
+	source := mustNewTraffic(t, DefaultConfig())
+	_, err := source.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 10, Y: 10}})
+	require.NoError(t, err)
+	_, err = source.PositionShip(PositionShip{ID: "1", Time: 101, Point: Vector{X: 11, Y: 10}})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, source.Export(&buf))
+
+	target := mustNewTraffic(t, DefaultConfig())
+	_, err = target.PositionShip(PositionShip{ID: "2", Time: 100, Point: Vector{X: 10, Y: 10}})
+	require.NoError(t, err)
+	require.NoError(t, target.AddHazard(Hazard{ID: "buoy", Position: Vector{X: 50, Y: 50}, RedRadius: 1, YellowRadius: 2}))
+	require.NoError(t, target.AddGeofence(Geofence{ID: "zone", Polygon: []Vector{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}, Status: Red}))
+	require.NoError(t, target.Import(&buf))
+
+	page, err := target.GetShips(ShipsQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Ships, 1)
+	assert.Equal(t, "1", page.Ships[0].ID)
+	assert.Equal(t, source.Hazards(), target.Hazards())
+	assert.Empty(t, target.Geofences())
+
+	// imported ships participate in collision detection
+	res, err := target.PositionShip(PositionShip{ID: "3", Time: 101, Point: Vector{X: 11, Y: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, Red, res.Status)
+}
+
+func TestTrafficImportVersion1(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+	require.NoError(t, tr.AddHazard(Hazard{ID: "buoy", Position: Vector{X: 50, Y: 50}, RedRadius: 1, YellowRadius: 2}))
+	require.NoError(t, tr.AddGeofence(Geofence{ID: "zone", Polygon: []Vector{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}, Status: Red}))
+	hazards, geofences := tr.Hazards(), tr.Geofences()
+
+	require.NoError(t, tr.Import(strings.NewReader(`{"version":1}`+"\n"+
+		`{"kind":"ship","ship":{"id":"1","status":0,"positions":[{"t":100,"x":100,"y":100,"vx":0,"vy":0}]}}`+"\n")))
+
+	page, err := tr.GetShips(ShipsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, shipIDs(page.Ships))
+	// version 1 has no hazards and geofences, current ones are kept
+	assert.Equal(t, hazards, tr.Hazards())
+	assert.Equal(t, geofences, tr.Geofences())
+
+	res, err := tr.PositionShip(PositionShip{ID: "2", Time: 100, Point: Vector{X: 0, Y: 0}})
+	require.NoError(t, err)
+	assert.Equal(t, Red, res.Status)
+}
+
+func TestTrafficImportInvalid(t *testing.T) {
+	traffic := mustNewTraffic(t, DefaultConfig())
+	_, err := traffic.PositionShip(PositionShip{ID: "1", Time: 100, Point: Vector{X: 10, Y: 10}})
+	require.NoError(t, err)
+
+	err = traffic.Import(strings.NewReader(`{"version":1}` + "\n" + `{"kind":"ship","ship":`))
+	require.ErrorIs(t, err, ErrInvalidSnapshot)
+
+	// nothing changed
+	page, err := traffic.GetShips(ShipsQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Ships, 1)
+}
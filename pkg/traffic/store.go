@@ -0,0 +1,305 @@
+package traffic
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Store keeps history and last status of the ships.
+// Implementations don't have to be safe for concurrent use, Traffic serializes access.
+type Store interface {
+	// History returns positions of the ship sorted by time, false if ship is unknown.
+	// Returned slice must not be modified.
+	History(id string) ([]ShipPosition, bool)
+	Status(id string) Status
+	// Range calls fn for every ship until fn returns false
+	Range(fn func(id string, history []ShipPosition, status Status) bool)
+	Len() int
+
+	// Append adds position to the end of the ship history and sets its status
+	Append(id string, position ShipPosition, status Status) error
+	// Put replaces whole history and status of the ship
+	Put(id string, history []ShipPosition, status Status) error
+	// Splice replaces positions history[from:to] of the ship with positions and sets its status
+	Splice(id string, from, to int, positions []ShipPosition, status Status) error
+	// SetStatus changes last status of the ship without touching its history
+	SetStatus(id string, status Status) error
+	// Delete removes the ship with its history, speed anomalies and incidents
+	Delete(id string) error
+	// Anomalies returns speed anomalies of the ship, oldest first.
+	// Returned slice must not be modified.
+	Anomalies(id string) []SpeedAnomaly
+	// RangeAnomalies calls fn for every speed anomaly until fn returns false
+	RangeAnomalies(fn func(id string, a SpeedAnomaly) bool)
+	// AppendAnomaly adds speed anomaly to the end of the ship anomalies
+	AppendAnomaly(id string, a SpeedAnomaly) error
+	// AppendAudit adds record to the end of the audit log
+	AppendAudit(record AuditRecord) error
+	// RangeAudit calls fn for every audit record, oldest first, until fn returns false
+	RangeAudit(fn func(record AuditRecord) bool)
+	// AppendIncident adds status transition to the end of the incident log
+	AppendIncident(i Incident) error
+	// RangeIncidents calls fn for every incident, oldest first, until fn returns false
+	RangeIncidents(fn func(i Incident) bool)
+	// TrimIncidents removes incidents with time before the given one
+	TrimIncidents(before int) error
+	// Hazard returns hazard by id, false if it is unknown
+	Hazard(id string) (Hazard, bool)
+	// RangeHazards calls fn for every hazard until fn returns false
+	RangeHazards(fn func(h Hazard) bool)
+	// PutHazard adds or replaces hazard
+	PutHazard(h Hazard) error
+	DeleteHazard(id string) error
+	// Geofence returns geofence by id, false if it is unknown
+	Geofence(id string) (Geofence, bool)
+	// RangeGeofences calls fn for every geofence until fn returns false
+	RangeGeofences(fn func(g Geofence) bool)
+	// PutGeofence adds or replaces geofence
+	PutGeofence(g Geofence) error
+	DeleteGeofence(id string) error
+	// Profile returns vessel profile of the ship, false if it has none
+	Profile(id string) (VesselProfile, bool)
+	// RangeProfiles calls fn for every vessel profile until fn returns false
+	RangeProfiles(fn func(id string, p VesselProfile) bool)
+	// PutProfile adds or replaces vessel profile of the ship
+	PutProfile(id string, p VesselProfile) error
+	DeleteProfile(id string) error
+	// Seeded tells whether defaults, e.g. the tower hazard, were added to the store
+	Seeded() bool
+	// MarkSeeded remembers that defaults were added, so they are not added again on restart
+	MarkSeeded() error
+	// Flush removes all ships, speed anomalies, vessel profiles, audit and incident logs,
+	// hazards, geofences and seeded mark are kept
+	Flush() error
+	Close() error
+}
+
+// MemoryStore keeps everything in maps, state is lost on restart
+type MemoryStore struct {
+	history    map[string][]ShipPosition
+	lastStatus map[string]Status
+	anomalies  map[string][]SpeedAnomaly
+	audit      []AuditRecord
+	incidents  []Incident
+	hazards    map[string]Hazard
+	geofences  map[string]Geofence
+	profiles   map[string]VesselProfile
+	seeded     bool
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		history:    make(map[string][]ShipPosition),
+		lastStatus: make(map[string]Status),
+		anomalies:  make(map[string][]SpeedAnomaly),
+		hazards:    make(map[string]Hazard),
+		geofences:  make(map[string]Geofence),
+		profiles:   make(map[string]VesselProfile),
+	}
+}
+
+func (s *MemoryStore) History(id string) ([]ShipPosition, bool) {
+	history, ok := s.history[id]
+	return history, ok
+}
+
+func (s *MemoryStore) Status(id string) Status {
+	return s.lastStatus[id]
+}
+
+func (s *MemoryStore) Range(fn func(id string, history []ShipPosition, status Status) bool) {
+	for id, history := range s.history {
+		if !fn(id, history, s.lastStatus[id]) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) Len() int {
+	return len(s.history)
+}
+
+func (s *MemoryStore) Append(id string, position ShipPosition, status Status) error {
+	s.history[id] = append(s.history[id], position)
+	s.lastStatus[id] = status
+	return nil
+}
+
+func (s *MemoryStore) Put(id string, history []ShipPosition, status Status) error {
+	s.history[id] = history
+	s.lastStatus[id] = status
+	return nil
+}
+
+func (s *MemoryStore) Splice(id string, from, to int, positions []ShipPosition, status Status) error {
+	history := s.history[id]
+	if from < 0 || from > to || to > len(history) {
+		return fmt.Errorf("invalid range [%d:%d] of %d positions", from, to, len(history))
+	}
+
+	// new slice, histories returned before are still read without lock
+	s.history[id] = slices.Concat(history[:from], positions, history[to:])
+	s.lastStatus[id] = status
+	return nil
+}
+
+func (s *MemoryStore) SetStatus(id string, status Status) error {
+	s.lastStatus[id] = status
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	delete(s.history, id)
+	delete(s.lastStatus, id)
+	delete(s.anomalies, id)
+	s.incidents = slices.DeleteFunc(s.incidents, func(i Incident) bool {
+		return i.ShipID == id
+	})
+	return nil
+}
+
+func (s *MemoryStore) Anomalies(id string) []SpeedAnomaly {
+	return s.anomalies[id]
+}
+
+func (s *MemoryStore) RangeAnomalies(fn func(id string, a SpeedAnomaly) bool) {
+	for id, anomalies := range s.anomalies {
+		for _, a := range anomalies {
+			if !fn(id, a) {
+				return
+			}
+		}
+	}
+}
+
+func (s *MemoryStore) AppendAnomaly(id string, a SpeedAnomaly) error {
+	s.anomalies[id] = append(s.anomalies[id], a)
+	return nil
+}
+
+func (s *MemoryStore) AppendAudit(record AuditRecord) error {
+	s.audit = append(s.audit, record)
+	return nil
+}
+
+func (s *MemoryStore) RangeAudit(fn func(record AuditRecord) bool) {
+	for _, record := range s.audit {
+		if !fn(record) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) AppendIncident(i Incident) error {
+	s.incidents = append(s.incidents, i)
+	return nil
+}
+
+func (s *MemoryStore) RangeIncidents(fn func(i Incident) bool) {
+	for _, i := range s.incidents {
+		if !fn(i) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) TrimIncidents(before int) error {
+	s.incidents = slices.DeleteFunc(s.incidents, func(i Incident) bool {
+		return i.Time < before
+	})
+	return nil
+}
+
+func (s *MemoryStore) Hazard(id string) (Hazard, bool) {
+	h, ok := s.hazards[id]
+	return h, ok
+}
+
+func (s *MemoryStore) RangeHazards(fn func(h Hazard) bool) {
+	for _, h := range s.hazards {
+		if !fn(h) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) PutHazard(h Hazard) error {
+	s.hazards[h.ID] = h
+	return nil
+}
+
+func (s *MemoryStore) DeleteHazard(id string) error {
+	delete(s.hazards, id)
+	return nil
+}
+
+func (s *MemoryStore) Geofence(id string) (Geofence, bool) {
+	g, ok := s.geofences[id]
+	return g, ok
+}
+
+func (s *MemoryStore) RangeGeofences(fn func(g Geofence) bool) {
+	for _, g := range s.geofences {
+		if !fn(g) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) PutGeofence(g Geofence) error {
+	s.geofences[g.ID] = g
+	return nil
+}
+
+func (s *MemoryStore) DeleteGeofence(id string) error {
+	delete(s.geofences, id)
+	return nil
+}
+
+func (s *MemoryStore) Profile(id string) (VesselProfile, bool) {
+	p, ok := s.profiles[id]
+	return p, ok
+}
+
+func (s *MemoryStore) RangeProfiles(fn func(id string, p VesselProfile) bool) {
+	for id, p := range s.profiles {
+		if !fn(id, p) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) PutProfile(id string, p VesselProfile) error {
+	s.profiles[id] = p
+	return nil
+}
+
+func (s *MemoryStore) DeleteProfile(id string) error {
+	delete(s.profiles, id)
+	return nil
+}
+
+func (s *MemoryStore) Seeded() bool {
+	return s.seeded
+}
+
+func (s *MemoryStore) MarkSeeded() error {
+	s.seeded = true
+	return nil
+}
+
+func (s *MemoryStore) Flush() error {
+	s.history = make(map[string][]ShipPosition)
+	s.lastStatus = make(map[string]Status)
+	s.anomalies = make(map[string][]SpeedAnomaly)
+	s.profiles = make(map[string]VesselProfile)
+	s.audit = nil
+	s.incidents = nil
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,127 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+)
+
+var ErrInvalidHistoryQuery = errors.New("invalid history query")
+
+type (
+	// HistoryQuery selects part of the ship track, zero value is the whole track
+	HistoryQuery struct {
+		// From and To limit position times, both included
+		From, To *int
+		// Limit is the max number of positions, all when 0
+		Limit int
+		// Downsample keeps the first position of every interval of that many seconds, all positions when 0
+		Downsample int
+	}
+
+	// Track is part of the ship history selected by HistoryQuery
+	Track struct {
+		// Positions iterates selected positions oldest first, it is safe to use without lock
+		Positions iter.Seq[ShipPosition]
+		// Anomalies are speed anomalies within [From, To]
+		Anomalies []SpeedAnomaly
+		// Next is From of the query for the rest of the track when it was cut by Limit
+		Next *int
+	}
+)
+
+// ShipHistory selects part of the ship track. Range is found with binary search and positions
+// are not copied, histories are replaced rather than modified by the store.
+func (t *Traffic) ShipHistory(id string, q HistoryQuery) (Track, error) {
+	if err := q.validate(); err != nil {
+		return Track{}, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	history, ok := t.store.History(id)
+	if !ok {
+		return Track{}, ErrNotFound
+	}
+
+	from, to := 0, len(history)
+	if q.From != nil {
+		from = sort.Search(len(history), func(i int) bool {
+			return history[i].Time >= *q.From
+		})
+	}
+	if q.To != nil {
+		to = sort.Search(len(history), func(i int) bool {
+			return history[i].Time > *q.To
+		})
+	}
+	to = max(from, to)
+
+	var track Track
+	if end := q.cut(history, from, to); end < to {
+		next := history[end].Time
+		track.Next = &next
+		to = end
+	}
+	positions := history[from:to]
+	track.Positions = func(yield func(ShipPosition) bool) {
+		for i, pos := range positions {
+			if q.skip(positions, i) {
+				continue
+			}
+			if !yield(pos) {
+				return
+			}
+		}
+	}
+
+	for _, a := range t.store.Anomalies(id) {
+		if (q.From == nil || a.Time >= *q.From) && (q.To == nil || a.Time <= *q.To) {
+			track.Anomalies = append(track.Anomalies, a)
+		}
+	}
+
+	return track, nil
+}
+
+func (q HistoryQuery) validate() error {
+	if q.Limit < 0 || q.Downsample < 0 {
+		return fmt.Errorf("%w: limit and downsample must not be negative", ErrInvalidHistoryQuery)
+	}
+	if q.From != nil && q.To != nil && *q.From > *q.To {
+		return fmt.Errorf("%w: from must not be after to", ErrInvalidHistoryQuery)
+	}
+
+	return nil
+}
+
+// skip tells whether downsampling drops positions[i], first position of every interval is kept
+// same as by compaction
+func (q HistoryQuery) skip(positions []ShipPosition, i int) bool {
+	return q.Downsample > 0 && i > 0 && positions[i-1].Time/q.Downsample == positions[i].Time/q.Downsample
+}
+
+// cut returns end of history[from:to] which has no more than Limit positions after downsampling
+func (q HistoryQuery) cut(history []ShipPosition, from, to int) int {
+	if q.Limit == 0 || to-from <= q.Limit {
+		return to
+	}
+	if q.Downsample == 0 {
+		return from + q.Limit
+	}
+
+	kept := 0
+	for i := from; i < to; i++ {
+		if q.skip(history[from:to], i-from) {
+			continue
+		}
+		if kept == q.Limit {
+			return i
+		}
+		kept++
+	}
+
+	return to
+}
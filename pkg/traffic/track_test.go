@@ -0,0 +1,112 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func trackTimes(track Track) []int {
+	var times []int
+	for pos := range track.Positions {
+		times = append(times, pos.Time)
+	}
+	return times
+}
+
+func TestShipHistory(t *testing.T) {
+	var history []ShipPosition
+	for _, time := range []int{100, 101, 105, 110, 111, 119, 120, 125, 130} {
+		history = append(history, ShipPosition{Time: time, Position: Vector{X: float64(time)}})
+	}
+	tr := newTrafficWithHistory(t, DefaultConfig(), map[string][]ShipPosition{"1": history})
+
+	track, err := tr.ShipHistory("1", HistoryQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{100, 101, 105, 110, 111, 119, 120, 125, 130}, trackTimes(track))
+	assert.Nil(t, track.Next)
+
+	from, to := 102, 120
+	track, err = tr.ShipHistory("1", HistoryQuery{From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Equal(t, []int{105, 110, 111, 119, 120}, trackTimes(track))
+
+	track, err = tr.ShipHistory("1", HistoryQuery{From: &from, To: &to, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{105, 110}, trackTimes(track))
+	require.NotNil(t, track.Next)
+	assert.Equal(t, 111, *track.Next)
+
+	// first position of every 10 seconds
+	track, err = tr.ShipHistory("1", HistoryQuery{Downsample: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []int{100, 110, 120, 130}, trackTimes(track))
+
+	// pages of downsampled track add up to the whole of it
+	var times []int
+	q := HistoryQuery{Downsample: 10, Limit: 3}
+	for {
+		track, err := tr.ShipHistory("1", q)
+		require.NoError(t, err)
+		times = append(times, trackTimes(track)...)
+		if track.Next == nil {
+			break
+		}
+		q.From = track.Next
+	}
+	assert.Equal(t, []int{100, 110, 120, 130}, times)
+
+	from, to = 200, 300
+	track, err = tr.ShipHistory("1", HistoryQuery{From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Empty(t, trackTimes(track))
+
+	// iteration stops early
+	track, err = tr.ShipHistory("1", HistoryQuery{})
+	require.NoError(t, err)
+	for pos := range track.Positions {
+		assert.Equal(t, 100, pos.Time)
+		break
+	}
+
+	_, err = tr.ShipHistory("2", HistoryQuery{})
+	assert.ErrorIs(t, err, ErrNotFound)
+	for _, q := range []HistoryQuery{
+		{Limit: -1},
+		{Downsample: -1},
+		{From: &to, To: &from},
+	} {
+		_, err := tr.ShipHistory("1", q)
+		assert.ErrorIs(t, err, ErrInvalidHistoryQuery)
+	}
+}
+
+func TestShipHistoryAnomalies(t *testing.T) {
+	tr := mustNewTraffic(t, DefaultConfig())
+
+	for _, ps := range []PositionShip{
+		{ID: "1", Time: 100, Point: Vector{X: 0, Y: 0}},
+		{ID: "1", Time: 101, Point: Vector{X: 1000, Y: 0}},
+		{ID: "1", Time: 110, Point: Vector{X: 1010, Y: 0}},
+		{ID: "1", Time: 111, Point: Vector{X: 3000, Y: 0}},
+	} {
+		_, err := tr.PositionShip(ps)
+		require.NoError(t, err)
+	}
+
+	track, err := tr.ShipHistory("1", HistoryQuery{})
+	require.NoError(t, err)
+	require.Len(t, track.Anomalies, 2)
+
+	from := 105
+	track, err = tr.ShipHistory("1", HistoryQuery{From: &from})
+	require.NoError(t, err)
+	require.Len(t, track.Anomalies, 1)
+	assert.Equal(t, 111, track.Anomalies[0].Time)
+
+	// track is a view of the history at the time of the query
+	_, err = tr.PositionShip(PositionShip{ID: "1", Time: 112, Point: Vector{X: 3001, Y: 0}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{110, 111}, trackTimes(track))
+}
@@ -3,8 +3,8 @@ package traffic
 import (
 	"errors"
 	"math"
+	"slices"
 	"sort"
-	"strconv"
 	"sync"
 	"time"
 )
@@ -17,15 +17,9 @@ const (
 	Red
 )
 
-const (
-	maxSpeedPerSecond = 100.0 // Maximum speed of a ship in units per second
-
-	YellowThreshold = 2 // Distance threshold for yellow status
-	RedThreshold    = 1 // Distance threshold for red status
+const KindShip ConflictKind = "ship"
 
-	predictionTimeSeconds = 60.0
-	epsilon               = 1e-9 // For floating point comparisons
-)
+const epsilon = 1e-9 // For floating point comparisons
 
 type (
 	ShipPosition struct {
@@ -48,14 +42,40 @@ type (
 	}
 
 	PositionResult struct {
-		Speed  float64
-		Status Status
+		Speed     float64
+		Status    Status
+		Conflicts []Conflict
+		// Late position was inserted into history, Status is the current status of the ship
+		// and Conflicts are set only if it was re-evaluated
+		Late          bool
+		StatusChanged bool
+		// Anomaly position implied speed above the limit of the vessel class, Speed is clamped
+		Anomaly bool
+	}
+
+	ConflictKind string
+
+	// Conflict describes closest point of approach(CPA) to the source of yellow or red status
+	Conflict struct {
+		Kind          ConflictKind
+		ID            string        // ship, hazard or geofence id
+		Alert         GeofenceAlert // set for geofence conflicts
+		Status        Status
+		Distance      float64 // distance at CPA
+		Time          float64 // time of CPA
+		Position      Vector  // position of the ship at CPA
+		OtherPosition Vector  // position of the conflict source at CPA
 	}
 
 	Traffic struct {
-		mu         sync.RWMutex
-		History    map[string][]ShipPosition
-		LastStatus map[string]Status
+		cfg     Config
+		mu      sync.RWMutex
+		store   Store
+		index   *spatialIndex
+		events  *EventBus
+		commits *commitLog
+		// largest vessel safety domain, ship conflicts are searched that much further
+		maxDomain float64
 	}
 )
 
@@ -63,54 +83,82 @@ var (
 	ErrNotFound     = errors.New("ship not found")
 	ErrTimeInPast   = errors.New("time must be greater than last position time")
 	ErrTimeInFuture = errors.New("time must be in the past")
+	// ErrDuplicateTime is returned in late positions mode instead of ErrTimeInPast
+	// when ship already has position at this time
+	ErrDuplicateTime = errors.New("position with the same time already exists")
 )
 
-func NewTraffic() *Traffic {
+// NewTraffic creates traffic on top of the store, store may already contain ships.
+// Store is seeded once: new store, or one written before hazards existed, gets the tower at the origin,
+// so the tower deleted by operator doesn't come back on restart.
+func NewTraffic(cfg Config, store Store) (*Traffic, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	t := &Traffic{
-		History:    make(map[string][]ShipPosition),
-		LastStatus: make(map[string]Status),
+		cfg:     cfg,
+		store:   store,
+		events:  NewEventBus(),
+		commits: newCommitLog(),
 	}
+	t.rebuildIndex()
+	t.updateMaxDomain()
 
-	return t
+	if !store.Seeded() {
+		if !hasHazards(store) {
+			if err := store.PutHazard(tower(cfg)); err != nil {
+				return nil, err
+			}
+		}
+		if err := store.MarkSeeded(); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
 }
 
-func (t *Traffic) Flush() {
+func (t *Traffic) Flush() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	t.History = make(map[string][]ShipPosition)
-	t.LastStatus = make(map[string]Status)
+
+	t.index = newSpatialIndex(t.cfg)
+	t.commits.reset()
+	t.maxDomain = 0
+	return t.store.Flush()
 }
 
-func (t *Traffic) GetShips() ([]Ship, error) {
-	res := make([]Ship, 0, len(t.History))
+// Close ends all event subscriptions and closes underlying store
+func (t *Traffic) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	for id, positions := range t.History {
-		ship := Ship{
-			ID:         id,
-			LastStatus: t.LastStatus[id],
-		}
+	t.events.Close()
+	return t.store.Close()
+}
 
-		if len(positions) > 0 {
-			lastPosition := positions[len(positions)-1]
+// Subscribe to accepted positions and status changes, subscription must be closed by caller
+func (t *Traffic) Subscribe(filter EventFilter) *Subscription {
+	return t.events.Subscribe(filter)
+}
 
-			ship.LastSeen = strconv.Itoa(lastPosition.Time)
-			ship.LastSpeed = lastPosition.Speed.Magnitude()
-			ship.LastPosition = lastPosition.Position
+// rebuildIndex indexes last known positions of all ships in the store
+func (t *Traffic) rebuildIndex() {
+	t.index = newSpatialIndex(t.cfg)
+	t.store.Range(func(id string, history []ShipPosition, _ Status) bool {
+		if len(history) > 0 {
+			t.index.update(id, history[len(history)-1])
 		}
-
-		res = append(res, ship)
-	}
-
-	return res, nil
+		return true
+	})
 }
 
 func (t *Traffic) GetShipPositions(id string) ([]ShipPosition, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	ship, ok := t.History[id]
+	ship, ok := t.store.History(id)
 	if !ok {
 		return nil, ErrNotFound
 	}
@@ -118,65 +166,171 @@ func (t *Traffic) GetShipPositions(id string) ([]ShipPosition, error) {
 	return ship, nil
 }
 
+// PositionShip evaluates status optimistically under read lock, so positions of different ships
+// are evaluated in parallel, and records it under write lock.
+// Before recording evaluation is validated against ships changed in the meantime,
+// result is the same as if whole call was done under write lock.
 func (t *Traffic) PositionShip(ps PositionShip) (PositionResult, error) {
 	if ps.Time > int(time.Now().Unix()) {
 		return PositionResult{}, ErrTimeInFuture
 	}
 
-	var (
-		speed        Vector
-		lastPosition ShipPosition
-	)
+	t.mu.RLock()
+	eval, err := t.evaluate(ps)
+	t.mu.RUnlock()
+	late := err == ErrTimeInPast && t.cfg.LatePositions
+	if err != nil && !late {
+		// rejected against state which existed during the call, nothing to validate
+		return PositionResult{}, err
+	}
 
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if len(t.History[ps.ID]) > 0 {
-		lastPosition = t.History[ps.ID][len(t.History[ps.ID])-1]
+	changed, ok := t.commits.since(eval.version)
+	if late || !ok || slices.Contains(changed, ps.ID) {
+		// ship itself changed, speed and time check are stale
+		return t.positionShip(ps)
+	}
+	eval.revalidate(t, ps, changed)
+
+	return t.record(ps, eval)
+}
+
+// Simulate evaluates position the same way as PositionShip without recording it or publishing events.
+// Only read lock is held, so simulations run in parallel with ingest. Time in the future is allowed,
+// time not after the last position of the ship is rejected.
+func (t *Traffic) Simulate(ps PositionShip) (PositionResult, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	eval, err := t.evaluate(ps)
+	if err != nil {
+		return PositionResult{}, err
+	}
+	status, conflicts := t.combineConflicts(ps, eval.speed, eval.conflicts)
+
+	return PositionResult{
+		Speed:         eval.speed.Magnitude(),
+		Status:        status,
+		Conflicts:     conflicts,
+		StatusChanged: status != eval.previousStatus,
+		Anomaly:       eval.anomaly != nil,
+	}, nil
+}
+
+// positionShip evaluates and records position, must be called under write lock
+func (t *Traffic) positionShip(ps PositionShip) (PositionResult, error) {
+	eval, err := t.evaluate(ps)
+	if err == ErrTimeInPast && t.cfg.LatePositions {
+		return t.insertLate(ps)
+	}
+	if err != nil {
+		return PositionResult{}, err
+	}
+
+	return t.record(ps, eval)
+}
+
+// evaluate calculates speed and conflicts with other ships, must be called under read or write lock
+func (t *Traffic) evaluate(ps PositionShip) (evaluation, error) {
+	eval := evaluation{
+		version:        t.commits.version,
+		previousStatus: t.store.Status(ps.ID),
+	}
+
+	var lastPosition ShipPosition
+	if history, _ := t.store.History(ps.ID); len(history) > 0 {
+		lastPosition = history[len(history)-1]
 	}
 
 	if lastPosition.Time != 0 {
 		if ps.Time <= lastPosition.Time {
-			return PositionResult{}, ErrTimeInPast
+			return eval, ErrTimeInPast
 		}
 
 		deltaTime := float64(ps.Time - lastPosition.Time)
-		speed = calculateShipSpeed(deltaTime, ps.Point, lastPosition.Position)
+		eval.speed, eval.anomaly = t.limitSpeed(ps.ID, ps.Time, shipSpeed(deltaTime, ps.Point, lastPosition.Position))
 	}
 
-	status := t.evaluateTrafficStatus(ps, speed)
+	eval.conflicts = t.evaluateShips(ps, eval.speed)
+
+	return eval, nil
+}
+
+// record stores position evaluated against current state, must be called under write lock
+func (t *Traffic) record(ps PositionShip, eval evaluation) (PositionResult, error) {
+	status, conflicts := t.combineConflicts(ps, eval.speed, eval.conflicts)
 
-	t.LastStatus[ps.ID] = status
-	t.History[ps.ID] = append(t.History[ps.ID], ShipPosition{
+	newPosition := ShipPosition{
 		Time:     ps.Time,
-		Speed:    speed,
+		Speed:    eval.speed,
 		Position: ps.Point,
-	})
+	}
+	if err := t.store.Append(ps.ID, newPosition, status); err != nil {
+		return PositionResult{}, err
+	}
+	if eval.anomaly != nil {
+		if err := t.recordAnomaly(ps.ID, *eval.anomaly); err != nil {
+			return PositionResult{}, err
+		}
+	}
+	t.index.advance(ps.Time)
+	t.index.update(ps.ID, newPosition)
+	t.commits.record(ps.ID)
+
+	// published under the lock, so subscribers see events in the order positions were accepted
+	event := Event{
+		Kind:           EventPosition,
+		ID:             ps.ID,
+		Time:           ps.Time,
+		Position:       ps.Point,
+		Speed:          eval.speed.Magnitude(),
+		Status:         status,
+		PreviousStatus: eval.previousStatus,
+		Conflicts:      conflicts,
+	}
+	t.events.Publish(event)
+	if status != eval.previousStatus {
+		if err := t.changeStatus(event); err != nil {
+			return PositionResult{}, err
+		}
+	}
 
 	return PositionResult{
-		Speed:  speed.Magnitude(),
-		Status: status,
+		Speed:     eval.speed.Magnitude(),
+		Status:    status,
+		Conflicts: conflicts,
+		Anomaly:   eval.anomaly != nil,
 	}, nil
 }
 
-// calculateShipSpeed between two positions in deltatime and truncate to maxSpeedPerSecond
-func calculateShipSpeed(deltaTime float64, newPosition, lastPosition Vector) Vector {
+// calculateShipSpeed between two positions in deltatime and truncate to maxSpeed
+func calculateShipSpeed(deltaTime float64, newPosition, lastPosition Vector, maxSpeed float64) Vector {
+	return clampSpeed(shipSpeed(deltaTime, newPosition, lastPosition), maxSpeed)
+}
+
+// shipSpeed between two positions in deltatime as reported, without any limit
+func shipSpeed(deltaTime float64, newPosition, lastPosition Vector) Vector {
 	deltaX := newPosition.X - lastPosition.X
 	deltaY := newPosition.Y - lastPosition.Y
-	speed := Vector{
+	return Vector{
 		X: float64(deltaX) / deltaTime,
 		Y: float64(deltaY) / deltaTime,
 	}
+}
 
-	// truncate speed to maxSpeedPerSecond
-	if speed.Magnitude() > maxSpeedPerSecond {
-		speed = speed.Normalize().ScalarMultiply(maxSpeedPerSecond)
+// clampSpeed truncates speed to maxSpeed keeping its direction
+func clampSpeed(speed Vector, maxSpeed float64) Vector {
+	if speed.Magnitude() > maxSpeed {
+		speed = speed.Normalize().ScalarMultiply(maxSpeed)
 	}
 
 	return speed
 }
 
-// evaluateTrafficStatus goes over all ships
+// evaluateTrafficStatus goes over all ships which could come close during prediction window
+// spatial index filters out ships which are too far away to matter
 // find ship states before ps.Time + 60
 // calculate ship position at ps.Time
 // calculate distance between the two ships
@@ -185,94 +339,142 @@ func calculateShipSpeed(deltaTime float64, newPosition, lastPosition Vector) Vec
 // move first ship to ps.Time to make things easier
 // keep ships in time sync
 // calculate distance between the two ships
-// if status red then break, not going to get any better
-// if status yellow then set status yellow
+// every ship with yellow or red status is reported as a conflict with its closest point of approach
+// status priority red > yellow > green
 //
 // edge cases:
-// 0,0 - tower
+// static hazards - towers, buoys, piers, wrecks
 // ships can jump surpassing max speed - try to use future position to calculate speed,
 // speed may not be correct, but at least trajectory is correct
-func (t *Traffic) evaluateTrafficStatus(ps PositionShip, speed Vector) Status {
-	status := Green
+func (t *Traffic) evaluateTrafficStatus(ps PositionShip, speed Vector) (Status, []Conflict) {
+	return t.combineConflicts(ps, speed, t.evaluateShips(ps, speed))
+}
+
+// evaluateShips returns yellow and red conflicts with other ships
+func (t *Traffic) evaluateShips(ps PositionShip, speed Vector) []Conflict {
+	var conflicts []Conflict
 
-loop:
-	for shipID, history := range t.History {
-		if shipID == ps.ID {
-			continue // don't collide with itself
+	t.index.candidates(ps, speed, t.domain(ps.ID)+t.maxDomain, func(shipID string) {
+		if conflict, ok := t.evaluateShip(ps, speed, shipID); ok {
+			conflicts = append(conflicts, conflict)
 		}
+	})
 
-		// other ships already aligned into the [ps.Time: ps.Time + 60 window]
-		// with adujusted speed(code is prettier now :) )
-		// move both ships to ts and calculate distance
-		currentPosition := ps.Point
-		currentTime := ps.Time
-		maxPredictionTime := ps.Time + int(predictionTimeSeconds)
-		collisionCandidates := rewindShipBinarySearch(history, ps)
-		for i, otherShip := range collisionCandidates {
-			if otherShip.Time == 0 {
-				continue // no history for this time
-			}
+	return conflicts
+}
 
-			// because there are many updates possible within 60 seconds
-			// dist calculation must be done for smaller time windows not just +60
-			nextPredictionTime := maxPredictionTime
-			if i < len(collisionCandidates)-1 {
-				nextPredictionTime = min(collisionCandidates[i+1].Time, maxPredictionTime)
-			}
+// evaluateShip returns conflict with another ship if it is yellow or red
+func (t *Traffic) evaluateShip(ps PositionShip, speed Vector, shipID string) (Conflict, bool) {
+	history, _ := t.store.History(shipID)
+	conflict, ok := t.evaluateShipStatus(ps, speed, shipID, history)
+	if !ok || conflict.Status == Green {
+		return Conflict{}, false
+	}
 
-			// ships must be at the time for calculate min distance to work
-			currentPosition = currentPosition.Add(speed.ScalarMultiply(float64(otherShip.Time - currentTime)))
-			currentTime = otherShip.Time
-
-			minDist := calculateMinDistance(ShipPosition{
-				Position: otherShip.Position,
-				Speed:    otherShip.Speed,
-			}, ShipPosition{
-				Position: currentPosition,
-				Speed:    speed,
-			}, float64(nextPredictionTime-currentTime))
-
-			newStatus := statusForDist(minDist)
-			if newStatus == Red {
-				status = newStatus
-				break loop
-			}
+	conflict.ID = shipID
+	return conflict, true
+}
 
-			if status != Yellow {
-				status = newStatus
-			}
-		}
-	}
+// combineConflicts adds hazards and geofences to the ship conflicts and calculates resulting status.
+// Hazards decide the status only when ships are green, same as the tower always did.
+func (t *Traffic) combineConflicts(ps PositionShip, speed Vector, shipConflicts []Conflict) (Status, []Conflict) {
+	hazardConflicts := t.evaluateHazards(ps, speed)
+	geofenceConflicts := t.evaluateGeofences(ps, speed)
+	conflicts := slices.Concat(shipConflicts, hazardConflicts, geofenceConflicts)
 
-	towerStatus := checkTowerCollision(ps, speed)
+	status := maxStatus(shipConflicts)
 	if status == Green {
-		status = towerStatus
-	} else if towerStatus == Yellow && status != Red {
-		status = Yellow
+		status = maxStatus(hazardConflicts)
 	}
+	status = max(status, maxStatus(geofenceConflicts))
 
+	// closest first, map order is random
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Distance != conflicts[j].Distance {
+			return conflicts[i].Distance < conflicts[j].Distance
+		}
+		if conflicts[i].ID != conflicts[j].ID {
+			return conflicts[i].ID < conflicts[j].ID
+		}
+		return conflicts[i].Time < conflicts[j].Time
+	})
+
+	return status, conflicts
+}
+
+func maxStatus(conflicts []Conflict) Status {
+	status := Green
+	for _, conflict := range conflicts {
+		status = max(status, conflict.Status)
+	}
 	return status
 }
 
-func checkTowerCollision(ps PositionShip, speed Vector) Status {
-	minDist := calculateMinDistance(ShipPosition{
-		Position: Vector{X: 0, Y: 0},
-		Speed:    Vector{X: 0, Y: 0},
-	}, ShipPosition{
-		Position: ps.Point,
-		Speed:    speed,
-	}, predictionTimeSeconds)
+// evaluateShipStatus finds closest point of approach of ship from request and another ship with the history,
+// thresholds are extended by the combined safety domains of the two ships.
+// Returns false if ship has no positions within prediction window
+func (t *Traffic) evaluateShipStatus(ps PositionShip, speed Vector, shipID string, history []ShipPosition) (Conflict, bool) {
+	conflict := Conflict{
+		Kind:     KindShip,
+		Distance: math.MaxFloat64,
+	}
+	found := false
+
+	// other ships already aligned into the [ps.Time: ps.Time + 60 window]
+	// with adujusted speed(code is prettier now :) )
+	// move both ships to ts and calculate distance
+	currentPosition := ps.Point
+	currentTime := ps.Time
+	maxPredictionTime := ps.Time + t.cfg.PredictionWindow
+	// speeds of the other ship are recalculated within its own limit
+	cfg := t.cfg
+	cfg.MaxSpeed = t.maxSpeed(shipID)
+	collisionCandidates := rewindShipBinarySearch(history, ps, cfg)
+	for i, otherShip := range collisionCandidates {
+		if otherShip.Time == 0 {
+			continue // no history for this time
+		}
+
+		// because there are many updates possible within 60 seconds
+		// dist calculation must be done for smaller time windows not just +60
+		nextPredictionTime := maxPredictionTime
+		if i < len(collisionCandidates)-1 {
+			nextPredictionTime = min(collisionCandidates[i+1].Time, maxPredictionTime)
+		}
+
+		// ships must be at the time for calculate min distance to work
+		currentPosition = currentPosition.Add(speed.ScalarMultiply(float64(otherShip.Time - currentTime)))
+		currentTime = otherShip.Time
+
+		minDist, at := calculateMinDistance(ShipPosition{
+			Position: otherShip.Position,
+			Speed:    otherShip.Speed,
+		}, ShipPosition{
+			Position: currentPosition,
+			Speed:    speed,
+		}, float64(nextPredictionTime-currentTime))
+
+		found = true
+		if minDist < conflict.Distance {
+			conflict.Distance = minDist
+			conflict.Time = float64(currentTime) + at
+			conflict.Position = currentPosition.Add(speed.ScalarMultiply(at))
+			conflict.OtherPosition = otherShip.Position.Add(otherShip.Speed.ScalarMultiply(at))
+		}
+	}
+
+	conflict.Status = t.cfg.statusForDist(conflict.Distance, t.domain(ps.ID)+t.domain(shipID))
 
-	return statusForDist(minDist)
+	return conflict, found
 }
 
-// find time box starting at ps.Time and ending at ps.Time + 60
+// find time box starting at ps.Time and ending at ps.Time + window
 // maybe second search for the end could be linear? - depends on density of updates
 // with small density for next 60 seconds second linear search will be very fast
 // however I don't want to make assumptions about the density of updates
 // so we will use binary search for both
 // on second thought, linear search could have better CPU cache performance - benchmark later
-func rewindShipBinarySearch(history []ShipPosition, ps PositionShip) []ShipPosition {
+func rewindShipBinarySearch(history []ShipPosition, ps PositionShip, cfg Config) []ShipPosition {
 	if len(history) == 0 {
 		return nil
 	}
@@ -288,7 +490,7 @@ func rewindShipBinarySearch(history []ShipPosition, ps PositionShip) []ShipPosit
 	}
 
 	endIndex := sort.Search(len(history), func(i int) bool {
-		return history[i].Time > ps.Time+int(predictionTimeSeconds)
+		return history[i].Time > ps.Time+cfg.PredictionWindow
 	})
 	// all out of range
 	if startIndex == endIndex && startIndex == len(history) {
@@ -303,10 +505,10 @@ func rewindShipBinarySearch(history []ShipPosition, ps PositionShip) []ShipPosit
 	}
 
 	// calc actual speed for all candidates if we have next position
-	// if range doesn't cover ps.Time + 60 look ahead one more position
-	// endIndex points to the first position that is greater than ps.Time + 60
+	// if range doesn't cover ps.Time + window look ahead one more position
+	// endIndex points to the first position that is greater than ps.Time + window
 	lastIndex := endIndex
-	if endIndex != len(history) && history[endIndex-1].Time < ps.Time+int(predictionTimeSeconds) {
+	if endIndex != len(history) && history[endIndex-1].Time < ps.Time+cfg.PredictionWindow {
 		lastIndex = endIndex + 1
 	}
 
@@ -322,7 +524,7 @@ func rewindShipBinarySearch(history []ShipPosition, ps PositionShip) []ShipPosit
 			// time = 100 x = 100, y = 0, speed = 1,0 -- very different trajectory from the last one
 			// and out of the prediction window, which means we don't know the speed
 			// so we calculate REAL speed using future position we already know
-			candidates[i].Speed = calculateShipSpeed(float64(speedCandidates[i+1].Time-candidates[i].Time), speedCandidates[i+1].Position, candidates[i].Position)
+			candidates[i].Speed = calculateShipSpeed(float64(speedCandidates[i+1].Time-candidates[i].Time), speedCandidates[i+1].Position, candidates[i].Position, cfg.MaxSpeed)
 		}
 	}
 
@@ -333,47 +535,36 @@ func rewindShipBinarySearch(history []ShipPosition, ps PositionShip) []ShipPosit
 	return candidates
 }
 
-func statusForDist(minDist float64) Status {
-	if minDist < RedThreshold {
-		return Red
-	}
-	if minDist < YellowThreshold {
-		return Yellow
-	}
-
-	return Green
-}
-
 // calculateMinDistance calculates the minimum distance between two ships
 // over a given duration. It uses the relative position and velocity of the
 // ships to determine the time of closest approach and computes the distance
 // at that time, as well as at the start and end of the duration.
-func calculateMinDistance(s1, s2 ShipPosition, duration float64) float64 {
+// Returns the distance and time offset when it happens.
+func calculateMinDistance(s1, s2 ShipPosition, duration float64) (float64, float64) {
 	rPos := s1.Position.Subtract(s2.Position)
 	rVel := s1.Speed.Subtract(s2.Speed)
 
 	relSpeedSq := rVel.MagnitudeSquared()
 
 	if relSpeedSq < epsilon {
-		return rPos.Magnitude()
+		return rPos.Magnitude(), 0
 	}
 
 	dotProduct := rPos.Dot(rVel)
 	// Time of closest approach - painful math
 	tMin := -dotProduct / relSpeedSq
 
-	distAt0 := rPos.MagnitudeSquared()
-	distAtDur := distAt(s1, s2, duration)
-	distAtTmin := math.MaxFloat64
+	dist, at := rPos.MagnitudeSquared(), 0.0
+	if distAtDur := distAt(s1, s2, duration); distAtDur < dist {
+		dist, at = distAtDur, duration
+	}
 	if tMin > 0 && tMin < duration {
-		distAtTmin = distAt(s1, s2, tMin)
+		if distAtTmin := distAt(s1, s2, tMin); distAtTmin < dist {
+			dist, at = distAtTmin, tMin
+		}
 	}
 
-	return math.Sqrt(min(
-		distAt0,
-		distAtDur,
-		distAtTmin,
-	))
+	return math.Sqrt(dist), at
 }
 
 func distAt(s1, s2 ShipPosition, duration float64) float64 {
@@ -2,11 +2,13 @@ package traffic
 
 import (
 	"fmt"
+	"math"
 	"math/rand/v2"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func generateHistory(size int) []ShipPosition {
@@ -18,12 +20,30 @@ func generateHistory(size int) []ShipPosition {
 	return history
 }
 
-func benchmarkRewindFunc(b *testing.B, size int, rewindFunc func([]ShipPosition, PositionShip) []ShipPosition) {
+func mustNewTraffic(tb testing.TB, cfg Config) *Traffic {
+	t, err := NewTraffic(cfg, NewMemoryStore())
+	require.NoError(tb, err)
+	return t
+}
+
+func newTrafficWithHistory(tb testing.TB, cfg Config, history map[string][]ShipPosition) *Traffic {
+	store := NewMemoryStore()
+	for id, positions := range history {
+		require.NoError(tb, store.Put(id, positions, Green))
+	}
+
+	t, err := NewTraffic(cfg, store)
+	require.NoError(tb, err)
+	return t
+}
+
+func benchmarkRewindFunc(b *testing.B, size int, rewindFunc func([]ShipPosition, PositionShip, Config) []ShipPosition) {
+	cfg := DefaultConfig()
 	history := generateHistory(size)
-	ps := PositionShip{Time: history[size/2].Time - predictionTimeSeconds/2}
+	ps := PositionShip{Time: history[size/2].Time - cfg.PredictionWindow/2}
 
 	for b.Loop() {
-		_ = rewindFunc(history, ps)
+		_ = rewindFunc(history, ps, cfg)
 	}
 }
 
@@ -37,7 +57,7 @@ func BenchmarkRewind(b *testing.B) {
 }
 
 func BenchmarkPosition(b *testing.B) {
-	t := NewTraffic()
+	t := mustNewTraffic(b, DefaultConfig())
 	sizes := []int{10, 100, 1000, 10000, 100_000, 1_000_000, 10_000_000, 100_000_000}
 
 	for _, N := range sizes {
@@ -85,6 +105,48 @@ func BenchmarkPosition(b *testing.B) {
 	}
 }
 
+// BenchmarkEvaluateTrafficStatus compares indexed evaluation against scan over all ships
+// fleet density is the same for all sizes, so indexed evaluation should stay flat
+func BenchmarkEvaluateTrafficStatus(b *testing.B) {
+	sizes := []int{1000, 10_000, 100_000}
+	for _, N := range sizes {
+		r := rand.New(rand.NewPCG(1, 2))
+		traffic := mustNewTraffic(b, DefaultConfig())
+		area := 10_000 * float64(N)
+		randomFleet(r, traffic, N, area)
+
+		probe := func() (PositionShip, Vector) {
+			return PositionShip{
+					ID:    "probe",
+					Time:  1200,
+					Point: Vector{X: r.Float64() * area, Y: r.Float64() * area},
+				},
+				Vector{X: r.Float64()*200 - 100, Y: r.Float64()*200 - 100}
+		}
+
+		b.Run(fmt.Sprintf("name=index/size=%d", N), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(N), "ships")
+			for b.Loop() {
+				ps, speed := probe()
+				_, _ = traffic.evaluateTrafficStatus(ps, speed)
+			}
+		})
+
+		b.Run(fmt.Sprintf("name=scan/size=%d", N), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(N), "ships")
+			for b.Loop() {
+				ps, speed := probe()
+				traffic.store.Range(func(id string, history []ShipPosition, _ Status) bool {
+					_, _ = traffic.evaluateShipStatus(ps, speed, id, history)
+					return true
+				})
+			}
+		})
+	}
+}
+
 func IdsPool(N int) []string {
 	ids := make([]string, N)
 	for i := range N {
@@ -106,7 +168,7 @@ func TestRewindShipCalculatesSpeed(t *testing.T) {
 	}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, []ShipPosition{
 		{Time: 100, Position: Vector{X: 10, Y: 10}, Speed: Vector{X: 0.5, Y: 0.5}},
@@ -124,7 +186,7 @@ func TestRewindShipUseLastForSpeed(t *testing.T) {
 	}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, []ShipPosition{
 		{Time: 100, Position: Vector{X: 5, Y: 5}, Speed: Vector{X: 0.5, Y: 0.5}}, // moved to start of the window
@@ -139,7 +201,7 @@ func TestRewindShipStillShips(t *testing.T) {
 	}
 	ps := PositionShip{Time: 124}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, []ShipPosition{
 		{Time: 124, Position: Vector{X: 1, Y: 1}, Speed: Vector{X: 0, Y: 0}},
@@ -154,7 +216,7 @@ func TestRewindShipPositionAdjustment(t *testing.T) {
 	}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, 100, result[0].Time)
 	assert.Equal(t, Vector{X: 10, Y: 10}, result[0].Position) // 0,0 + (1,1) * 10
@@ -168,7 +230,7 @@ func TestRewindShipSinglePosition(t *testing.T) {
 	}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, 1, len(result))
 	assert.Equal(t, 100, result[0].Time)
@@ -182,7 +244,7 @@ func TestRewindShipExactBoundaries(t *testing.T) {
 	}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, 2, len(result))
 	assert.Equal(t, 100, result[0].Time)
@@ -202,7 +264,7 @@ func TestRewindShipComplexScenario(t *testing.T) {
 	}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, 3, len(result))
 
@@ -226,7 +288,7 @@ func TestRewindShipEdgeCases(t *testing.T) {
 	}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, 1, len(result))
 	assert.Equal(t, 100, result[0].Time)
@@ -240,7 +302,7 @@ func TestRewindShipOutOfRange(t *testing.T) {
 	}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, 0, len(result)) // No positions should be returned
 }
@@ -249,11 +311,90 @@ func TestRewindShipEmptyHistory(t *testing.T) {
 	history := []ShipPosition{}
 	ps := PositionShip{Time: 100}
 
-	result := rewindShipBinarySearch(history, ps)
+	result := rewindShipBinarySearch(history, ps, DefaultConfig())
 
 	assert.Equal(t, 0, len(result)) // No positions should be returned
 }
 
+func TestRewindShipShortWindow(t *testing.T) {
+	history := []ShipPosition{
+		{Time: 90, Position: Vector{X: 0, Y: 0}, Speed: Vector{X: 1, Y: 1}},
+		{Time: 110, Position: Vector{X: 20, Y: 20}, Speed: Vector{X: 1, Y: 1}},
+		{Time: 140, Position: Vector{X: 50, Y: 50}, Speed: Vector{X: 1, Y: 1}},
+	}
+	ps := PositionShip{Time: 100}
+	cfg := DefaultConfig()
+	cfg.PredictionWindow = 20
+
+	result := rewindShipBinarySearch(history, ps, cfg)
+
+	assert.Equal(t, []ShipPosition{
+		{Time: 100, Position: Vector{X: 10, Y: 10}, Speed: Vector{X: 1, Y: 1}},
+		{Time: 110, Position: Vector{X: 20, Y: 20}, Speed: Vector{X: 1, Y: 1}}, // speed adjusted using position out of the window
+	}, result)
+}
+
+func TestRewindShipLongWindow(t *testing.T) {
+	history := []ShipPosition{
+		{Time: 90, Position: Vector{X: 0, Y: 0}, Speed: Vector{X: 0, Y: 0}},
+		{Time: 110, Position: Vector{X: 10, Y: 10}, Speed: Vector{X: 1, Y: 1}},
+		{Time: 120, Position: Vector{X: 20, Y: 20}, Speed: Vector{X: 1, Y: 1}},
+		{Time: 200, Position: Vector{X: 500, Y: 500}, Speed: Vector{X: 2, Y: 2}},
+		{Time: 400, Position: Vector{X: 500, Y: 500}, Speed: Vector{X: 0, Y: 0}},
+	}
+	ps := PositionShip{Time: 100}
+	cfg := DefaultConfig()
+	cfg.PredictionWindow = 120
+
+	result := rewindShipBinarySearch(history, ps, cfg)
+
+	assert.Equal(t, []ShipPosition{
+		{Time: 100, Position: Vector{X: 5, Y: 5}, Speed: Vector{X: 0.5, Y: 0.5}},
+		{Time: 110, Position: Vector{X: 10, Y: 10}, Speed: Vector{X: 1, Y: 1}},
+		{Time: 120, Position: Vector{X: 20, Y: 20}, Speed: Vector{X: 6, Y: 6}},
+		{Time: 200, Position: Vector{X: 500, Y: 500}, Speed: Vector{X: 0, Y: 0}}, // inside of the window now
+	}, result)
+}
+
+func TestRewindShipLowMaxSpeed(t *testing.T) {
+	history := []ShipPosition{
+		{Time: 100, Position: Vector{X: 0, Y: 0}},
+		{Time: 110, Position: Vector{X: 0, Y: 100}},
+	}
+	ps := PositionShip{Time: 100}
+	cfg := DefaultConfig()
+	cfg.MaxSpeed = 2
+
+	result := rewindShipBinarySearch(history, ps, cfg)
+
+	assert.Equal(t, Vector{X: 0, Y: 2}, result[0].Speed) // truncated to max speed
+}
+
+func TestEvaluateTrafficStatusCustomConfig(t *testing.T) {
+	cfg := Config{
+		YellowThreshold:  50,
+		RedThreshold:     10,
+		MaxSpeed:         10,
+		PredictionWindow: 10,
+	}
+	traffic := newTrafficWithHistory(t, cfg, map[string][]ShipPosition{
+		"ship2": {
+			{Time: 100, Position: Vector{X: 1000, Y: 0}, Speed: Vector{X: 0, Y: 0}},
+		},
+		"ship3": {
+			{Time: 100, Position: Vector{X: -1000, Y: 30}, Speed: Vector{X: 0, Y: 0}},
+		},
+	})
+
+	// would be red with 60 seconds window, but ship2 is 900 units away in 10 seconds
+	status, _ := traffic.evaluateTrafficStatus(PositionShip{ID: "ship1", Time: 100, Point: Vector{X: 0, Y: 100}}, Vector{X: 10, Y: 0})
+	assert.Equal(t, Green, status)
+
+	// 30 units is far enough for default thresholds
+	status, _ = traffic.evaluateTrafficStatus(PositionShip{ID: "ship1", Time: 100, Point: Vector{X: -1000, Y: 0}}, Vector{})
+	assert.Equal(t, Yellow, status)
+}
+
 func TestEvaluateTrafficStatus(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -369,7 +510,7 @@ func TestEvaluateTrafficStatus(t *testing.T) {
 			name: "Edge of time window",
 			history: map[string][]ShipPosition{
 				"ship2": {
-					{Time: 100 + predictionTimeSeconds, Position: Vector{X: 0.5, Y: 0}, Speed: Vector{X: 0, Y: 0}},
+					{Time: 100 + DefaultConfig().PredictionWindow, Position: Vector{X: 0.5, Y: 0}, Speed: Vector{X: 0, Y: 0}},
 				},
 			},
 			positionShip:   PositionShip{ID: "ship1", Time: 100, Point: Vector{X: 0, Y: 0}},
@@ -391,11 +532,64 @@ func TestEvaluateTrafficStatus(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			traffic := NewTraffic()
-			traffic.History = tt.history
+			traffic := newTrafficWithHistory(t, DefaultConfig(), tt.history)
 
-			status := traffic.evaluateTrafficStatus(tt.positionShip, tt.speed)
+			status, _ := traffic.evaluateTrafficStatus(tt.positionShip, tt.speed)
 			assert.Equal(t, tt.expectedStatus, status, "Unexpected traffic status")
 		})
 	}
 }
+
+func TestEvaluateTrafficStatusConflicts(t *testing.T) {
+	traffic := newTrafficWithHistory(t, DefaultConfig(), map[string][]ShipPosition{
+		"ship2": {
+			{Time: 100, Position: Vector{X: 110, Y: 10}, Speed: Vector{X: -1, Y: 0}},
+		},
+		"ship3": {
+			{Time: 90, Position: Vector{X: 101, Y: 11.5}, Speed: Vector{X: 0, Y: 0}},
+		},
+		"ship4": {
+			{Time: 100, Position: Vector{X: 500, Y: 500}, Speed: Vector{X: 0, Y: 0}},
+		},
+	})
+
+	status, conflicts := traffic.evaluateTrafficStatus(PositionShip{ID: "ship1", Time: 100, Point: Vector{X: 90, Y: 10}}, Vector{X: 1, Y: 0})
+
+	assert.Equal(t, Red, status)
+	require.Len(t, conflicts, 2)
+
+	// ship2 goes head on and meets ship1 at (100, 10) in 10 seconds
+	assert.Equal(t, Conflict{Kind: KindShip, ID: "ship2", Status: Red, Distance: 0, Time: 110, Position: Vector{X: 100, Y: 10}, OtherPosition: Vector{X: 100, Y: 10}}, conflicts[0])
+	// ship1 passes by standing still ship3 a second later
+	assert.Equal(t, Conflict{Kind: KindShip, ID: "ship3", Status: Yellow, Distance: 1.5, Time: 111, Position: Vector{X: 101, Y: 10}, OtherPosition: Vector{X: 101, Y: 11.5}}, conflicts[1])
+}
+
+func TestEvaluateTrafficStatusTowerConflict(t *testing.T) {
+	traffic := mustNewTraffic(t, DefaultConfig())
+
+	status, conflicts := traffic.evaluateTrafficStatus(PositionShip{ID: "ship1", Time: 100, Point: Vector{X: -10, Y: 1.5}}, Vector{X: 1, Y: 0})
+
+	assert.Equal(t, Yellow, status)
+	assert.Equal(t, []Conflict{
+		{Kind: KindHazard, ID: TowerID, Status: Yellow, Distance: 1.5, Time: 110, Position: Vector{X: 0, Y: 1.5}, OtherPosition: Vector{X: 0, Y: 0}},
+	}, conflicts)
+}
+
+func TestCalculateMinDistance(t *testing.T) {
+	s1 := ShipPosition{Position: Vector{X: 0, Y: 0}, Speed: Vector{X: 1, Y: 0}}
+	s2 := ShipPosition{Position: Vector{X: 10, Y: 3}, Speed: Vector{X: -1, Y: 0}}
+
+	dist, at := calculateMinDistance(s1, s2, 60)
+	assert.Equal(t, 3.0, dist)
+	assert.Equal(t, 5.0, at)
+
+	// closest approach is after the duration
+	dist, at = calculateMinDistance(s1, s2, 2)
+	assert.InDelta(t, math.Sqrt(45), dist, 0.0001) // (2, 0) and (8, 3)
+	assert.Equal(t, 2.0, at)
+
+	// moving away
+	dist, at = calculateMinDistance(ShipPosition{Position: Vector{X: 10, Y: 3}, Speed: Vector{X: 1, Y: 0}}, ShipPosition{Position: Vector{X: 0, Y: 3}}, 60)
+	assert.Equal(t, 10.0, dist)
+	assert.Equal(t, 0.0, at)
+}
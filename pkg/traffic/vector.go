@@ -51,3 +51,8 @@ func (v Vector) Add(other Vector) Vector {
 		Y: v.Y + other.Y,
 	}
 }
+
+// Cross is z component of the cross product, positive if other is counterclockwise from v
+func (v Vector) Cross(other Vector) float64 {
+	return v.X*other.Y - v.Y*other.X
+}